@@ -21,3 +21,9 @@ type Expression interface {
 	Node
 	expressionNode()
 }
+
+// Node type - Pattern, matched against a value and binding names
+type Pattern interface {
+	Node
+	patternNode()
+}
@@ -2,7 +2,9 @@ package ast
 
 import (
 	"bytes"
+	"math/big"
 	"mkc/token"
+	"strconv"
 	"strings"
 )
 
@@ -35,11 +37,28 @@ func (p *Program) String() string {
 	return out.String()
 }
 
+// Returns the names exported by the top level of the program, in order
+func (p *Program) Exports() []*Identifier {
+	var names []*Identifier
+	for _, s := range p.Statements {
+		if es, ok := s.(*ExportStatement); ok && es != nil {
+			names = append(names, es.Names()...)
+		}
+	}
+	return names
+}
+
 // identifier node
 
 type Identifier struct {
 	Token tk.Token
 	Value string
+
+	// Set by the resolver for names local to a function. The name lives
+	// in slot Slot of the environment Depth functions out.
+	Resolved bool
+	Depth    int
+	Slot     int
 }
 
 func (i *Identifier) expressionNode() {}
@@ -48,12 +67,23 @@ func (i *Identifier) String() string {
 	return i.Value
 }
 
-// let statement node
+// let statement node, also used for const
 
 type LetStatement struct {
-	Token tk.Token
-	Name  *Identifier
-	Value Expression
+	Token   tk.Token
+	Name    *Identifier // nil when destructuring
+	Pattern Pattern     // nil when binding a single name
+	Value   Expression
+}
+
+func (ls *LetStatement) IsConst() bool { return ls.Token.Type == tk.CONST }
+
+// Returns the names the statement binds
+func (ls *LetStatement) Names() []*Identifier {
+	if ls.Pattern != nil {
+		return PatternBindings(ls.Pattern)
+	}
+	return []*Identifier{ls.Name}
 }
 
 func (ls *LetStatement) statementNode() {}
@@ -61,7 +91,11 @@ func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(ls.TokenLiteral() + " ")
-	out.WriteString(ls.Name.String())
+	if ls.Pattern != nil {
+		out.WriteString(ls.Pattern.String())
+	} else {
+		out.WriteString(ls.Name.String())
+	}
 	out.WriteString(" = ")
 
 	if ls.Value != nil {
@@ -93,6 +127,150 @@ func (rs *ReturnStatement) String() string {
 	return out.String()
 }
 
+// struct declaration, binds a constructor for records with the fields
+
+type StructStatement struct {
+	Token  tk.Token
+	Name   *Identifier
+	Fields []*Identifier
+}
+
+func (ss *StructStatement) statementNode() {}
+func (ss *StructStatement) TokenLiteral() string { return ss.Token.Literal }
+func (ss *StructStatement) String() string {
+	var fields []string
+	for _, f := range ss.Fields {
+		fields = append(fields, f.String())
+	}
+	return ss.TokenLiteral() + " " + ss.Name.String() + " { " + strings.Join(fields, ", ") + " }"
+}
+
+// enum declaration, binds a type whose values are one of its variants
+
+type EnumStatement struct {
+	Token    tk.Token
+	Name     *Identifier
+	Variants []*EnumVariant
+}
+
+// A variant, Name or Name(FIELDS)
+type EnumVariant struct {
+	Name   *Identifier
+	Fields []*Identifier // nil for a variant without fields
+}
+
+func (ev *EnumVariant) String() string {
+	if ev.Fields == nil {
+		return ev.Name.String()
+	}
+	var fields []string
+	for _, f := range ev.Fields {
+		fields = append(fields, f.String())
+	}
+	return ev.Name.String() + "(" + strings.Join(fields, ", ") + ")"
+}
+
+func (es *EnumStatement) statementNode() {}
+func (es *EnumStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *EnumStatement) String() string {
+	var variants []string
+	for _, v := range es.Variants {
+		variants = append(variants, v.String())
+	}
+	return es.TokenLiteral() + " " + es.Name.String() + " { " + strings.Join(variants, ", ") + " }"
+}
+
+// Returns the variant with name, or nil
+func (es *EnumStatement) Variant(name string) *EnumVariant {
+	for _, v := range es.Variants {
+		if v.Name.Value == name {
+			return v
+		}
+	}
+	return nil
+}
+
+// impl block, adds methods to a struct or enum type
+
+type ImplStatement struct {
+	Token   tk.Token
+	Name    *Identifier
+	Methods []*FunctionStatement
+}
+
+func (is *ImplStatement) statementNode() {}
+func (is *ImplStatement) TokenLiteral() string { return is.Token.Literal }
+func (is *ImplStatement) String() string {
+	var methods []string
+	for _, m := range is.Methods {
+		methods = append(methods, m.String())
+	}
+	return is.TokenLiteral() + " " + is.Name.String() + " { " + strings.Join(methods, " ") + " }"
+}
+
+// import statement, binds the module at Path to Name
+
+type ImportStatement struct {
+	Token tk.Token
+	Path  *StringLiteral
+	Name  *Identifier
+}
+
+func (is *ImportStatement) statementNode() {}
+func (is *ImportStatement) TokenLiteral() string { return is.Token.Literal }
+func (is *ImportStatement) String() string {
+	return is.TokenLiteral() + " " + is.Path.String() + " as " + is.Name.String() + ";"
+}
+
+// export statement, makes the names a declaration binds visible to importers
+
+type ExportStatement struct {
+	Token       tk.Token
+	Declaration Statement // a let, const, fn, struct or enum declaration
+}
+
+func (es *ExportStatement) statementNode() {}
+func (es *ExportStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExportStatement) String() string {
+	return es.TokenLiteral() + " " + es.Declaration.String()
+}
+
+// Returns the names the declaration binds
+func (es *ExportStatement) Names() []*Identifier {
+	switch d := es.Declaration.(type) {
+	case *LetStatement:
+		return d.Names()
+	case *FunctionStatement:
+		return []*Identifier{d.Name}
+	case *StructStatement:
+		return []*Identifier{d.Name}
+	case *EnumStatement:
+		return []*Identifier{d.Name}
+	}
+	return nil
+}
+
+// Returns the declaration of an export statement, or statement itself
+func Unexported(statement Statement) Statement {
+	if es, ok := statement.(*ExportStatement); ok && es != nil {
+		return es.Declaration
+	}
+	return statement
+}
+
+// yield statement, suspends a generator
+
+type YieldStatement struct {
+	Token tk.Token
+	Value Expression
+}
+
+func (ys *YieldStatement) statementNode() {}
+func (ys *YieldStatement) TokenLiteral() string { return ys.Token.Literal }
+func (ys *YieldStatement) String() string {
+	return ys.TokenLiteral() + " " + ys.Value.String() + ";"
+}
+
 // expression statement
 
 type ExpressionStatement struct {
@@ -115,11 +293,51 @@ func (es *ExpressionStatement) String() string {
 type IntegerLiteral struct {
 	Token tk.Token
 	Value int64
+	Big   *big.Int // set instead of Value when the literal overflows 64 bits
 }
 func (il *IntegerLiteral) expressionNode() {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
 func (il *IntegerLiteral) String() string { return il.Token.Literal }
 
+// float literal
+
+type FloatLiteral struct {
+	Token tk.Token
+	Value float64
+}
+func (fl *FloatLiteral) expressionNode() {}
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) String() string { return fl.Token.Literal }
+
+// string literal
+
+type StringLiteral struct {
+	Token tk.Token
+	Value string
+}
+
+func (sl *StringLiteral) expressionNode() {}
+func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StringLiteral) String() string { return strconv.Quote(sl.Value) }
+
+// array literal
+
+type ArrayLiteral struct {
+	Token    tk.Token
+	Elements []Expression
+}
+
+func (al *ArrayLiteral) expressionNode() {}
+func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) String() string {
+	var elements []string
+	for _, e := range al.Elements {
+		elements = append(elements, e.String())
+	}
+
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
 // prefix expression
 
 type PrefixExpression struct {
@@ -141,6 +359,20 @@ func (pe *PrefixExpression) String() string {
 	return out.String()
 }
 
+// postfix expression
+
+type PostfixExpression struct {
+	Token    tk.Token
+	Left     Expression
+	Operator string
+}
+
+func (pe *PostfixExpression) expressionNode() {}
+func (pe *PostfixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PostfixExpression) String() string {
+	return "(" + pe.Left.String() + pe.Operator + ")"
+}
+
 // infix expression
 
 type InfixExpression struct {
@@ -164,6 +396,28 @@ func (ie *InfixExpression) String() string {
 	return out.String()
 }
 
+// assignment
+
+type AssignExpression struct {
+	Token tk.Token
+	Name  *Identifier
+	Value Expression
+}
+
+func (ae *AssignExpression) expressionNode() {}
+func (ae *AssignExpression) TokenLiteral() string { return ae.Token.Literal }
+func (ae *AssignExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(ae.Name.String())
+	out.WriteString(" = ")
+	out.WriteString(ae.Value.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
 // boolean
 
 type BooleanLiteral struct {
@@ -224,8 +478,70 @@ func (ie *IfExpression) String() string {
 
 type FunctionLiteral struct {
 	Token      tk.Token
+	Name       string // empty for anonymous functions
 	Parameters []*Identifier
 	Body       *BlockStatement
+	Slots      int  // number of resolved names, set by the resolver
+	Arrow      bool // written as PARAMETERS => BODY
+	Generator  bool // written as fn*, calling it returns an iterator
+
+	// Patterns of destructured parameters, nil for plain names. The
+	// parameter of a destructured position only names it for printing.
+	Patterns []Pattern
+}
+
+// Prints the short form, x => BODY or (a, b) => BODY
+func (fl *FunctionLiteral) arrowString(params []string) string {
+	var out bytes.Buffer
+
+	if len(params) == 1 && fl.ParameterPattern(0) == nil {
+		out.WriteString(params[0])
+	} else {
+		out.WriteString("(" + strings.Join(params, ", ") + ")")
+	}
+	out.WriteString(" => ")
+
+	switch {
+	case fl.Body.Token.Type == tk.LBRACE:
+		out.WriteString("{ " + fl.Body.String() + " }")
+	case len(fl.Body.Statements) == 1 && isHashStatement(fl.Body.Statements[0]):
+		// a brace after => starts a block, so a hash body needs parentheses
+		out.WriteString("(" + fl.Body.String() + ")")
+	default:
+		out.WriteString(fl.Body.String())
+	}
+
+	return out.String()
+}
+
+func isHashStatement(statement Statement) bool {
+	es, ok := statement.(*ExpressionStatement)
+	if !ok {
+		return false
+	}
+	_, ok = es.Expression.(*HashLiteral)
+	return ok
+}
+
+// Returns the names the parameters bind
+func (fl *FunctionLiteral) Bindings() []*Identifier {
+	var names []*Identifier
+	for i, param := range fl.Parameters {
+		if pattern := fl.ParameterPattern(i); pattern != nil {
+			names = append(names, PatternBindings(pattern)...)
+		} else {
+			names = append(names, param)
+		}
+	}
+	return names
+}
+
+// Returns the pattern of the i-th parameter, or nil for a plain name
+func (fl *FunctionLiteral) ParameterPattern(i int) Pattern {
+	if i < len(fl.Patterns) {
+		return fl.Patterns[i]
+	}
+	return nil
 }
 
 func (fl *FunctionLiteral) expressionNode() {}
@@ -238,7 +554,17 @@ func (fl *FunctionLiteral) String() string {
 		params = append(params, p.String())
 	}
 
+	if fl.Arrow {
+		return fl.arrowString(params)
+	}
+
 	out.WriteString(fl.TokenLiteral())
+	if fl.Generator {
+		out.WriteString("*")
+	}
+	if fl.Name != "" {
+		out.WriteString(" " + fl.Name)
+	}
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ","))
 	out.WriteString(")")
@@ -247,6 +573,65 @@ func (fl *FunctionLiteral) String() string {
 	return out.String()
 }
 
+// member access, record.field
+
+type MemberExpression struct {
+	Token  tk.Token // the .
+	Object Expression
+	Member *Identifier
+}
+
+func (me *MemberExpression) expressionNode() {}
+func (me *MemberExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MemberExpression) String() string {
+	return me.Object.String() + "." + me.Member.String()
+}
+
+// method call, value.method(ARGUMENTS)
+
+type MethodCallExpression struct {
+	Token     tk.Token // the (
+	Object    Expression
+	Method    *Identifier
+	Arguments []Expression
+}
+
+func (mc *MethodCallExpression) expressionNode() {}
+func (mc *MethodCallExpression) TokenLiteral() string { return mc.Token.Literal }
+func (mc *MethodCallExpression) String() string {
+	var args []string
+	for _, a := range mc.Arguments {
+		args = append(args, a.String())
+	}
+	return mc.Object.String() + "." + mc.Method.String() + "(" + strings.Join(args, ", ") + ")"
+}
+
+// member assignment, record.field = value
+
+type MemberAssignExpression struct {
+	Token  tk.Token // the =
+	Target *MemberExpression
+	Value  Expression
+}
+
+func (ma *MemberAssignExpression) expressionNode() {}
+func (ma *MemberAssignExpression) TokenLiteral() string { return ma.Token.Literal }
+func (ma *MemberAssignExpression) String() string {
+	return "(" + ma.Target.String() + " = " + ma.Value.String() + ")"
+}
+
+// function declaration
+
+type FunctionStatement struct {
+	Token    tk.Token
+	Name     *Identifier
+	Function *FunctionLiteral
+}
+
+func (fs *FunctionStatement) statementNode() {}
+func (fs *FunctionStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *FunctionStatement) String() string { return fs.Function.String() }
+
 // function calls
 
 type CallExpression struct {
@@ -272,3 +657,334 @@ func (ce *CallExpression) String() string {
 
 	return out.String()
 }
+
+// index
+
+type IndexExpression struct {
+	Token tk.Token
+	Left  Expression
+	Index Expression
+}
+
+func (ie *IndexExpression) expressionNode() {}
+func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(ie.Left.String())
+	out.WriteString("[")
+	out.WriteString(ie.Index.String())
+	out.WriteString("])")
+
+	return out.String()
+}
+
+// null literal
+
+type NullLiteral struct {
+	Token tk.Token
+}
+
+func (nl *NullLiteral) expressionNode() {}
+func (nl *NullLiteral) TokenLiteral() string { return nl.Token.Literal }
+func (nl *NullLiteral) String() string { return nl.Token.Literal }
+
+// conditional expression, cond ? a : b
+
+type ConditionalExpression struct {
+	Token       tk.Token
+	Condition   Expression
+	Consequence Expression
+	Alternative Expression
+}
+
+func (ce *ConditionalExpression) expressionNode() {}
+func (ce *ConditionalExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *ConditionalExpression) String() string {
+	return "(" + ce.Condition.String() + " ? " + ce.Consequence.String() + " : " + ce.Alternative.String() + ")"
+}
+
+// for in loop
+
+type ForStatement struct {
+	Token    tk.Token
+	Pattern  Pattern
+	Iterable Expression
+	Body     *BlockStatement
+
+	Slots int // number of resolved names bound by each iteration
+}
+
+func (fs *ForStatement) statementNode() {}
+func (fs *ForStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *ForStatement) String() string {
+	return "for (" + fs.Pattern.String() + " in " + fs.Iterable.String() + ") " + fs.Body.String()
+}
+
+// spread, ...iterable in array literals and call arguments
+
+type SpreadExpression struct {
+	Token tk.Token
+	Value Expression
+}
+
+func (se *SpreadExpression) expressionNode() {}
+func (se *SpreadExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SpreadExpression) String() string { return "..." + se.Value.String() }
+
+// throw statement
+
+type ThrowStatement struct {
+	Token tk.Token
+	Value Expression
+}
+
+func (ts *ThrowStatement) statementNode() {}
+func (ts *ThrowStatement) TokenLiteral() string { return ts.Token.Literal }
+func (ts *ThrowStatement) String() string {
+	return ts.TokenLiteral() + " " + ts.Value.String() + ";"
+}
+
+// try catch finally
+
+type TryExpression struct {
+	Token   tk.Token
+	Block   *BlockStatement
+	Param   *Identifier     // nil without a catch clause
+	Catch   *BlockStatement // nil without a catch clause
+	Finally *BlockStatement // nil without a finally clause
+
+	CatchSlots int // number of resolved names in the catch clause
+}
+
+func (te *TryExpression) expressionNode() {}
+func (te *TryExpression) TokenLiteral() string { return te.Token.Literal }
+func (te *TryExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("try ")
+	out.WriteString(te.Block.String())
+
+	if te.Catch != nil {
+		out.WriteString(" catch(")
+		out.WriteString(te.Param.String())
+		out.WriteString(") ")
+		out.WriteString(te.Catch.String())
+	}
+
+	if te.Finally != nil {
+		out.WriteString(" finally ")
+		out.WriteString(te.Finally.String())
+	}
+
+	return out.String()
+}
+
+// hash literal
+
+type HashLiteral struct {
+	Token tk.Token
+	Keys  []Expression
+	Pairs map[Expression]Expression
+}
+
+func (hl *HashLiteral) expressionNode() {}
+func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) String() string {
+	var pairs []string
+	for _, key := range hl.Keys {
+		pairs = append(pairs, key.String()+": "+hl.Pairs[key].String())
+	}
+
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// match expression
+
+type MatchExpression struct {
+	Token   tk.Token
+	Subject Expression
+	Arms    []*MatchArm
+}
+
+func (me *MatchExpression) expressionNode() {}
+func (me *MatchExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MatchExpression) String() string {
+	var arms []string
+	for _, arm := range me.Arms {
+		arms = append(arms, arm.String())
+	}
+
+	return "match (" + me.Subject.String() + ") {" + strings.Join(arms, ", ") + "}"
+}
+
+type MatchArm struct {
+	Token   tk.Token
+	Pattern Pattern
+	Guard   Expression // nil without an if guard
+	Body    *BlockStatement
+
+	Slots int // number of resolved names bound in the arm
+}
+
+func (ma *MatchArm) TokenLiteral() string { return ma.Token.Literal }
+func (ma *MatchArm) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(ma.Pattern.String())
+	if ma.Guard != nil {
+		out.WriteString(" if " + ma.Guard.String())
+	}
+	out.WriteString(" => ")
+	out.WriteString(ma.Body.String())
+
+	return out.String()
+}
+
+//////////////
+// Patterns //
+//////////////
+
+// _, matches anything
+
+type WildcardPattern struct {
+	Token tk.Token
+}
+
+func (wp *WildcardPattern) patternNode() {}
+func (wp *WildcardPattern) TokenLiteral() string { return wp.Token.Literal }
+func (wp *WildcardPattern) String() string { return "_" }
+
+// a name, matches anything and binds it
+
+type BindingPattern struct {
+	Token tk.Token
+	Name  *Identifier
+}
+
+func (bp *BindingPattern) patternNode() {}
+func (bp *BindingPattern) TokenLiteral() string { return bp.Token.Literal }
+func (bp *BindingPattern) String() string { return bp.Name.String() }
+
+// an integer, string or boolean literal, matches an equal value
+
+type LiteralPattern struct {
+	Token tk.Token
+	Value Expression
+}
+
+func (lp *LiteralPattern) patternNode() {}
+func (lp *LiteralPattern) TokenLiteral() string { return lp.Token.Literal }
+func (lp *LiteralPattern) String() string { return lp.Value.String() }
+
+// Enum.Variant(a, b), matches values of that variant whose fields match.
+// Without parentheses it matches any value of the variant.
+
+type VariantPattern struct {
+	Token   tk.Token
+	Enum    *Identifier
+	Variant *Identifier
+	Fields  []Pattern // nil without parentheses
+}
+
+func (vp *VariantPattern) patternNode() {}
+func (vp *VariantPattern) TokenLiteral() string { return vp.Token.Literal }
+func (vp *VariantPattern) String() string {
+	name := vp.Enum.String() + "." + vp.Variant.String()
+	if vp.Fields == nil {
+		return name
+	}
+	var fields []string
+	for _, f := range vp.Fields {
+		fields = append(fields, f.String())
+	}
+	return name + "(" + strings.Join(fields, ", ") + ")"
+}
+
+// [a, b, ...rest], matches arrays of that length, or at least that long with a rest
+
+type ArrayPattern struct {
+	Token    tk.Token
+	Elements []Pattern
+	Rest     Pattern // nil without ...rest
+}
+
+func (ap *ArrayPattern) patternNode() {}
+func (ap *ArrayPattern) TokenLiteral() string { return ap.Token.Literal }
+func (ap *ArrayPattern) String() string {
+	var elements []string
+	for _, e := range ap.Elements {
+		elements = append(elements, e.String())
+	}
+	if ap.Rest != nil {
+		elements = append(elements, "..."+ap.Rest.String())
+	}
+
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// {"key": pattern}, matches hashes having those keys
+
+type HashPattern struct {
+	Token  tk.Token
+	Keys   []Expression
+	Values []Pattern
+}
+
+func (hp *HashPattern) patternNode() {}
+func (hp *HashPattern) TokenLiteral() string { return hp.Token.Literal }
+func (hp *HashPattern) String() string {
+	var pairs []string
+	for i, key := range hp.Keys {
+		pairs = append(pairs, key.String()+": "+hp.Values[i].String())
+	}
+
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// Returns the first token of a pattern
+func PatternToken(pattern Pattern) tk.Token {
+	switch p := pattern.(type) {
+	case *WildcardPattern:
+		return p.Token
+	case *BindingPattern:
+		return p.Token
+	case *LiteralPattern:
+		return p.Token
+	case *ArrayPattern:
+		return p.Token
+	case *HashPattern:
+		return p.Token
+	case *VariantPattern:
+		return p.Token
+	}
+	return tk.Token{}
+}
+
+// Returns the names a pattern binds, in order
+func PatternBindings(pattern Pattern) []*Identifier {
+	var names []*Identifier
+
+	switch p := pattern.(type) {
+	case *BindingPattern:
+		names = append(names, p.Name)
+	case *ArrayPattern:
+		for _, e := range p.Elements {
+			names = append(names, PatternBindings(e)...)
+		}
+		if p.Rest != nil {
+			names = append(names, PatternBindings(p.Rest)...)
+		}
+	case *HashPattern:
+		for _, v := range p.Values {
+			names = append(names, PatternBindings(v)...)
+		}
+	case *VariantPattern:
+		for _, f := range p.Fields {
+			names = append(names, PatternBindings(f)...)
+		}
+	}
+
+	return names
+}
@@ -0,0 +1,51 @@
+package eval
+
+import (
+	"mkc/ast"
+	"mkc/lexer"
+	obj "mkc/object"
+	"mkc/parser"
+	"mkc/resolver"
+	"testing"
+)
+
+const fibProgram = `
+	let fib = fn(n) {
+		if (n < 2) { return n; }
+		fib(n - 1) + fib(n - 2);
+	};
+	fib(25);
+`
+
+func parseProgram(b *testing.B, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		b.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
+
+func benchmarkFib(b *testing.B, resolve bool) {
+	program := parseProgram(b, fibProgram)
+	if resolve {
+		resolver.Lint(program)
+	}
+
+	for i := 0; i < b.N; i++ {
+		result := Eval(program, obj.NewEnvironment())
+		if n, ok := result.(*obj.Integer); !ok || n.Value != 75025 {
+			b.Fatalf("fib(25) wrong. got=%+v", result)
+		}
+	}
+}
+
+// Every name looked up through the environment maps
+func BenchmarkFib25Named(b *testing.B) {
+	benchmarkFib(b, false)
+}
+
+// Locals annotated with slots by the resolver
+func BenchmarkFib25Slots(b *testing.B) {
+	benchmarkFib(b, true)
+}
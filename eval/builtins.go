@@ -0,0 +1,201 @@
+package eval
+
+import (
+	obj "mkc/object"
+	"sort"
+	"unicode/utf8"
+)
+
+// Functions available to every program
+// They are found after every scope of the environment has been searched
+var builtins = map[string]*obj.Builtin{
+	"ok":     {Name: "ok", Fn: builtinOk},
+	"err":    {Name: "err", Fn: builtinErr},
+	"is_ok":  {Name: "is_ok", Fn: builtinIsOk},
+	"is_err": {Name: "is_err", Fn: builtinIsErr},
+	"unwrap": {Name: "unwrap", Fn: builtinUnwrap},
+	"type":   {Name: "type", Fn: builtinType},
+	"len":    {Name: "len", Fn: builtinLen},
+}
+
+// Builtins that follow the interpreter's options, as any and all follow
+// -strict-bool. Each interpreter binds its own on first use.
+var optionBuiltins = map[string]func(options *obj.Options, args ...obj.Object) obj.Object{}
+
+// Returns the names of all builtins, such as for resolver.Define
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins)+len(optionBuiltins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	for name := range optionBuiltins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Returns the builtin called name, bound to the options of env's
+// interpreter if it follows them, or nil if there is none
+func lookupBuiltin(name string, env *obj.Environment) *obj.Builtin {
+	if builtin, ok := builtins[name]; ok {
+		return builtin
+	}
+	fn, ok := optionBuiltins[name]
+	if !ok {
+		return nil
+	}
+
+	bound := env.Modules().Builtins
+	if builtin, ok := bound[name]; ok {
+		return builtin
+	}
+	options := env.Options()
+	builtin := &obj.Builtin{Name: name, Fn: func(args ...obj.Object) obj.Object {
+		return fn(options, args...)
+	}}
+	bound[name] = builtin
+	return builtin
+}
+
+// Errors if a builtin did not get the number of arguments it takes
+func checkArgumentCount(name string, args []obj.Object, want int) *obj.Error {
+	if len(args) != want {
+		return newError(ARGUMENT_ERROR, "wrong number of arguments to %s: want=%d, got=%d",
+			name, want, len(args))
+	}
+	return nil
+}
+
+// Errors if a builtin did not get from min to max arguments
+func checkArgumentRange(name string, args []obj.Object, min int, max int) *obj.Error {
+	if len(args) < min || len(args) > max {
+		return newError(ARGUMENT_ERROR, "wrong number of arguments to %s: want=%d to %d, got=%d",
+			name, min, max, len(args))
+	}
+	return nil
+}
+
+///////////
+// Types //
+///////////
+
+// Returns the name of a value's type, the struct or enum name for their values
+func typeName(o obj.Object) string {
+	switch o := o.(type) {
+	case *obj.Struct:
+		return o.StructType.Name
+	case *obj.EnumValue:
+		return o.Variant.Enum.Name
+	}
+	return string(o.Type())
+}
+
+// type(value) returns the name of its type, such as "INTEGER", "Point" or "Shape"
+func builtinType(args ...obj.Object) obj.Object {
+	if err := checkArgumentCount("type", args, 1); err != nil {
+		return err
+	}
+	return &obj.String{Value: typeName(args[0])}
+}
+
+/////////////
+// Lengths //
+/////////////
+
+// len(value) returns the number of characters of a string, elements of an
+// array, pairs of a hash or numbers of a range, as their len methods do
+func builtinLen(args ...obj.Object) obj.Object {
+	if err := checkArgumentCount("len", args, 1); err != nil {
+		return err
+	}
+
+	switch val := args[0].(type) {
+	case *obj.String:
+		return &obj.Integer{Value: int64(utf8.RuneCountInString(val.Value))}
+	case *obj.Array:
+		return &obj.Integer{Value: int64(len(val.Elements))}
+	case *obj.Hash:
+		return &obj.Integer{Value: int64(len(val.Pairs))}
+	case *obj.Range:
+		return &obj.Integer{Value: val.Len()}
+	}
+	return newError(TYPE_ERROR, "argument to len must be %s, %s, %s or %s, got %s",
+		obj.STRING_OBJ, obj.ARRAY_OBJ, obj.HASH_OBJ, obj.RANGE_OBJ, args[0].Type())
+}
+
+/////////////
+// Results //
+/////////////
+
+// ok(value) makes a successful result
+func builtinOk(args ...obj.Object) obj.Object {
+	if err := checkArgumentCount("ok", args, 1); err != nil {
+		return err
+	}
+	return &obj.Result{Ok: true, Value: args[0]}
+}
+
+// err(error) makes a failed result
+func builtinErr(args ...obj.Object) obj.Object {
+	if err := checkArgumentCount("err", args, 1); err != nil {
+		return err
+	}
+	return &obj.Result{Ok: false, Value: args[0]}
+}
+
+// is_ok(result) checks if a result succeeded
+func builtinIsOk(args ...obj.Object) obj.Object {
+	if err := checkArgumentCount("is_ok", args, 1); err != nil {
+		return err
+	}
+
+	result, ok := args[0].(*obj.Result)
+	if !ok {
+		return newOErrorArgumentType("is_ok", obj.RESULT_OBJ, args[0])
+	}
+	return nativeBoolToBooleanObject(result.Ok)
+}
+
+// is_err(result) checks if a result failed
+func builtinIsErr(args ...obj.Object) obj.Object {
+	if err := checkArgumentCount("is_err", args, 1); err != nil {
+		return err
+	}
+
+	result, ok := args[0].(*obj.Result)
+	if !ok {
+		return newOErrorArgumentType("is_err", obj.RESULT_OBJ, args[0])
+	}
+	return nativeBoolToBooleanObject(!result.Ok)
+}
+
+// unwrap(result) returns the value of a successful result, and raises
+// the error of a failed one
+func builtinUnwrap(args ...obj.Object) obj.Object {
+	if err := checkArgumentCount("unwrap", args, 1); err != nil {
+		return err
+	}
+
+	result, ok := args[0].(*obj.Result)
+	if !ok {
+		return newOErrorArgumentType("unwrap", obj.RESULT_OBJ, args[0])
+	}
+
+	if !result.Ok {
+		return raiseResultError(result)
+	}
+	return result.Value
+}
+
+// Turns the value of an err result into a raised error
+func raiseResultError(result *obj.Result) *obj.Error {
+	switch val := result.Value.(type) {
+	case *obj.Exception:
+		return &obj.Error{Kind: val.Error.Kind, Message: val.Error.Message}
+	case *obj.String:
+		return newError(ERROR, "%s", val.Value)
+	default:
+		return newError(ERROR, "%s", val.Inspect())
+	}
+}
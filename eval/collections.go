@@ -0,0 +1,446 @@
+package eval
+
+import (
+	obj "mkc/object"
+	"sort"
+)
+
+//////////////////////////
+// Collection builtins //
+//////////////////////////
+
+// Like map and filter, these take the iterable first, so they can be piped
+// into. Those giving back a collection give arrays for arrays, and lazy
+// iterators for any other iterable. An error raised by a callback stops
+// them, and is raised again by them.
+func init() {
+	builtins["reduce"] = &obj.Builtin{Name: "reduce", Fn: builtinReduce}
+	builtins["each"] = &obj.Builtin{Name: "each", Fn: builtinEach}
+	optionBuiltins["any"] = builtinAny
+	optionBuiltins["all"] = builtinAll
+	optionBuiltins["find"] = builtinFind
+	builtins["zip"] = &obj.Builtin{Name: "zip", Fn: builtinZip}
+	builtins["enumerate"] = &obj.Builtin{Name: "enumerate", Fn: builtinEnumerate}
+	builtins["flat_map"] = &obj.Builtin{Name: "flat_map", Fn: builtinFlatMap}
+	builtins["group_by"] = &obj.Builtin{Name: "group_by", Fn: builtinGroupBy}
+	builtins["sort"] = &obj.Builtin{Name: "sort", Fn: builtinSort}
+	builtins["unique"] = &obj.Builtin{Name: "unique", Fn: builtinUnique}
+}
+
+// Calls visit with each element of an iterable value, until it returns
+// false or an error. The iterator is closed on return.
+func walk(o obj.Object, visit func(obj.Object) (bool, *obj.Error)) *obj.Error {
+	it, err := iterate(o)
+	if err != nil {
+		return err
+	}
+	defer obj.CloseIterator(it)
+
+	for {
+		val, ok := it.Next()
+		if !ok {
+			return nil
+		}
+		if err, ok := val.(*obj.Error); ok {
+			return err
+		}
+		if more, err := visit(val); err != nil || !more {
+			return err
+		}
+	}
+}
+
+// Applies a callback, separating out the error it may raise
+func callBack(fn obj.Object, args ...obj.Object) (obj.Object, *obj.Error) {
+	result := applyFunction(fn, args)
+	if err, ok := result.(*obj.Error); ok {
+		return nil, err
+	}
+	return result, nil
+}
+
+// reduce(iterable, fn, init) combines the elements from the left with
+// fn(acc, element). Without init, the first element starts.
+func builtinReduce(args ...obj.Object) obj.Object {
+	if err := checkArgumentRange("reduce", args, 2, 3); err != nil {
+		return err
+	}
+
+	fn := args[1]
+	started := len(args) == 3
+	var acc obj.Object
+	if started {
+		acc = args[2]
+	}
+
+	err := walk(args[0], func(val obj.Object) (bool, *obj.Error) {
+		if !started {
+			started, acc = true, val
+			return true, nil
+		}
+		var err *obj.Error
+		acc, err = callBack(fn, acc, val)
+		return true, err
+	})
+	if err != nil {
+		return err
+	}
+
+	if !started {
+		return newError(TYPE_ERROR, "reduce of an empty iterable without an initial value")
+	}
+	return acc
+}
+
+// each(iterable, fn) calls fn with each element, and returns null
+func builtinEach(args ...obj.Object) obj.Object {
+	if err := checkArgumentCount("each", args, 2); err != nil {
+		return err
+	}
+
+	err := walk(args[0], func(val obj.Object) (bool, *obj.Error) {
+		_, err := callBack(args[1], val)
+		return true, err
+	})
+	if err != nil {
+		return err
+	}
+	return ONULL
+}
+
+// Returns if fn holds for an element, or the element does without fn,
+// as a condition under the interpreter's options
+func holds(options *obj.Options, args []obj.Object, val obj.Object) (bool, *obj.Error) {
+	if len(args) == 1 {
+		return checkCondition(val, options)
+	}
+	result, err := callBack(args[1], val)
+	if err != nil {
+		return false, err
+	}
+	return checkCondition(result, options)
+}
+
+// any(iterable, fn) checks if fn holds for some element, stopping at the
+// first one. Without fn, elements are checked for truthiness.
+func builtinAny(options *obj.Options, args ...obj.Object) obj.Object {
+	if err := checkArgumentRange("any", args, 1, 2); err != nil {
+		return err
+	}
+
+	found := false
+	err := walk(args[0], func(val obj.Object) (bool, *obj.Error) {
+		ok, err := holds(options, args, val)
+		found = ok
+		return !ok, err
+	})
+	if err != nil {
+		return err
+	}
+	return nativeBoolToBooleanObject(found)
+}
+
+// all(iterable, fn) checks if fn holds for every element, stopping at the
+// first one it does not. Without fn, elements are checked for truthiness.
+func builtinAll(options *obj.Options, args ...obj.Object) obj.Object {
+	if err := checkArgumentRange("all", args, 1, 2); err != nil {
+		return err
+	}
+
+	every := true
+	err := walk(args[0], func(val obj.Object) (bool, *obj.Error) {
+		ok, err := holds(options, args, val)
+		every = ok
+		return ok, err
+	})
+	if err != nil {
+		return err
+	}
+	return nativeBoolToBooleanObject(every)
+}
+
+// find(iterable, fn) returns the first element for which fn holds, or null
+func builtinFind(options *obj.Options, args ...obj.Object) obj.Object {
+	if err := checkArgumentCount("find", args, 2); err != nil {
+		return err
+	}
+
+	var found obj.Object = ONULL
+	err := walk(args[0], func(val obj.Object) (bool, *obj.Error) {
+		ok, err := holds(options, args, val)
+		if ok {
+			found = val
+		}
+		return !ok, err
+	})
+	if err != nil {
+		return err
+	}
+	return found
+}
+
+// zip(a, b, ...) pairs up the elements of iterables, as an array of each
+// element of every one, until the shortest ends. All arrays give an array.
+func builtinZip(args ...obj.Object) obj.Object {
+	if len(args) < 2 {
+		return newError(ARGUMENT_ERROR, "wrong number of arguments to zip: want=at least 2, got=%d", len(args))
+	}
+
+	zipped := &zipIterator{}
+	arrays := true
+	for _, arg := range args {
+		it, err := iterate(arg)
+		if err != nil {
+			zipped.Close()
+			return err
+		}
+		zipped.sources = append(zipped.sources, it)
+		arrays = arrays && arg.Type() == obj.ARRAY_OBJ
+	}
+
+	if !arrays {
+		return &obj.Sequence{Source: zipped}
+	}
+	return collectIfArray(args[0], zipped)
+}
+
+// Iterates over several iterators at once
+type zipIterator struct {
+	sources []obj.Iterator
+}
+
+func (it *zipIterator) Next() (obj.Object, bool) {
+	elements := make([]obj.Object, len(it.sources))
+	for i, source := range it.sources {
+		val, ok := source.Next()
+		if !ok || isError(val) {
+			return val, ok
+		}
+		elements[i] = val
+	}
+	return &obj.Array{Elements: elements}, true
+}
+
+func (it *zipIterator) Close() {
+	for _, source := range it.sources {
+		obj.CloseIterator(source)
+	}
+}
+
+// enumerate(iterable) pairs each element with its position, as [i, element]
+func builtinEnumerate(args ...obj.Object) obj.Object {
+	if err := checkArgumentCount("enumerate", args, 1); err != nil {
+		return err
+	}
+
+	it, err := iterate(args[0])
+	if err != nil {
+		return err
+	}
+
+	var i int64
+	enumerated := &derivedIterator{source: it, next: func() (obj.Object, bool) {
+		val, ok := it.Next()
+		if !ok || isError(val) {
+			return val, ok
+		}
+		pair := &obj.Array{Elements: []obj.Object{&obj.Integer{Value: i}, val}}
+		i += 1
+		return pair, true
+	}}
+
+	return collectIfArray(args[0], enumerated)
+}
+
+// flat_map(iterable, fn) applies fn to each element, and gives the elements
+// of the iterables it returns, one after the other
+func builtinFlatMap(args ...obj.Object) obj.Object {
+	if err := checkArgumentCount("flat_map", args, 2); err != nil {
+		return err
+	}
+
+	it, err := iterate(args[0])
+	if err != nil {
+		return err
+	}
+
+	return collectIfArray(args[0], &flatMapIterator{source: it, fn: args[1]})
+}
+
+// Iterates over the iterables fn returns for the elements of source
+type flatMapIterator struct {
+	source obj.Iterator
+	fn     obj.Object
+	inner  obj.Iterator
+}
+
+func (it *flatMapIterator) Next() (obj.Object, bool) {
+	for {
+		if it.inner != nil {
+			val, ok := it.inner.Next()
+			if ok {
+				return val, true
+			}
+			it.inner = nil
+		}
+
+		val, ok := it.source.Next()
+		if !ok || isError(val) {
+			return val, ok
+		}
+
+		result, err := callBack(it.fn, val)
+		if err != nil {
+			return err, true
+		}
+		inner, err := iterate(result)
+		if err != nil {
+			return err, true
+		}
+		it.inner = inner
+	}
+}
+
+func (it *flatMapIterator) Close() {
+	if it.inner != nil {
+		obj.CloseIterator(it.inner)
+	}
+	obj.CloseIterator(it.source)
+}
+
+// group_by(iterable, fn) returns a hash from each key fn returns to the
+// array of elements it returned that key for, in order
+func builtinGroupBy(args ...obj.Object) obj.Object {
+	if err := checkArgumentCount("group_by", args, 2); err != nil {
+		return err
+	}
+
+	groups := obj.NewHash()
+	err := walk(args[0], func(val obj.Object) (bool, *obj.Error) {
+		result, err := callBack(args[1], val)
+		if err != nil {
+			return false, err
+		}
+		key, ok := result.(obj.Hashable)
+		if !ok {
+			return false, newOHashKeyError(result)
+		}
+
+		if group, ok := groups.Get(key); ok {
+			array := group.(*obj.Array)
+			array.Elements = append(array.Elements, val)
+		} else {
+			groups.Set(key, &obj.Array{Elements: []obj.Object{val}})
+		}
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+	return groups
+}
+
+// sort(iterable, less) returns a new array of the elements, ordered so that
+// less(a, b) holds for a before b, and less must return a boolean. Without
+// less, numbers and strings are sorted ascending. Equal elements keep their
+// order.
+func builtinSort(args ...obj.Object) obj.Object {
+	if err := checkArgumentRange("sort", args, 1, 2); err != nil {
+		return err
+	}
+
+	elements, err := appendAll([]obj.Object{}, args[0])
+	if err != nil {
+		return err
+	}
+
+	less := naturalLess
+	if len(args) == 2 {
+		less = func(a, b obj.Object) (bool, *obj.Error) {
+			result, err := callBack(args[1], a, b)
+			if err != nil {
+				return false, err
+			}
+			// a three-way comparison such as b - a would sort wrongly
+			boolean, ok := result.(*obj.Boolean)
+			if !ok {
+				return false, newError(TYPE_ERROR, "less of sort must return BOOLEAN, got %s", result.Type())
+			}
+			return boolean.Value, nil
+		}
+	}
+
+	// the first error stops comparing, the order is dropped anyway
+	var failed *obj.Error
+	sort.SliceStable(elements, func(i, j int) bool {
+		if failed != nil {
+			return false
+		}
+		ok, err := less(elements[i], elements[j])
+		failed = err
+		return ok
+	})
+	if failed != nil {
+		return failed
+	}
+
+	return &obj.Array{Elements: elements}
+}
+
+// Orders numbers and strings, the values with a natural order
+func naturalLess(a obj.Object, b obj.Object) (bool, *obj.Error) {
+	if isNumber(a) && isNumber(b) {
+		return compareNumbers(a, b) < 0, nil
+	}
+	switch a := a.(type) {
+	case *obj.String:
+		if b, ok := b.(*obj.String); ok {
+			return a.Value < b.Value, nil
+		}
+	}
+	return false, newError(TYPE_ERROR, "cannot compare %s and %s", a.Type(), b.Type())
+}
+
+// unique(iterable) drops the elements equal to an earlier one
+func builtinUnique(args ...obj.Object) obj.Object {
+	if err := checkArgumentCount("unique", args, 1); err != nil {
+		return err
+	}
+
+	it, err := iterate(args[0])
+	if err != nil {
+		return err
+	}
+
+	// hashable elements are looked up as keys of a hash, which tells apart
+	// those of the same HashKey, and others are compared one by one
+	seen := obj.NewHash()
+	var others []obj.Object
+	isNew := func(val obj.Object) bool {
+		if key, ok := val.(obj.Hashable); ok {
+			if _, ok := seen.Get(key); ok {
+				return false
+			}
+			seen.Set(key, OTRUE)
+			return true
+		}
+		for _, other := range others {
+			if other.Equals(val) {
+				return false
+			}
+		}
+		others = append(others, val)
+		return true
+	}
+
+	deduplicated := &derivedIterator{source: it, next: func() (obj.Object, bool) {
+		for {
+			val, ok := it.Next()
+			if !ok || isError(val) || isNew(val) {
+				return val, ok
+			}
+		}
+	}}
+
+	return collectIfArray(args[0], deduplicated)
+}
@@ -0,0 +1,52 @@
+package eval
+
+import (
+	"mkc/ast"
+	obj "mkc/object"
+)
+
+///////////
+// Enums //
+///////////
+
+// Returns the type an enum declaration binds, hoisted like functions
+func newEnumType(es *ast.EnumStatement) *obj.EnumType {
+	et := &obj.EnumType{Name: es.Name.Value}
+	for _, v := range es.Variants {
+		variant := &obj.Variant{Enum: et, Name: v.Name.Value}
+		if v.Fields == nil {
+			variant.Unit = &obj.EnumValue{Variant: variant}
+		} else {
+			variant.Fields = make([]string, len(v.Fields))
+			for i, field := range v.Fields {
+				variant.Fields[i] = field.Value
+			}
+		}
+		et.Variants = append(et.Variants, variant)
+	}
+	return et
+}
+
+// Calling a variant makes a value, with the fields in declared order
+func instantiateVariant(v *obj.Variant, args []obj.Object) obj.Object {
+	if len(args) != len(v.Fields) {
+		return newError(ARGUMENT_ERROR, "wrong number of arguments to %s: want=%d, got=%d",
+			v.Inspect(), len(v.Fields), len(args))
+	}
+
+	values := make([]obj.Object, len(args))
+	copy(values, args)
+	return &obj.EnumValue{Variant: v, Values: values}
+}
+
+// Returns Enum.Variant, the variant itself if it has fields, else its only value
+func enumVariant(et *obj.EnumType, name string) (obj.Object, bool) {
+	v := et.Variant(name)
+	if v == nil {
+		return nil, false
+	}
+	if v.Unit != nil {
+		return v.Unit, true
+	}
+	return v, true
+}
@@ -2,33 +2,174 @@ package eval
 
 import (
 	"fmt"
+	"mkc/ast"
 	obj "mkc/object"
+	"strings"
 )
 
-func newError(format string, a ...interface{}) *obj.Error {
-	return &obj.Error{Message: fmt.Sprintf(format, a...)}
+// Kinds of errors, visible to scripts as the kind of a caught error
+const (
+	ERROR            = "Error" // thrown by scripts
+	TYPE_ERROR       = "TypeError"
+	NAME_ERROR       = "NameError"
+	ARGUMENT_ERROR   = "ArgumentError"
+	ARITHMETIC_ERROR = "ArithmeticError"
+	DOMAIN_ERROR     = "DomainError" // math on a number it is not defined for
+	BINDING_ERROR    = "BindingError"
+	MATCH_ERROR      = "MatchError"
+	IMPORT_ERROR     = "ImportError"
+	JSON_ERROR       = "JSONError"
+	PERMISSION_ERROR = "PermissionError" // file access the host did not grant
+	IO_ERROR         = "IOError"
+)
+
+func newError(kind string, format string, a ...interface{}) *obj.Error {
+	return &obj.Error{Kind: kind, Message: fmt.Sprintf(format, a...)}
 }
 
 func newOErrorUnknownPrefixOp(operator string, right obj.Object) *obj.Error {
-	return newError("unknown operator: %s%s", operator, right.Type())
+	return newError(TYPE_ERROR, "unknown operator: %s%s", operator, right.Type())
 }
 
 func newOErrorUnknownInfixOp(left obj.Object, operator string, right obj.Object) *obj.Error {
-	return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	return newError(TYPE_ERROR, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
 }
 
 func newOErrorTypeMismatch(left obj.Object, operator string, right obj.Object) *obj.Error {
-	return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	return newError(TYPE_ERROR, "type mismatch: %s %s %s", left.Type(), operator, right.Type())
 }
 
 func newOErrorInvalidOperand(operator string, right obj.Object) *obj.Error {
-	return newError("invalid operand: %s%s", operator, right.Type())
+	return newError(TYPE_ERROR, "invalid operand: %s%s", operator, right.Type())
+}
+
+func newOErrorInvalidPostfixOperand(operator string, left obj.Object) *obj.Error {
+	return newError(TYPE_ERROR, "invalid operand: %s%s", left.Type(), operator)
+}
+
+func newOErrorArgumentType(function string, want obj.ObjectType, got obj.Object) *obj.Error {
+	return newError(TYPE_ERROR, "argument to %s must be %s, got %s", function, want, got.Type())
+}
+
+func newOErrorNumberArgument(function string, got obj.Object) *obj.Error {
+	return newError(TYPE_ERROR, "argument to %s must be a number, got %s", function, got.Type())
+}
+
+func newOErrorDivisionByZero() *obj.Error {
+	return newError(ARITHMETIC_ERROR, "division by zero")
+}
+
+func newODomainError(expression string) *obj.Error {
+	return newError(DOMAIN_ERROR, "%s is undefined", expression)
+}
+
+func newOPermissionError(capability string, name string) *obj.Error {
+	return newError(PERMISSION_ERROR, "no %s capability for %s", capability, name)
+}
+
+func newOConditionError(condition obj.Object) *obj.Error {
+	return newError(TYPE_ERROR, "condition must be %s, got %s", obj.BOOLEAN_OBJ, condition.Type())
+}
+
+func newOFieldError(o obj.Object, name string) *obj.Error {
+	switch o := o.(type) {
+	case *obj.EnumType:
+		return newError(NAME_ERROR, "%s has no variant %s", o.Name, name)
+	case *obj.Module:
+		return newError(NAME_ERROR, "module %s does not export %s", o.Name, name)
+	}
+	return newError(NAME_ERROR, "%s has no field %s", typeName(o), name)
+}
+
+func newOMethodError(o obj.Object, name string) *obj.Error {
+	switch o.(type) {
+	case *obj.EnumType, *obj.Module:
+		return newOFieldError(o, name)
+	}
+	return newError(NAME_ERROR, "%s has no method %s", typeName(o), name)
+}
+
+func newOMethodArgumentCountError(receiver obj.Object, name string, want int, got int) *obj.Error {
+	return newError(ARGUMENT_ERROR, "wrong number of arguments to %s.%s: want=%d, got=%d",
+		typeName(receiver), name, want, got)
+}
+
+func newOImmutableError(o obj.Object, name string, reason string) *obj.Error {
+	return newError(TYPE_ERROR, "cannot assign to %s of %s, %s", name, o.Inspect(), reason)
+}
+
+func newOImportCycleError(cycle []*obj.Module) *obj.Error {
+	names := make([]string, len(cycle))
+	for i, module := range cycle {
+		names[i] = module.Name
+	}
+	return newError(IMPORT_ERROR, "import cycle: %s", strings.Join(names, " -> "))
+}
+
+func newOYieldError() *obj.Error {
+	return newError(TYPE_ERROR, "yield outside of a generator")
+}
+
+func newONotIterableError(o obj.Object) *obj.Error {
+	return newError(TYPE_ERROR, "cannot iterate over %s", o.Type())
 }
 
 func newOIdentifierError(ident string) *obj.Error {
-	return newError("identifier not found: %s", ident)
+	return newError(NAME_ERROR, "identifier not found: %s", ident)
 }
 
 func newOFunctionError(function obj.Object) *obj.Error {
-	return newError("not a function: %s", function.Type())
+	return newError(TYPE_ERROR, "not a function: %s", function.Type())
+}
+
+func newOArgumentCountError(function *obj.Function, got int) *obj.Error {
+	return newError(ARGUMENT_ERROR, "wrong number of arguments to %s: want=%d, got=%d",
+		functionName(function), len(function.Parameters), got)
+}
+
+func newORedeclarationError(name string, previous obj.Binding) *obj.Error {
+	if previous.Const {
+		return newError(BINDING_ERROR, "cannot redeclare constant %s, declared at %s", name, previous.Token.Position())
+	}
+	return newError(BINDING_ERROR, "cannot redeclare %s, declared at %s", name, previous.Token.Position())
+}
+
+func newOConstAssignError(name string, binding obj.Binding) *obj.Error {
+	return newError(BINDING_ERROR, "cannot assign to constant %s, declared at %s", name, binding.Token.Position())
+}
+
+func newOIndexError(left obj.Object, index obj.Object) *obj.Error {
+	return newError(TYPE_ERROR, "index operator not supported: %s[%s]", left.Type(), index.Type())
+}
+
+func newOHashKeyError(key obj.Object) *obj.Error {
+	return newError(TYPE_ERROR, "unusable as hash key: %s", key.Type())
+}
+
+func newONoMatchError(subject obj.Object, position string) *obj.Error {
+	return newError(MATCH_ERROR, "no match arm for %s at %s", subject.Inspect(), position)
+}
+
+func newODestructureError(pattern ast.Pattern, mismatch string) *obj.Error {
+	return newError(MATCH_ERROR, "cannot destructure %s at %s: %s",
+		pattern.String(), ast.PatternToken(pattern).Position(), mismatch)
+}
+
+// Returns a name for a function to use in messages
+func functionName(fnObj obj.Object) string {
+	switch function := fnObj.(type) {
+	case *obj.Builtin:
+		return function.Name
+	case *obj.StructType:
+		return function.Name
+	case *obj.Variant:
+		return function.Inspect()
+	case *obj.BoundMethod:
+		return functionName(function.Method)
+	case *obj.Function:
+		if function.Name != "" {
+			return function.Name
+		}
+	}
+	return "anonymous function"
 }
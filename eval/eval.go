@@ -2,8 +2,11 @@ package eval
 
 import (
 	"fmt"
+	"math"
+	"math/big"
 	"mkc/ast"
 	obj "mkc/object"
+	tk "mkc/token"
 )
 
 // Fixed values
@@ -29,27 +32,72 @@ func Eval(node ast.Node, env *obj.Environment) obj.Object {
 		return Eval(node.Expression, env)
 
 	case *ast.LetStatement:
-		val := Eval(node.Value, env)
-		if isError(val) { return val }
-		env.Set(node.Name.Value, val)
+		return evalLetStatement(node, env)
+
+	case *ast.FunctionStatement, *ast.StructStatement, *ast.EnumStatement:
+		// already bound by hoistFunctions when the block was entered
+
+	case *ast.ImplStatement:
+		return evalImplStatement(node, env)
+
+	case *ast.ImportStatement:
+		return evalImportStatement(node, env)
+
+	case *ast.ExportStatement:
+		return Eval(node.Declaration, env)
+
+	case *ast.ForStatement:
+		return evalForStatement(node, env)
+
+	case *ast.YieldStatement:
+		return evalYieldStatement(node, env)
 
 	// >> Expressions
 
 	// data types
 	case *ast.IntegerLiteral:
+		if node.Big != nil {
+			return &obj.BigInt{Value: node.Big}
+		}
 		return &obj.Integer{Value: node.Value}
 
+	case *ast.FloatLiteral:
+		return &obj.Float{Value: node.Value}
+
 	case *ast.BooleanLiteral:
 		return nativeBoolToBooleanObject(node.Value)
 
+	case *ast.StringLiteral:
+		return &obj.String{Value: node.Value}
+
+	case *ast.NullLiteral:
+		return ONULL
+
+	case *ast.ArrayLiteral:
+		elements := evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isError(elements[0]) { return elements[0] }
+		return &obj.Array{Elements: elements}
+
+	case *ast.HashLiteral:
+		return evalHashLiteral(node, env)
+
 	// expressions
 	case *ast.PrefixExpression:
 		right := Eval(node.Right, env)
-		return evalPrefixExpression(node.Operator, right)
+		if isError(right) { return right }
+		return evalPrefixExpression(node.Operator, right, env)
+
+	case *ast.PostfixExpression:
+		left := Eval(node.Left, env)
+		if isError(left) { return left }
+		return evalPostfixExpression(node.Operator, left)
 
 	case *ast.InfixExpression:
 		left := Eval(node.Left, env)
 		if isError(left) { return left }
+		if isLogicalOperator(node.Operator) {
+			return evalLogicalExpression(node, left, env)
+		}
 		right := Eval(node.Right, env)
 		if isError(right) { return right }
 		return evalInfixExpression(node.Operator, left, right)
@@ -59,10 +107,19 @@ func Eval(node ast.Node, env *obj.Environment) obj.Object {
 		if isError(val) { return val }
 		return &obj.ReturnValue{Value: val}
 
+	case *ast.ThrowStatement:
+		return evalThrowStatement(node, env)
+
 	// identifiers
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 
+	case *ast.AssignExpression:
+		return evalAssignExpression(node, env)
+
+	case *ast.MemberAssignExpression:
+		return evalMemberAssignExpression(node, env)
+
 	// block constructs
 	case *ast.BlockStatement:
 		return evalBlockStatement(node, env)
@@ -70,10 +127,17 @@ func Eval(node ast.Node, env *obj.Environment) obj.Object {
 	case *ast.IfExpression:
 		return evalIfExpression(node, env)
 
+	case *ast.ConditionalExpression:
+		return evalConditionalExpression(node, env)
+
+	case *ast.TryExpression:
+		return evalTryExpression(node, env)
+
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, env)
+
 	case *ast.FunctionLiteral:
-		body := node.Body
-		params := node.Parameters
-		return &obj.Function{Body: body, Parameters: params, Env: env}
+		return newFunction(node, env)
 
 	case *ast.CallExpression:
 		function := Eval(node.Function, env)
@@ -82,7 +146,20 @@ func Eval(node ast.Node, env *obj.Environment) obj.Object {
 		args := evalExpressions(node.Arguments, env)
 		if len(args) == 1 && isError(args[0]) { return args[0] }
 
-		return applyFunction(function, args)
+		return addStackFrame(applyFunction(function, args), function, node.Token)
+
+	case *ast.MethodCallExpression:
+		return evalMethodCallExpression(node, env)
+
+	case *ast.IndexExpression:
+		left := Eval(node.Left, env)
+		if isError(left) { return left }
+		index := Eval(node.Index, env)
+		if isError(index) { return index }
+		return evalIndexExpression(left, index)
+
+	case *ast.MemberExpression:
+		return evalMemberExpression(node, env)
 
 
 	// --- end evaluating ---
@@ -97,12 +174,14 @@ func Eval(node ast.Node, env *obj.Environment) obj.Object {
 // Utilities //
 ///////////////
 
-// Checks if its an error object
+// Checks if its an error object, or an err result leaving its function
+// through ?. Both unwind evaluation until caught or returned.
 func isError(o obj.Object) bool {
 	if o == nil {
 		return false
 	}
-	return o.Type() == obj.ERROR_OBJ
+	t := o.Type()
+	return t == obj.ERROR_OBJ || t == obj.EARLY_RETURN_OBJ
 }
 
 // Returns the same address of boolean object
@@ -120,6 +199,10 @@ func nativeBoolToBooleanObject(input bool) *obj.Boolean {
 
 // Evaluates complete program
 func evalProgram(program *ast.Program, env *obj.Environment) obj.Object {
+	if err := hoistFunctions(program.Statements, env); err != nil {
+		return err
+	}
+
 	var result obj.Object
 	for _, statement := range program.Statements {
 		result = Eval(statement, env)
@@ -127,6 +210,8 @@ func evalProgram(program *ast.Program, env *obj.Environment) obj.Object {
 		switch result := result.(type) {
 		case *obj.ReturnValue:
 			return result.Value
+		case *obj.EarlyReturn:
+			return result.Value
 		case *obj.Error:
 			return result
 		}
@@ -136,13 +221,24 @@ func evalProgram(program *ast.Program, env *obj.Environment) obj.Object {
 
 // Loops over all statements
 func evalBlockStatement(block *ast.BlockStatement, env *obj.Environment) obj.Object {
+	env.BeginBlock()
+	result := evalStatements(block.Statements, env)
+	env.EndBlock()
+	return result
+}
+
+// Evaluates statements in order, stopping at a return or an error
+func evalStatements(statements []ast.Statement, env *obj.Environment) obj.Object {
+	if err := hoistFunctions(statements, env); err != nil {
+		return err
+	}
+
 	var result obj.Object
-	for _, statement := range block.Statements {
+	for _, statement := range statements {
 		result = Eval(statement, env)
 
 		if result != nil  {
-			rt := result.Type()
-			if rt == obj.RETURN_OBJ || rt == obj.ERROR_OBJ {
+			if result.Type() == obj.RETURN_OBJ || isError(result) {
 				return result
 			}
 		}
@@ -150,11 +246,92 @@ func evalBlockStatement(block *ast.BlockStatement, env *obj.Environment) obj.Obj
 	return result
 }
 
+// Binds every function, struct and enum declaration of a block before any
+// statement runs, so declarations can use each other regardless of their order
+func hoistFunctions(statements []ast.Statement, env *obj.Environment) *obj.Error {
+	for _, statement := range statements {
+		var name *ast.Identifier
+		var val obj.Object
+
+		switch s := ast.Unexported(statement).(type) {
+		case *ast.FunctionStatement:
+			name, val = s.Name, newFunction(s.Function, env)
+		case *ast.StructStatement:
+			name, val = s.Name, newStructType(s)
+		case *ast.EnumStatement:
+			name, val = s.Name, newEnumType(s)
+		default:
+			continue
+		}
+
+		if err := checkRedeclaration(name, false, env); err != nil {
+			return err
+		}
+
+		declare(env, name, val, obj.Binding{Token: name.Token})
+	}
+	return nil
+}
+
+// Binds a let or const name in the current scope
+func evalLetStatement(ls *ast.LetStatement, env *obj.Environment) obj.Object {
+	for _, name := range ls.Names() {
+		if err := checkRedeclaration(name, ls.IsConst(), env); err != nil {
+			return err
+		}
+	}
+
+	val := Eval(ls.Value, env)
+	if isError(val) { return val }
+
+	if ls.Pattern != nil {
+		if err := bindPattern(ls.Pattern, val, env, ls.IsConst()); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	binding := obj.Binding{Token: ls.Name.Token, Const: ls.IsConst()}
+	declare(env, ls.Name, val, binding)
+
+	return nil
+}
+
+// Binds a name in the current scope, in its slot if it was resolved
+func declare(env *obj.Environment, name *ast.Identifier, val obj.Object, binding obj.Binding) {
+	if name.Resolved {
+		env.SetSlot(0, name.Slot, val)
+		return
+	}
+	env.Declare(name.Value, val, binding)
+}
+
+// Errors if name may not be declared again in the current scope.
+// Constants can never be redeclared, lets only outside strict mode, but a
+// declaration in a block that has ended does not count, as in the resolver.
+func checkRedeclaration(name *ast.Identifier, constant bool, env *obj.Environment) *obj.Error {
+	// the resolver only gives slots to names declared once
+	if name.Resolved {
+		return nil
+	}
+
+	previous, ok := env.Declaration(name.Value)
+	if !ok || !env.Active(previous) {
+		return nil
+	}
+
+	if previous.Const || constant || env.Options().StrictLet {
+		return newORedeclarationError(name.Value, previous)
+	}
+
+	return nil
+}
+
 // Matches operator with required function call
-func evalPrefixExpression(operator string, right obj.Object) obj.Object {
+func evalPrefixExpression(operator string, right obj.Object, env *obj.Environment) obj.Object {
 	switch operator {
 	case "!":
-		return evalBangOperatorExpression(right)
+		return evalBangOperatorExpression(right, env)
 	case "-":
 		return evalMinusPrefixOperatorExpression(right)
 	case "+":
@@ -169,36 +346,69 @@ func evalPrefixExpression(operator string, right obj.Object) obj.Object {
 //////////////////
 
 // Matches right object with supported data types
-func evalBangOperatorExpression(right obj.Object) obj.Object {
-	switch right {
-	case OTRUE:
-		return OFALSE
-	case OFALSE:
-		return OTRUE
-	default:
+func evalBangOperatorExpression(right obj.Object, env *obj.Environment) obj.Object {
+	if right.Type() != obj.BOOLEAN_OBJ && env.Options().StrictBool {
 		return newOErrorInvalidOperand("!", right)
 	}
+
+	return nativeBoolToBooleanObject(!obj.Truthy(right))
 }
 
 // Returns - of given right expression
 func evalMinusPrefixOperatorExpression(right obj.Object) obj.Object {
-	if right.Type() != obj.INTEGER_OBJ {
-		return newOErrorInvalidOperand("-", right)
+	switch right := right.(type) {
+	case *obj.Integer:
+		if right.Value == math.MinInt64 {
+			return newInteger(new(big.Int).Neg(toBig(right)))
+		}
+		return &obj.Integer{Value: -right.Value}
+
+	case *obj.Float:
+		return &obj.Float{Value: -right.Value}
+
+	case *obj.BigInt:
+		return newInteger(new(big.Int).Neg(right.Value))
 	}
 
-	value := - right.(*obj.Integer).Value
-	return &obj.Integer{Value: value}
+	return newOErrorInvalidOperand("-", right)
 }
 
 // Returns + of given right expression
 func evalPlusPrefixOperatorExpression(right obj.Object) obj.Object {
-	if right.Type() != obj.INTEGER_OBJ {
+	if !isNumber(right) {
 		return newOErrorInvalidOperand("+", right)
 	}
 
 	return right
 }
 
+///////////////////
+// Postfix Exprs //
+///////////////////
+
+// Matches operator with required function call
+func evalPostfixExpression(operator string, left obj.Object) obj.Object {
+	switch operator {
+	case "?":
+		return evalPropagateOperatorExpression(left)
+	default:
+		return newOErrorInvalidPostfixOperand(operator, left)
+	}
+}
+
+// Unwraps an ok result, and returns an err result from the function
+func evalPropagateOperatorExpression(left obj.Object) obj.Object {
+	result, ok := left.(*obj.Result)
+	if !ok {
+		return newOErrorInvalidPostfixOperand("?", left)
+	}
+
+	if !result.Ok {
+		return &obj.EarlyReturn{Value: result}
+	}
+	return result.Value
+}
+
 /////////////////
 // Infix Exprs //
 /////////////////
@@ -206,50 +416,106 @@ func evalPlusPrefixOperatorExpression(right obj.Object) obj.Object {
 // Passes infix expression to respective handlers
 func evalInfixExpression(operator string, left obj.Object, right obj.Object) obj.Object {
 	switch {
+	// equality is defined between any two values
+	case operator == "==":
+		return nativeBoolToBooleanObject(left.Equals(right))
+
+	case operator == "!=":
+		return nativeBoolToBooleanObject(!left.Equals(right))
+
 	case left.Type() == obj.INTEGER_OBJ && right.Type() == obj.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
 
-	case left.Type() != right.Type():
-		return newOErrorTypeMismatch(left, operator, right)
+	case isNumber(left) && isNumber(right):
+		return evalNumberInfixExpression(operator, left, right)
 
-	case operator == "==":
-		return nativeBoolToBooleanObject(left == right)
+	case left.Type() == obj.STRING_OBJ && right.Type() == obj.STRING_OBJ:
+		return evalStringInfixExpression(operator, left, right)
 
-	case operator == "!=":
-		return nativeBoolToBooleanObject(left != right)
+	case left.Type() != right.Type():
+		return newOErrorTypeMismatch(left, operator, right)
 
 	default:
 		return newOErrorUnknownInfixOp(left, operator, right)
 	}
 }
 
-// Evaluates an arithmetic operation
+func isLogicalOperator(operator string) bool {
+	return operator == "&&" || operator == "||" || operator == "??"
+}
+
+// Evaluates &&, || and ??, which only evaluate their right side when the
+// left one does not decide the result. They return the deciding operand,
+// so a || b is a when a is truthy and b otherwise.
+func evalLogicalExpression(ie *ast.InfixExpression, left obj.Object, env *obj.Environment) obj.Object {
+	switch ie.Operator {
+	case "??":
+		if left != ONULL { return left }
+
+	case "&&", "||":
+		holds, err := evalCondition(left, env)
+		if err != nil { return err }
+		if holds == (ie.Operator == "||") { return left }
+	}
+
+	right := Eval(ie.Right, env)
+	if isError(right) { return right }
+
+	if ie.Operator != "??" {
+		if _, err := evalCondition(right, env); err != nil { return err }
+	}
+
+	return right
+}
+
+// Evaluates an arithmetic operation. One that overflows is done again
+// on big integers.
 func evalIntegerInfixExpression(operator string, left obj.Object, right obj.Object) obj.Object {
 	lval := left.(*obj.Integer).Value
 	rval := right.(*obj.Integer).Value
 
 	switch operator {
 	case "+":
-		return &obj.Integer{Value: lval + rval}
+		if sum, ok := addIntegers(lval, rval); ok {
+			return &obj.Integer{Value: sum}
+		}
+		return evalBigInfixExpression(operator, left, right)
 
 	case "-":
-		return &obj.Integer{Value: lval - rval}
+		if difference, ok := subtractIntegers(lval, rval); ok {
+			return &obj.Integer{Value: difference}
+		}
+		return evalBigInfixExpression(operator, left, right)
 
 	case "*":
-		return &obj.Integer{Value: lval * rval}
+		if product, ok := multiplyIntegers(lval, rval); ok {
+			return &obj.Integer{Value: product}
+		}
+		return evalBigInfixExpression(operator, left, right)
 
 	case "/":
+		if rval == 0 {
+			return newOErrorDivisionByZero()
+		}
+		if lval == math.MinInt64 && rval == -1 {
+			return evalBigInfixExpression(operator, left, right)
+		}
 		return &obj.Integer{Value: lval / rval}
 
 	case "%":
+		if rval == 0 {
+			return newOErrorDivisionByZero()
+		}
 		return &obj.Integer{Value: lval % rval}
 
 	case "**":
-		res := int64(1)
-		for i := int64(0); i < rval; i++ {
-			res = res * lval
-		}
-		return &obj.Integer{Value: res}
+		return power(left, right)
+
+	case "..":
+		return &obj.Range{Start: lval, Stop: rval, Step: 1, Inclusive: true}
+
+	case "..<":
+		return &obj.Range{Start: lval, Stop: rval, Step: 1}
 
 	case "<":
 		return nativeBoolToBooleanObject(lval < rval)
@@ -263,27 +529,194 @@ func evalIntegerInfixExpression(operator string, left obj.Object, right obj.Obje
 	case ">=":
 		return nativeBoolToBooleanObject(lval >= rval)
 
-	case "==":
-		return nativeBoolToBooleanObject(lval == rval)
+	default:
+		return newOErrorUnknownInfixOp(left, operator, right)
+	}
+}
+
+// Evaluates concatenation and comparison of strings
+func evalStringInfixExpression(operator string, left obj.Object, right obj.Object) obj.Object {
+	lval := left.(*obj.String).Value
+	rval := right.(*obj.String).Value
 
-	case "!=":
-		return nativeBoolToBooleanObject(lval != rval)
+	switch operator {
+	case "+":
+		return &obj.String{Value: lval + rval}
 
 	default:
 		return newOErrorUnknownInfixOp(left, operator, right)
 	}
 }
 
+//////////////
+// Indexing //
+//////////////
+
+// Passes index expression to respective handlers
+func evalIndexExpression(left obj.Object, index obj.Object) obj.Object {
+	switch {
+	case left.Type() == obj.ARRAY_OBJ && index.Type() == obj.INTEGER_OBJ:
+		return evalArrayIndexExpression(left, index)
+
+	case left.Type() == obj.RANGE_OBJ && index.Type() == obj.INTEGER_OBJ:
+		return evalRangeIndexExpression(left, index)
+
+	case left.Type() == obj.HASH_OBJ:
+		return evalHashIndexExpression(left, index)
+
+	case left.Type() == obj.EXCEPTION_OBJ && index.Type() == obj.STRING_OBJ:
+		return evalExceptionIndexExpression(left, index)
+
+	default:
+		return newOIndexError(left, index)
+	}
+}
+
+// Returns element at index, or null when out of bounds
+func evalArrayIndexExpression(array obj.Object, index obj.Object) obj.Object {
+	elements := array.(*obj.Array).Elements
+	idx := index.(*obj.Integer).Value
+
+	if idx < 0 || idx >= int64(len(elements)) {
+		return ONULL
+	}
+
+	return elements[idx]
+}
+
+// Returns the number at index, or null when out of bounds
+func evalRangeIndexExpression(rng obj.Object, index obj.Object) obj.Object {
+	r := rng.(*obj.Range)
+	idx := index.(*obj.Integer).Value
+
+	if idx < 0 || idx >= r.Len() {
+		return ONULL
+	}
+
+	return &obj.Integer{Value: r.At(idx)}
+}
+
+// Returns value stored under index, or null when missing
+func evalHashIndexExpression(hash obj.Object, index obj.Object) obj.Object {
+	key, ok := index.(obj.Hashable)
+	if !ok {
+		return newOHashKeyError(index)
+	}
+
+	if val, ok := hash.(*obj.Hash).Get(key); ok {
+		return val
+	}
+
+	return ONULL
+}
+
+// Returns field of a caught error: message, kind or stack
+func evalExceptionIndexExpression(exception obj.Object, field obj.Object) obj.Object {
+	err := exception.(*obj.Exception).Error
+
+	switch field.(*obj.String).Value {
+	case "message":
+		return &obj.String{Value: err.Message}
+
+	case "kind":
+		return &obj.String{Value: err.Kind}
+
+	case "stack":
+		frames := make([]obj.Object, len(err.Stack))
+		for i, frame := range err.Stack {
+			frames[i] = &obj.String{Value: frame}
+		}
+		return &obj.Array{Elements: frames}
+
+	default:
+		return ONULL
+	}
+}
+
+////////////
+// Errors //
+////////////
+
+// Raises the thrown value as an error
+// Caught errors are raised again as they were, anything else becomes
+// the message of a new error
+func evalThrowStatement(ts *ast.ThrowStatement, env *obj.Environment) obj.Object {
+	val := Eval(ts.Value, env)
+	if isError(val) { return val }
+
+	switch val := val.(type) {
+	case *obj.Exception:
+		stack := append([]string{}, val.Error.Stack...)
+		return &obj.Error{Kind: val.Error.Kind, Message: val.Error.Message, Stack: stack}
+
+	case *obj.String:
+		return newError(ERROR, "%s", val.Value)
+
+	default:
+		return newError(ERROR, "%s", val.Inspect())
+	}
+}
+
+// Handles a try catch finally expression
+// Errors raised in the try block are bound to the catch parameter as an
+// exception value. The finally block always runs, and replaces the result
+// only if it raises an error or returns.
+func evalTryExpression(te *ast.TryExpression, env *obj.Environment) obj.Object {
+	result := Eval(te.Block, env)
+
+	if err, ok := result.(*obj.Error); ok && te.Catch != nil {
+		catchEnv := obj.NewSlottedEnvironment(env, te.CatchSlots)
+		binding := obj.Binding{Token: te.Param.Token}
+		declare(catchEnv, te.Param, &obj.Exception{Error: err}, binding)
+
+		result = Eval(te.Catch, catchEnv)
+	}
+
+	if te.Finally != nil {
+		final := Eval(te.Finally, env)
+		if final != nil {
+			if final.Type() == obj.RETURN_OBJ || isError(final) {
+				return final
+			}
+		}
+	}
+
+	return result
+}
+
 ////////////
 // Others //
 ////////////
 
+// Reports whether a condition holds, by obj.Truthy
+// In strict mode a condition must be a boolean.
+func evalCondition(condition obj.Object, env *obj.Environment) (bool, *obj.Error) {
+	return checkCondition(condition, env.Options())
+}
+
+// Reports whether a condition holds under the options of an interpreter,
+// for builtins that have no environment
+func checkCondition(condition obj.Object, options *obj.Options) (bool, *obj.Error) {
+	if boolean, ok := condition.(*obj.Boolean); ok {
+		return boolean.Value, nil
+	}
+
+	if options.StrictBool {
+		return false, newOConditionError(condition)
+	}
+
+	return obj.Truthy(condition), nil
+}
+
 // Handles an if else expression
 func evalIfExpression(ie *ast.IfExpression, env *obj.Environment) obj.Object {
 	condition := Eval(ie.Condition, env)
 	if isError(condition) { return condition }
 
-	if condition == OTRUE {
+	holds, err := evalCondition(condition, env)
+	if err != nil { return err }
+
+	if holds {
 		return Eval(ie.Consequence, env)
 	} else if ie.Alternative != nil {
 		return Eval(ie.Alternative, env)
@@ -292,21 +725,111 @@ func evalIfExpression(ie *ast.IfExpression, env *obj.Environment) obj.Object {
 	}
 }
 
+// Handles cond ? a : b, evaluating only the chosen branch
+func evalConditionalExpression(ce *ast.ConditionalExpression, env *obj.Environment) obj.Object {
+	condition := Eval(ce.Condition, env)
+	if isError(condition) { return condition }
+
+	holds, err := evalCondition(condition, env)
+	if err != nil { return err }
+
+	if holds {
+		return Eval(ce.Consequence, env)
+	}
+	return Eval(ce.Alternative, env)
+}
+
 // Returns identifier object from environment
 func evalIdentifier(ie *ast.Identifier, env *obj.Environment) obj.Object {
-	val, ok := env.Get(ie.Value)
-	if !ok {
+	if ie.Resolved {
+		if val := env.GetSlot(ie.Depth, ie.Slot); val != nil {
+			return val
+		}
 		return newOIdentifierError(ie.Value)
 	}
 
+	if val, ok := env.Get(ie.Value); ok {
+		return val
+	}
+
+	if builtin := lookupBuiltin(ie.Value, env); builtin != nil {
+		return builtin
+	}
+
+	return newOIdentifierError(ie.Value)
+}
+
+// Rebinds an existing name in the scope that declared it
+func evalAssignExpression(ae *ast.AssignExpression, env *obj.Environment) obj.Object {
+	name := ae.Name.Value
+
+	// resolved names are never constants
+	if ae.Name.Resolved {
+		if env.GetSlot(ae.Name.Depth, ae.Name.Slot) == nil {
+			return newOIdentifierError(name)
+		}
+
+		val := Eval(ae.Value, env)
+		if isError(val) { return val }
+
+		env.SetSlot(ae.Name.Depth, ae.Name.Slot, val)
+		return val
+	}
+
+	scope := env.Scope(name)
+	if scope == nil {
+		return newOIdentifierError(name)
+	}
+
+	if binding, ok := scope.Declaration(name); ok && binding.Const {
+		return newOConstAssignError(name, binding)
+	}
+
+	val := Eval(ae.Value, env)
+	if isError(val) { return val }
+
+	scope.Set(name, val)
 	return val
 }
 
-// Loops over multiple expressions
+// Builds a hash, evaluating keys and values in source order
+func evalHashLiteral(hl *ast.HashLiteral, env *obj.Environment) obj.Object {
+	hash := obj.NewHash()
+
+	for _, keyNode := range hl.Keys {
+		key := Eval(keyNode, env)
+		if isError(key) { return key }
+
+		hashKey, ok := key.(obj.Hashable)
+		if !ok {
+			return newOHashKeyError(key)
+		}
+
+		val := Eval(hl.Pairs[keyNode], env)
+		if isError(val) { return val }
+
+		hash.Set(hashKey, val)
+	}
+
+	return hash
+}
+
+// Loops over multiple expressions, ...iterable adds all its elements
 func evalExpressions(exps []ast.Expression, env *obj.Environment) []obj.Object {
 	var result []obj.Object
 
 	for _, e := range exps {
+		if se, ok := e.(*ast.SpreadExpression); ok {
+			iterable := Eval(se.Value, env)
+			if isError(iterable) { return []obj.Object{iterable} }
+
+			var err *obj.Error
+			if result, err = appendAll(result, iterable); err != nil {
+				return []obj.Object{err}
+			}
+			continue
+		}
+
 		evaluated := Eval(e, env)
 		if isError(evaluated) { return []obj.Object{evaluated} }
 		result = append(result, evaluated)
@@ -315,31 +838,94 @@ func evalExpressions(exps []ast.Expression, env *obj.Environment) []obj.Object {
 	return result
 }
 
+// Creates a closure over env
+func newFunction(fl *ast.FunctionLiteral, env *obj.Environment) *obj.Function {
+	return &obj.Function{
+		Name: fl.Name,
+		Parameters: fl.Parameters,
+		Patterns: fl.Patterns,
+		Body: fl.Body,
+		Slots: fl.Slots,
+		Generator: fl.Generator,
+		Env: env,
+	}
+}
+
 // Evaluates a function
 func applyFunction(fnObj obj.Object, args []obj.Object) obj.Object {
+	if builtin, ok := fnObj.(*obj.Builtin); ok {
+		return builtin.Fn(args...)
+	}
+
+	if st, ok := fnObj.(*obj.StructType); ok {
+		return instantiateStruct(st, args)
+	}
+
+	if v, ok := fnObj.(*obj.Variant); ok {
+		return instantiateVariant(v, args)
+	}
+
+	if bm, ok := fnObj.(*obj.BoundMethod); ok {
+		return callMethod(bm.Receiver, bm.Name, bm.Method, args)
+	}
+
 	function, ok := fnObj.(*obj.Function)
 	if !ok {
 		return newOFunctionError(fnObj)
 	}
 
-	extendedEnv := extendFunctionEnv(function, args)
-	evaluated := Eval(function.Body, extendedEnv)
-	return unwrapReturnValue(evaluated)
+	if len(args) != len(function.Parameters) {
+		return newOArgumentCountError(function, len(args))
+	}
+
+	extendedEnv, err := extendFunctionEnv(function, args)
+	if err != nil {
+		return err
+	}
+	if function.Generator {
+		return newGenerator(function, extendedEnv)
+	}
+	evaluated := unwrapReturnValue(Eval(function.Body, extendedEnv))
+	// a body that is empty or ends in a statement returns null
+	if evaluated == nil {
+		return ONULL
+	}
+	return evaluated
+}
+
+// Adds the frame of a call to the stack of an error it raised
+func addStackFrame(result obj.Object, function obj.Object, call tk.Token) obj.Object {
+	if err, ok := result.(*obj.Error); ok {
+		frame := fmt.Sprintf("%s at %s", functionName(function), call.Position())
+		err.Stack = append(err.Stack, frame)
+	}
+	return result
 }
 
 // Extends the env with function arguments and returns wrapped env
-func extendFunctionEnv(fn *obj.Function, args []obj.Object) *obj.Environment {
-	env := obj.NewEnclosedEnvironment(fn.Env)
+// Fails when an argument does not have the shape of its parameter pattern
+func extendFunctionEnv(fn *obj.Function, args []obj.Object) (*obj.Environment, *obj.Error) {
+	env := obj.NewSlottedEnvironment(fn.Env, fn.Slots)
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		if paramIdx < len(fn.Patterns) && fn.Patterns[paramIdx] != nil {
+			if err := bindPattern(fn.Patterns[paramIdx], args[paramIdx], env, false); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		declare(env, param, args[paramIdx], obj.Binding{Token: param.Token})
 	}
 
-	return env
+	return env, nil
 }
 
 // Unwraps the return value
+// An err result propagated by ? stops here too, at the function boundary
 func unwrapReturnValue(o obj.Object) obj.Object {
-	if returnValue, ok := o.(*obj.ReturnValue); ok {
+	switch returnValue := o.(type) {
+	case *obj.ReturnValue:
+		return returnValue.Value
+	case *obj.EarlyReturn:
 		return returnValue.Value
 	}
 
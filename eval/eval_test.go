@@ -1,10 +1,18 @@
 package eval
 
 import (
+	"io/ioutil"
+	"mkc/ast"
 	"mkc/lexer"
 	obj "mkc/object"
 	"mkc/parser"
+	"mkc/resolver"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 )
 
 ///////////////
@@ -12,6 +20,26 @@ import (
 ///////////////
 
 func runEval(t *testing.T, input string) obj.Object {
+	return runEvalWithOptions(t, input, &obj.Options{})
+}
+
+func runEvalWithOptions(t *testing.T, input string, options *obj.Options) obj.Object {
+	program := parseInput(t, input)
+	env := obj.NewEnvironmentWithOptions(options)
+
+	return Eval(program, env)
+}
+
+// Evaluates input as the source of file, whose directory imports are
+// found in
+func runEvalFile(t *testing.T, input string, file string, options *obj.Options) obj.Object {
+	program := parseInput(t, input)
+	env := obj.NewEnvironmentWithOptions(options)
+
+	return EvalFile(program, file, env)
+}
+
+func parseInput(t *testing.T, input string) *ast.Program {
 	l := lexer.New(input)
 	p := parser.New(l)
 
@@ -27,9 +55,7 @@ func runEval(t *testing.T, input string) obj.Object {
 		t.FailNow()
 	}
 
-	env := obj.NewEnvironment()
-
-	return Eval(program, env)
+	return program
 }
 
 func assertOInteger(t *testing.T, o obj.Object, expected int64) bool {
@@ -263,6 +289,54 @@ func TestErrorHandling(t *testing.T) {
 			"foobarhoohaa",
 			"identifier not found: foobarhoohaa",
 		},
+		{
+			"fn add(x, y) { x + y }; add(1);",
+			"wrong number of arguments to add: want=2, got=1",
+		},
+		{
+			"fn(x) { x }(1, 2);",
+			"wrong number of arguments to anonymous function: want=1, got=2",
+		},
+		{
+			"1 / 0",
+			"division by zero",
+		},
+		{
+			"5 % (2 - 2)",
+			"division by zero",
+		},
+		{
+			`"a" - "b"`,
+			"unknown operator: STRING - STRING",
+		},
+		{
+			"1[0]",
+			"index operator not supported: INTEGER[INTEGER]",
+		},
+		{
+			`throw "bad record";`,
+			"bad record",
+		},
+		{
+			"-x",
+			"identifier not found: x",
+		},
+		{
+			"5?",
+			"invalid operand: INTEGER?",
+		},
+		{
+			"ok(1, 2)",
+			"wrong number of arguments to ok: want=1, got=2",
+		},
+		{
+			"is_ok(1)",
+			"argument to is_ok must be RESULT, got INTEGER",
+		},
+		{
+			`unwrap(err("no such record"))`,
+			"no such record",
+		},
 	}
 
 	for _, tt := range tests {
@@ -355,6 +429,26 @@ func TestFunctionApplication(t *testing.T) {
 	}
 }
 
+func TestFunctionWithoutValue(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn() { let x = 1; }; [f()]", "[null]"},
+		{"let f = fn() { let x = 1; }; f() == 1", "false"},
+		{"let f = fn() {}; f()", "null"},
+		{"map([1, 2], fn(x) { let y = x; })", "[null, null]"},
+		{"filter([1, 2], fn(x) { let y = x; })", "[]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := runEval(t, tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("%q - expected=%q, got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
 func TestClosures(t *testing.T) {
 	input := `
 		let newAdder = fn(x) {
@@ -366,3 +460,1543 @@ func TestClosures(t *testing.T) {
 	evaluated := runEval(t, input)
 	assertOInteger(t, evaluated, 4)
 }
+
+func TestFunctionStatements(t *testing.T) {
+	tests := []struct {
+		input string
+		expected int64
+	}{
+		{"fn double(x) { x * 2 }; double(4);", 8},
+		{"let r = double(4); fn double(x) { x * 2 }; r;", 8},
+		{`
+			fn fact(n) { if (n <= 1) { return 1; } n * fact(n - 1) }
+			fact(5);
+		`, 120},
+		{`
+			fn isEven(n) { if (n == 0) { true } else { isOdd(n - 1) } }
+			fn isOdd(n) { if (n == 0) { false } else { isEven(n - 1) } }
+			if (isEven(10)) { 1 } else { 0 }
+		`, 1},
+		{`
+			let outer = fn(n) {
+				let r = countdown(n);
+				fn countdown(n) { if (n == 0) { 0 } else { countdown(n - 1) } }
+				r + n;
+			};
+			outer(3);
+		`, 3},
+	}
+
+	for _, tt := range tests {
+		evaluated := runEval(t, tt.input)
+		assertOInteger(t, evaluated, tt.expected)
+	}
+}
+
+func TestFunctionStatementName(t *testing.T) {
+	evaluated := runEval(t, "fn add(x, y) { x + y }; add;")
+
+	fn, ok := evaluated.(*obj.Function)
+	if !ok {
+		t.Fatalf("object is not Function. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if fn.Name != "add" {
+		t.Fatalf("fn.Name is not 'add'. got=%q", fn.Name)
+	}
+}
+
+func TestConstAndAssignment(t *testing.T) {
+	tests := []struct {
+		input string
+		expected int64
+	}{
+		{"const a = 5; a;", 5},
+		{"let a = 5; a = 6; a;", 6},
+		{"let a = 5; let b = a = 7; a + b;", 14},
+		{"let a = 1; let inc = fn() { a = a + 1 }; inc(); inc(); a;", 3},
+		{"const a = 1; let f = fn() { let a = 2; a = 3; a }; f() + a;", 4},
+		{"let a = 1; let a = 2; a;", 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := runEval(t, tt.input)
+		assertOInteger(t, evaluated, tt.expected)
+	}
+}
+
+func TestConstAndRedeclarationErrors(t *testing.T) {
+	tests := []struct {
+		input     string
+		strictLet bool
+		expected  string
+	}{
+		{
+			"const a = 1;\na = 2;",
+			false,
+			"cannot assign to constant a, declared at 1:7",
+		},
+		{
+			"const a = 1; let f = fn() { a = 2 }; f();",
+			false,
+			"cannot assign to constant a, declared at 1:7",
+		},
+		{
+			"const a = 1; if (true) { let a = 2; }",
+			false,
+			"cannot redeclare constant a, declared at 1:7",
+		},
+		{
+			"let a = 1; if (true) { let a = 2; }",
+			true,
+			"cannot redeclare a, declared at 1:5",
+		},
+		{
+			"let f = fn(x) { if (true) { let x = 2; } }; f(1);",
+			true,
+			"cannot redeclare x, declared at 1:12",
+		},
+		{
+			"if (true) { const a = 1; if (true) { const a = 2; } }",
+			false,
+			"cannot redeclare constant a, declared at 1:19",
+		},
+		{
+			"b = 1;",
+			false,
+			"identifier not found: b",
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := runEvalWithOptions(t, tt.input, &obj.Options{StrictLet: tt.strictLet})
+		errObj, ok := evaluated.(*obj.Error)
+
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.expected {
+			t.Errorf(
+				"wrong error message. expected=%q, got=%q",
+				tt.expected, errObj.Message,
+			)
+		}
+	}
+}
+
+func TestSiblingBlockDeclarations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"if (true) { const x = 1; x }; if (true) { const x = 2; x }", 2},
+		{"if (true) { let x = 1; x }; if (true) { let x = 2; x }", 2},
+		{"let r = try { const x = 1; x } finally { const x = 2; }; r", 1},
+		{"let f = fn() { if (true) { const x = 1; } if (true) { const x = 2; x } }; f()", 2},
+		{"let s = 0; for (i in 1..3) { if (true) { const y = i; s = s + y; } } s", 6},
+	}
+
+	for _, tt := range tests {
+		for _, strictLet := range []bool{false, true} {
+			p := parser.New(lexer.New(tt.input))
+			program := p.ParseProgram()
+			if len(p.Errors()) != 0 {
+				t.Fatalf("parser errors: %v", p.Errors())
+			}
+
+			r := resolver.New()
+			r.SetStrictLet(strictLet)
+			if d := r.Resolve(program); resolver.HasErrors(d) {
+				t.Fatalf("resolver errors: %v", d)
+			}
+
+			evaluated := runEvalWithOptions(t, tt.input, &obj.Options{StrictLet: strictLet})
+			if !assertOInteger(t, evaluated, tt.expected) {
+				t.Logf("input: %q, strict let: %t", tt.input, strictLet)
+			}
+		}
+	}
+}
+
+func TestResolvedEvaluation(t *testing.T) {
+	tests := []struct {
+		input string
+		expected int64
+	}{
+		{"let f = fn(x) { let y = x * 2; y + 1 }; f(3);", 7},
+		{"let newAdder = fn(x) { fn(y) { x + y } }; newAdder(2)(3);", 5},
+		{"let f = fn(x) { let g = fn() { x = x + 1 }; g(); g(); x }; f(1);", 3},
+		{"let f = fn(n) { fn fact(n) { if (n <= 1) { return 1; } n * fact(n - 1) } fact(n) }; f(5);", 120},
+		{"let x = 10; let f = fn() { let y = x; let x = 2; y + x }; f();", 12},
+		{"let f = fn(x) { let x = x + 1; x }; f(1);", 2},
+		{"let f = fn(a) { let g = fn(b) { fn(c) { a + b + c } }; g(2)(3) }; f(1);", 6},
+		{"let f = fn(a) { try { throw a; } catch (e) { let b = a * 2; fn() { a + b }() } }; f(3);", 9},
+		{"let f = fn(xs) { match (xs) { [a, ...r] if a > 0 => a + r[0], _ => 0 } }; f([2, 3, 0]);", 5},
+		{"let f = fn([a, b], {c}) { let [d, ...e] = [a + b, c]; d * e[0] }; f([1, 2], {\"c\": 4});", 12},
+		{"let f = fn(a) { struct Pair { l, r } let p = Pair(a, a * 2); p.r = p.r + p.l; p.r }; f(2);", 6},
+		{"let f = fn(n) { let s = 0; for (i in 1..n) { let sq = i * i; s = s + sq; } s }; f(3);", 14},
+		{"let f = fn(xs) { let fs = []; for ([a, b] in xs) { fs = [...fs, fn() { a * b }]; } fs[1]() }; f([[1, 2], [3, 4]]);", 12},
+		{"let f = fn(n) { enum E { A(v), B } let e = E.A(n); match (e) { E.A(v) => v * 2, E.B => 0 } }; f(4);", 8},
+		{"enum E { A(v), B } let f = fn(xs) { let s = 0; for (E.A(v) in xs) { s = s + v; } s }; f([E.A(1), E.A(2)]);", 3},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors: %v", p.Errors())
+		}
+
+		if d := resolver.Lint(program); resolver.HasErrors(d) {
+			t.Fatalf("resolver errors: %v", d)
+		}
+
+		evaluated := Eval(program, obj.NewEnvironment())
+		assertOInteger(t, evaluated, tt.expected)
+	}
+}
+
+func assertOString(t *testing.T, o obj.Object, expected string) bool {
+	result, ok := o.(*obj.String)
+	if !ok {
+		t.Errorf("object is not String. got=%T (%+v)", o, o)
+		return false
+	}
+
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%q, want=%q", result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
+func TestStringExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"hello world"`, "hello world"},
+		{`"hello" + " " + "world"`, "hello world"},
+		{`"a" == "a"`, true},
+		{`"a" != "a"`, false},
+		{`"a" == "b"`, false},
+	}
+
+	for _, tt := range tests {
+		evaluated := runEval(t, tt.input)
+		switch expected := tt.expected.(type) {
+		case string:
+			assertOString(t, evaluated, expected)
+		case bool:
+			assertOBoolean(t, evaluated, expected)
+		}
+	}
+}
+
+func TestArrayExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2 * 2, 3 + 3][1]", 4},
+		{"let a = [1, 2, 3]; a[0] + a[1] + a[2];", 6},
+		{"let i = 0; [1][i];", 1},
+		{"[1, 2, 3][3]", nil},
+		{"[1, 2, 3][-1]", nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := runEval(t, tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			assertOInteger(t, evaluated, int64(integer))
+		} else {
+			assertNullObject(t, evaluated)
+		}
+	}
+
+	evaluated := runEval(t, "[1, \"two\", [3]]")
+	if evaluated.Inspect() != "[1, two, [3]]" {
+		t.Fatalf("array.Inspect() wrong. got=%q", evaluated.Inspect())
+	}
+}
+
+func TestTryCatch(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`try { 1 } catch (e) { 2 }`, 1},
+		{`try { throw "x"; 1 } catch (e) { 2 }`, 2},
+		{`try { 1 / 0 } catch (e) { e["message"] }`, "division by zero"},
+		{`try { 1 / 0 } catch (e) { e["kind"] }`, "ArithmeticError"},
+		{`try { throw "bad"; } catch (e) { e["kind"] + ": " + e["message"] }`, "Error: bad"},
+		{`try { throw 42; } catch (e) { e["message"] }`, "42"},
+		{`try { missing } catch (e) { e["kind"] }`, "NameError"},
+		{`try { try { throw "inner"; } catch (e) { throw e; } } catch (e) { e["message"] }`, "inner"},
+		{`try { try { throw "a"; } finally { 1 } } catch (e) { e["message"] }`, "a"},
+		{`let x = 0; try { 1 } finally { x = 5 }; x`, 5},
+		{`let x = 0; try { throw "a"; } catch (e) { x = 1 } finally { x = x + 1 }; x`, 2},
+		{`try { 1 } catch (e) { 2 } finally { 3 }`, 1},
+		{`let f = fn() { try { return 1; } finally { 2 } }; f()`, 1},
+		{`let f = fn() { try { 1 } finally { return 2; } }; f()`, 2},
+		{`try { try { 1 } finally { throw "f"; } } catch (e) { e["message"] }`, "f"},
+		{`let e = try { throw "x"; } catch (err) { err }; e["message"]`, "x"},
+		{`let e = 1; try { throw "x"; } catch (e) { 2 }; e`, 1},
+		{
+			`let records = [1, 0, 2];
+			let inverse = fn(n) { try { 100 / n } catch (e) { 0 } };
+			inverse(records[0]) + inverse(records[1]) + inverse(records[2])`,
+			150,
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := runEval(t, tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			assertOInteger(t, evaluated, int64(expected))
+		case string:
+			assertOString(t, evaluated, expected)
+		}
+	}
+}
+
+func TestErrorStack(t *testing.T) {
+	input := `
+		fn divide(a, b) { a / b }
+		fn run(x) { divide(x, 0) }
+		try { run(1) } catch (e) { e["stack"] }
+	`
+
+	evaluated := runEval(t, input)
+	stack, ok := evaluated.(*obj.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := []string{"divide at 3:21", "run at 4:12"}
+	if len(stack.Elements) != len(expected) {
+		t.Fatalf("wrong stack. expected=%v, got=%s", expected, stack.Inspect())
+	}
+
+	for i, frame := range expected {
+		assertOString(t, stack.Elements[i], frame)
+	}
+}
+
+func TestResults(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"ok(1)", "ok(1)"},
+		{`err("bad")`, "err(bad)"},
+		{"is_ok(ok(1))", "true"},
+		{"is_err(ok(1))", "false"},
+		{"unwrap(ok(5))", "5"},
+		{"let f = fn() { ok(1)? + 1 }; f();", "2"},
+		{`let f = fn() { err("bad")? + 1 }; f();`, "err(bad)"},
+		{`
+			let parse = fn(n) { if (n < 0) { err("negative") } else { ok(n) } };
+			let total = fn(a, b) { ok(parse(a)? + parse(b)?) };
+			[total(1, 2), total(1, -1), total(-1, 1)];
+		`, "[ok(3), err(negative), err(negative)]"},
+		{`
+			let inner = fn() { err("inner")? };
+			let outer = fn() { let r = inner(); ok(is_err(r)) };
+			outer();
+		`, "ok(true)"},
+		{`
+			let f = fn() { try { err("x")? } catch (e) { ok("caught") } };
+			f();
+		`, "err(x)"},
+		{`
+			let f = fn() { [1, err("in array")?, 3] };
+			f();
+		`, "err(in array)"},
+		{`err("top")?; 5;`, "err(top)"},
+	}
+
+	for _, tt := range tests {
+		evaluated := runEval(t, tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("%q - expected=%q, got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestHashExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"a": 1, "b": 2}`, `{"a": 1, "b": 2}`},
+		{`{"b": 1, "a": 2, "b": 3}`, `{"b": 3, "a": 2}`},
+		{`let k = "x"; {k: 1, 2: true, false: "f"}`, `{"x": 1, 2: true, false: f}`},
+		{`{"a": 5}["a"]`, "5"},
+		{`{"a": 5}["b"]`, "null"},
+		{`{1: "one"}[1]`, "one"},
+		{`{true: 1}[true]`, "1"},
+		{`{"a": 1}[[1]]`, "TypeError: unusable as hash key: ARRAY"},
+		{`{[1]: 1}`, "TypeError: unusable as hash key: ARRAY"},
+	}
+
+	for _, tt := range tests {
+		evaluated := runEval(t, tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("%q - expected=%q, got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+// A string whose HashKey is the same whatever its value
+type collidingKey struct {
+	obj.String
+}
+
+func (k *collidingKey) HashKey() obj.HashKey {
+	return obj.HashKey{Type: obj.STRING_OBJ, Value: 1}
+}
+
+func (k *collidingKey) Equals(other obj.Object) bool {
+	o, ok := other.(*collidingKey)
+	return ok && o.Value == k.Value
+}
+
+func TestHashKeyCollisions(t *testing.T) {
+	a := &collidingKey{obj.String{Value: "a"}}
+	b := &collidingKey{obj.String{Value: "b"}}
+
+	h := obj.NewHash()
+	h.Set(a, &obj.Integer{Value: 1})
+	h.Set(b, &obj.Integer{Value: 2})
+	h.Set(a, &obj.Integer{Value: 3})
+	if got := h.Inspect(); got != "{a: 3, b: 2}" {
+		t.Errorf("expected {a: 3, b: 2}, got=%s", got)
+	}
+	if val, ok := h.Get(b); !ok {
+		t.Errorf("expected b in the hash")
+	} else {
+		assertOInteger(t, val, 2)
+	}
+
+	same := obj.NewHash()
+	same.Set(b, &obj.Integer{Value: 2})
+	same.Set(a, &obj.Integer{Value: 3})
+	swapped := obj.NewHash()
+	swapped.Set(a, &obj.Integer{Value: 2})
+	swapped.Set(b, &obj.Integer{Value: 3})
+	if !h.Equals(same) || h.Equals(swapped) {
+		t.Errorf("hashes with colliding keys compared wrong: %s == %s, %s != %s", h.Inspect(), same.Inspect(), h.Inspect(), swapped.Inspect())
+	}
+
+	unique := builtinUnique(&obj.Array{Elements: []obj.Object{a, b, a}})
+	if got := unique.Inspect(); got != "[a, b]" {
+		t.Errorf("expected unique to keep colliding elements, got=%s", got)
+	}
+}
+
+func TestMatchExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"match (0) { 0 => 1, _ => 2 }", "1"},
+		{"match (5) { 0 => 1, _ => 2 }", "2"},
+		{"match (-1) { -1 => \"neg\", _ => \"other\" }", "neg"},
+		{`match ("b") { "a" => 1, "b" => 2 }`, "2"},
+		{"match (false) { true => 1, false => 0 }", "0"},
+		{"match (12) { n if n > 10 => n * 2, n => n }", "24"},
+		{"match (3) { n if n > 10 => n * 2, n => n }", "3"},
+		{"match ([1, 2, 3]) { [] => 0, [first, ...rest] => rest }", "[2, 3]"},
+		{"match ([]) { [] => 0, [first, ...rest] => rest }", "0"},
+		{"match ([1]) { [a, b] => a + b, [a] => a }", "1"},
+		{"match ([1, [2, 3]]) { [a, [b, c]] => a + b + c }", "6"},
+		{"match ([1, 2]) { [_, 2] => \"ends in 2\" }", "ends in 2"},
+		{`match ({"type": "circle", "r": 2}) { {"type": "square"} => 0, {"type": t, "r": r} => t + " " + r }`,
+			"TypeError: type mismatch: STRING + INTEGER"},
+		{`match ({"type": "circle", "r": 2}) { {"type": "square"} => 0, {"type": "circle", "r": r} => r * r }`, "4"},
+		{`match ({"a": 1}) { {"b": _} => 1, _ => 2 }`, "2"},
+		{`match (1) { [x] => x, {"a": x} => x, x => x + 1 }`, "2"},
+		{"let x = 1; match (5) { x => x }; x", "1"},
+		{"match (4) { n => { let m = n * n; m } }", "16"},
+		{"let f = fn(code) { match (code) { 200 => \"ok\", 404 => \"missing\", c if c >= 500 => \"server\", _ => \"?\" } }; [f(200), f(404), f(503), f(1)]",
+			"[ok, missing, server, ?]"},
+		{"let f = fn(xs) { match (xs) { [] => 0, [x, ...rest] => x + f(rest) } }; f([1, 2, 3, 4])", "10"},
+		{"match (7) { 0 => 1, 1 => 2 }", "MatchError: no match arm for 7 at 1:1"},
+		{"match (1) { n if missing => 1 }", "NameError: identifier not found: missing"},
+		{"try { match ([1]) { [] => 0 } } catch (e) { e[\"kind\"] }", "MatchError"},
+	}
+
+	for _, tt := range tests {
+		evaluated := runEval(t, tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("%q - expected=%q, got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+// A value that counts how often it is printed
+type inspectCounter struct {
+	obj.Integer
+	count int
+}
+
+func (c *inspectCounter) Inspect() string {
+	c.count++
+	return c.Integer.Inspect()
+}
+
+func TestFailedArmsDoNotInspect(t *testing.T) {
+	program := parseInput(t, `match (x) { 1 => 0, "a" => 1, [a] => 2, _ => 3 }`)
+	subject := &inspectCounter{Integer: obj.Integer{Value: 7}}
+	env := obj.NewEnvironment()
+	env.Set("x", subject)
+
+	evaluated := Eval(program, env)
+	assertOInteger(t, evaluated, 3)
+	if subject.count != 0 {
+		t.Errorf("subject inspected %d times by arms that failed", subject.count)
+	}
+}
+
+func TestDestructuring(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, b, ...rest] = [1, 2, 3, 4]; [a, b, rest]", "[1, 2, [3, 4]]"},
+		{"let [a, ...rest] = [1]; rest", "[]"},
+		{`let {name, age: years} = {"name": "ada", "age": 36}; years`, "36"},
+		{`let {name, age: years} = {"name": "ada", "age": 36, "x": 0}; [name, years]`, "[ada, 36]"},
+		{`let [_, {"k": [v]}] = [0, {"k": [9]}]; v`, "9"},
+		{"const [c] = [1]; c = 2", "BindingError: cannot assign to constant c, declared at 1:8"},
+		{"let f = fn([x, y], z) { x + y + z }; f([1, 2], 3)", "6"},
+		{`fn area({w, h}) { w * h }; area({"w": 2, "h": 3})`, "6"},
+		{"let f = fn([head, ...tail]) { tail }; f([1, 2, 3])", "[2, 3]"},
+		{"let f = fn(n) { let [a, b] = [n, n * 2]; a + b }; f(2)", "6"},
+		{"let [a, b] = [1]; a", "MatchError: cannot destructure [a, b] at 1:5: expected 2 elements, got 1"},
+		{"let [a, b, ...r] = [1]; a", "MatchError: cannot destructure [a, b, ...r] at 1:5: expected at least 2 elements, got 1"},
+		{"let [a] = 5; a", "MatchError: cannot destructure [a] at 1:5: expected ARRAY, got INTEGER"},
+		{`let {name} = {"age": 1}; name`, `MatchError: cannot destructure {"name": name} at 1:5: missing key "name"`},
+		{`let {name} = [1]; name`, `MatchError: cannot destructure {"name": name} at 1:5: expected HASH, got ARRAY`},
+		{"let [1, a] = [2, 3]; a", "MatchError: cannot destructure [1, a] at 1:5: expected 1, got 2"},
+		{"let f = fn([a]) { a }; f(1)", "MatchError: cannot destructure [a] at 1:12: expected ARRAY, got INTEGER"},
+		{"let a = 0; try { let [a, b] = [1]; 0 } catch (e) { a }", "0"},
+	}
+
+	for _, tt := range tests {
+		evaluated := runEval(t, tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("%q - expected=%q, got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let double = fn(x) { x * 2 }; 3 |> double", "6"},
+		{"let add = fn(a, b) { a + b }; 1 |> add(2) |> add(3)", "6"},
+		{"let sub = fn(a, b) { a - b }; 10 |> sub(4)", "6"},
+		{"let wrap = fn(x) { [x] }; 1 + 2 |> wrap", "[3]"},
+		{"2 |> fn(x) { x * x }", "4"},
+		{"let f = fn() { 5 |> ok()? }; f()", "5"},
+		{"let f = fn() { \"bad\" |> err()? }; f()", "err(bad)"},
+		{"1 |> 2", "TypeError: not a function: INTEGER"},
+		{"[1, 2, 3, 4] |> filter(x => x % 2 == 0) |> map(x => x * 10) |> len", "2"},
+		{`["héllo" |> len, {"a": 1} |> len, 1..10 |> len, 0..<0 |> len]`, "[5, 1, 10, 0]"},
+		{"len(5)", "TypeError: argument to len must be STRING, ARRAY, HASH or RANGE, got INTEGER"},
+		{"len()", "ArgumentError: wrong number of arguments to len: want=1, got=0"},
+	}
+
+	for _, tt := range tests {
+		evaluated := runEval(t, tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("%q - expected=%q, got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestArrowFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let double = x => x * 2; double(4)", "8"},
+		{"let add = (a, b) => a + b; add(2, 3)", "5"},
+		{"let k = () => 7; k()", "7"},
+		{"let adder = x => y => x + y; adder(1)(2)", "3"},
+		{"let f = x => { let y = x + 1; y * y }; f(2)", "9"},
+		{"let first = ([a, ...r]) => a; first([5, 6])", "5"},
+		{"3 |> (x => x * x)", "9"},
+		{"let f = x => x; f(1, 2)", "ArgumentError: wrong number of arguments to anonymous function: want=1, got=2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := runEval(t, tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("%q - expected=%q, got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestConditionalAndNullish(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"true ? 1 : 2", "1"},
+		{"false ? 1 : 2", "2"},
+		{"1 < 2 ? \"yes\" : \"no\"", "yes"},
+		{"let n = 0; true ? 1 : (n = 5); n", "0"},
+		{"false ? missing : 3", "3"},
+		{"let sign = fn(n) { n < 0 ? -1 : n == 0 ? 0 : 1 }; [sign(-5), sign(0), sign(5)]", "[-1, 0, 1]"},
+		{"null", "null"},
+		{"null ?? 5", "5"},
+		{"0 ?? 5", "0"},
+		{"false ?? 5", "false"},
+		{"1 ?? missing", "1"},
+		{"null ?? null ?? 3", "3"},
+		{`{"a": 1}["b"] ?? "default"`, "default"},
+		{"[1][5] ?? 0", "0"},
+		{"null == null", "true"},
+		{"match (null) { null => \"none\", _ => \"some\" }", "none"},
+		{"missing ?? 1", "NameError: identifier not found: missing"},
+	}
+
+	for _, tt := range tests {
+		evaluated := runEval(t, tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("%q - expected=%q, got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestTruthiness(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"if (1) { 10 } else { 20 }", "10"},
+		{"if (0) { 10 } else { 20 }", "20"},
+		{`if ("") { 10 } else { 20 }`, "20"},
+		{`if ("a") { 10 } else { 20 }`, "10"},
+		{"if ([]) { 10 } else { 20 }", "20"},
+		{"if ({}) { 10 } else { 20 }", "20"},
+		{`if ({"a": 1}) { 10 } else { 20 }`, "10"},
+		{"if (null) { 10 } else { 20 }", "20"},
+		{"if (fn() { 1 }) { 10 } else { 20 }", "10"},
+		{"!0", "true"},
+		{"!5", "false"},
+		{"!null", "true"},
+		{"[] ? 1 : 2", "2"},
+		{"match (0) { n if n => 1, _ => 2 }", "2"},
+		{"true && 5", "5"},
+		{"0 && missing", "0"},
+		{"null || \"default\"", "default"},
+		{"1 || missing", "1"},
+		{"false || false", "false"},
+		{"1 < 2 && 2 < 3", "true"},
+		{"1 > 2 || 2 > 3", "false"},
+		{"true || false && false", "true"},
+		{"let n = 0; false && (n = 1); n", "0"},
+	}
+
+	for _, tt := range tests {
+		evaluated := runEval(t, tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("%q - expected=%q, got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestStrictBool(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"if (true) { 10 } else { 20 }", "10"},
+		{"if (1 > 2) { 10 } else { 20 }", "20"},
+		{"!false", "true"},
+		{"true && false || true", "true"},
+		{"if (1) { 10 }", "TypeError: condition must be BOOLEAN, got INTEGER"},
+		{"!5", "TypeError: invalid operand: !INTEGER"},
+		{"null ? 1 : 2", "TypeError: condition must be BOOLEAN, got NULL"},
+		{"1 && true", "TypeError: condition must be BOOLEAN, got INTEGER"},
+		{"true && 1", "TypeError: condition must be BOOLEAN, got INTEGER"},
+		{"false || []", "TypeError: condition must be BOOLEAN, got ARRAY"},
+		{"match (1) { n if n => 1 }", "TypeError: condition must be BOOLEAN, got INTEGER"},
+		{"null ?? 1", "1"},
+		{"any([0, 1], fn(x) { x })", "TypeError: condition must be BOOLEAN, got INTEGER"},
+		{"all([1, 2], x => x)", "TypeError: condition must be BOOLEAN, got INTEGER"},
+		{"find([\"\", \"a\"], x => x)", "TypeError: condition must be BOOLEAN, got STRING"},
+		{"any([0, 1])", "TypeError: condition must be BOOLEAN, got INTEGER"},
+		{"[any([1, 2], x => x > 1), all([true]), find(1..5, x => x > 3)]", "[true, true, 4]"},
+		{"filter([0, 1, 2], fn(x) { x })", "TypeError: condition must be BOOLEAN, got INTEGER"},
+		{"[...filter(1..5, x => x % 2)]", "TypeError: condition must be BOOLEAN, got INTEGER"},
+		{"[...filter(1..5, x => x % 2 == 1)]", "[1, 3, 5]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := runEvalWithOptions(t, tt.input, &obj.Options{StrictBool: true})
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("%q - expected=%q, got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestEquality(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`"a" + "b" == "ab"`, true},
+		{"[1, 2, [3]] == [1, 2, [3]]", true},
+		{"[1, 2] == [1, 2, 3]", false},
+		{"[1, 2] != [2, 1]", true},
+		{`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`{"a": 1} == {"a": 1, "b": 2}`, false},
+		{"1 == true", false},
+		{`1 != "1"`, true},
+		{"null == null", true},
+		{"null == false", false},
+		{"ok([1]) == ok([1])", true},
+		{"ok(1) == err(1)", false},
+		{"let f = fn() { 1 }; f == f", true},
+		{"fn() { 1 } == fn() { 1 }", false},
+		{"let e = try { throw \"x\"; } catch (e) { e }; e == e", true},
+		{"let xs = [1]; xs.push(xs); let ys = [1]; ys.push(ys); xs == ys", true},
+		{"let xs = [1]; xs.push(xs); xs == xs", true},
+		{"let xs = [1]; xs.push(xs); let ys = [1]; ys.push([1, ys]); xs == ys", true},
+		{"let xs = [1]; xs.push(xs); let ys = [2]; ys.push(ys); xs == ys", false},
+		{"let xs = [1]; xs.push(xs); xs == [1, [1]]", false},
+	}
+
+	for _, tt := range tests {
+		evaluated := runEval(t, tt.input)
+		if !assertOBoolean(t, evaluated, tt.expected) {
+			t.Logf("input: %q", tt.input)
+		}
+	}
+}
+
+func TestInspectCycles(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let xs = [1]; xs.push(xs); xs", "[1, [...]]"},
+		{"let xs = [1]; xs.push(xs); [xs, xs]", "[[1, [...]], [1, [...]]]"},
+		{"let xs = [1]; [xs, xs]", "[[1], [1]]"},
+		{`let xs = []; let h = {"xs": xs}; xs.push(h); h`, `{"xs": [{...}]}`},
+		{"struct Node { value, next } let n = Node(1, null); n.next = n; n", "Node{value: 1, next: Node{...}}"},
+		{"let xs = []; xs.push(ok(xs)); xs", "[ok([...])]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := runEval(t, tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("%q - expected=%q, got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestRangesAndIterators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1..5", "1..5"},
+		{"1..5 == 1..<6", "true"},
+		{"[...9223372036854775806..9223372036854775807]", "[9223372036854775806, 9223372036854775807]"},
+		{"[...-9223372036854775807 - 1..-9223372036854775807]", "[-9223372036854775808, -9223372036854775807]"},
+		{"(0..9223372036854775807)[9223372036854775806]", "9223372036854775806"},
+		{"[...range(9223372036854775807, 9223372036854775800, -3)]", "[9223372036854775807, 9223372036854775804, 9223372036854775801]"},
+		{"[...range(-9223372036854775807 - 1, 9223372036854775807, 9223372036854775807)]", "[-9223372036854775808, -1, 9223372036854775806]"},
+		{"[...5..5, ...5..4]", "[5]"},
+		{"0..<3", "0..<3"},
+		{"range(4)", "0..<4"},
+		{"range(10, 0, -3)", "range(10, 0, -3)"},
+		{"[...1..5]", "[1, 2, 3, 4, 5]"},
+		{"[...range(10, 0, -3)]", "[10, 7, 4, 1]"},
+		{"[...range(0, 10, 4)]", "[0, 4, 8]"},
+		{"[...5..<5]", "[]"},
+		{"[...3..1]", "[]"},
+		{"(2..<9)[3]", "5"},
+		{"(2..<9)[7]", "null"},
+		{"range(0, 10, 2)[2]", "4"},
+		{"1..3 == range(1, 4)", "true"},
+		{"if (0..<0) { 1 } else { 2 }", "2"},
+		{`[...[1, 2], ..."ab", ...{"k": 1}]`, `[1, 2, a, b, k]`},
+		{"let add = fn(a, b, c) { a + b + c }; add(...[1, 2], 3)", "6"},
+		{"let s = 0; for (i in 1..10) { s = s + i; } s", "55"},
+		{`let s = ""; for (c in "héllo") { s = c + s; } s`, "olléh"},
+		{`let ks = []; for ([k, v] in [["a", "1"], ["b", "2"]]) { ks = [...ks, k + v]; } ks`, "[a1, b2]"},
+		{"let n = 0; for (x in []) { n = 1; } n", "0"},
+		{"let find = fn() { for (i in 0..<10000000) { if (i * i > 50) { return i; } } -1 }; find()", "8"},
+		{"map([1, 2, 3], x => x * 10)", "[10, 20, 30]"},
+		{"filter([1, 2, 3, 4], x => x % 2 == 0)", "[2, 4]"},
+		{"map(1..3, x => x * x)", "iterator"},
+		{"[...map(1..3, x => x * x)]", "[1, 4, 9]"},
+		{"[...filter(map(0..<10, x => x * 3), x => x % 2)]", "[3, 9, 15, 21, 27]"},
+		{"let calls = 0; let m = map(0..<1000000, fn(x) { calls = calls + 1; x }); for (x in m) { if (x == 2) { return calls; } }", "3"},
+		{"let it = map([1], x => x); it == it", "true"},
+		{"for (x in 5) { x }", "TypeError: cannot iterate over INTEGER"},
+		{"[...5]", "TypeError: cannot iterate over INTEGER"},
+		{"for ([a] in [1]) { a }", "MatchError: cannot destructure [a] at 1:6: expected ARRAY, got INTEGER"},
+		{"[...map(1..3, fn(x) { x + true })]", "TypeError: type mismatch: INTEGER + BOOLEAN"},
+		{"range(0, 5, 0)", "ArgumentError: range step must not be zero"},
+		{`range("a")`, "TypeError: argument to range must be INTEGER, got STRING"},
+		{"range()", "ArgumentError: wrong number of arguments to range: want=1 to 3, got=0"},
+		{`map([1], 2)`, "TypeError: not a function: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := runEval(t, tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("%q - expected=%q, got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestCollectionBuiltins(t *testing.T) {
+	gen := "let closed = false; fn* gen() { try { yield 1; yield 2; yield 3; } finally { closed = true; } } "
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"reduce([1, 2, 3], (a, b) => a + b)", "6"},
+		{"reduce([1, 2, 3], (a, b) => a - b, 10)", "4"},
+		{"reduce(1..4, (a, b) => a * b)", "24"},
+		{"reduce([], (a, b) => a + b, 0)", "0"},
+		{"[1, 2, 3] |> reduce((acc, x) => [x, ...acc], [])", "[3, 2, 1]"},
+		{"let s = 0; [each([1, 2, 3], fn(x) { s = s + x }), s]", "[null, 6]"},
+		{"[any([1, 2, 3], x => x > 2), any([1, 2], x => x > 2), any([])]", "[true, false, false]"},
+		{"[all([1, 2, 3], x => x > 0), all([1, 2], x => x > 1), all([])]", "[true, false, true]"},
+		{"[any([0, null, 3]), all([1, \"\", 3])]", "[true, false]"},
+		{"[find([1, 2, 3, 4], x => x % 2 == 0), find([1], x => x > 5)]", "[2, null]"},
+		{"find(1..1000000000, x => x * x > 50)", "8"},
+		{"zip([1, 2, 3], [\"a\", \"b\"])", "[[1, a], [2, b]]"},
+		{"zip([1, 2], [3, 4], [5, 6])", "[[1, 3, 5], [2, 4, 6]]"},
+		{"zip(0..<2, [\"a\", \"b\", \"c\"])", "iterator"},
+		{"[...zip(0..<2, \"abc\")]", "[[0, a], [1, b]]"},
+		{"enumerate([\"a\", \"b\"])", "[[0, a], [1, b]]"},
+		{"let out = []; for ([i, c] in enumerate(\"xy\")) { out.push(c + c); } out", "[xx, yy]"},
+		{"flat_map([1, 2, 3], x => [x, x * 10])", "[1, 10, 2, 20, 3, 30]"},
+		{"flat_map([1, 2, 0], x => 0..<x)", "[0, 0, 1]"},
+		{"[...flat_map(1..2, x => [x, -x])]", "[1, -1, 2, -2]"},
+		{"group_by([1, 2, 3, 4, 5], x => x % 2 == 0 ? \"even\" : \"odd\")", "{\"odd\": [1, 3, 5], \"even\": [2, 4]}"},
+		{"group_by([], x => x)", "{}"},
+		{"sort([3, 1, 2])", "[1, 2, 3]"},
+		{"sort([\"pear\", \"apple\", \"fig\"])", "[apple, fig, pear]"},
+		{"sort([3, 1, 2], (a, b) => a > b)", "[3, 2, 1]"},
+		{"sort([[2, \"a\"], [1, \"b\"], [2, \"c\"], [1, \"d\"]], (a, b) => a[0] < b[0])", "[[1, b], [1, d], [2, a], [2, c]]"},
+		{"let xs = [2, 1]; sort(xs); xs", "[2, 1]"},
+		{"sort(range(5, 0, -1))", "[1, 2, 3, 4, 5]"},
+		{"unique([1, 2, 1, 3, 2])", "[1, 2, 3]"},
+		{"unique([[1], [2], [1], \"a\", \"a\"])", "[[1], [2], a]"},
+		{"[...unique(map(0..<6, x => x % 3))]", "[0, 1, 2]"},
+		{gen + "[any(gen(), x => x == 1), closed]", "[true, true]"},
+		{gen + "[find(gen(), x => x == 2), closed]", "[2, true]"},
+		{gen + "[...zip(gen(), [1])]; closed", "true"},
+		{gen + "[...flat_map([1], x => gen())]; closed", "true"},
+		{"reduce([], (a, b) => a + b)", "TypeError: reduce of an empty iterable without an initial value"},
+		{"reduce([1, 2], fn(a, b) { throw \"boom\" })", "Error: boom"},
+		{"try { each([1, 2], fn(x) { x + true }) } catch (e) { e[\"message\"] }", "type mismatch: INTEGER + BOOLEAN"},
+		{"let n = 0; any(1..5, fn(x) { n = n + 1; if (x == 2) { throw \"stop\" } false }); n", "Error: stop"},
+		{"all([1], fn(a, b) { true })", "ArgumentError: wrong number of arguments to anonymous function: want=2, got=1"},
+		{"[...flat_map([1], x => x)]", "TypeError: cannot iterate over INTEGER"},
+		{"group_by([1], x => [x])", "TypeError: unusable as hash key: ARRAY"},
+		{"sort([1, \"a\"])", "TypeError: cannot compare STRING and INTEGER"},
+		{"sort([1, 2, 3], fn(a, b) { a + true })", "TypeError: type mismatch: INTEGER + BOOLEAN"},
+		{"sort([3, 1, 2, 3], (a, b) => b - a)", "TypeError: less of sort must return BOOLEAN, got INTEGER"},
+		{"sort([3, 1], (a, b) => null)", "TypeError: less of sort must return BOOLEAN, got NULL"},
+		{"zip([1])", "ArgumentError: wrong number of arguments to zip: want=at least 2, got=1"},
+		{"zip([1], 2)", "TypeError: cannot iterate over INTEGER"},
+		{"sort()", "ArgumentError: wrong number of arguments to sort: want=1 to 2, got=0"},
+		{"enumerate(5)", "TypeError: cannot iterate over INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := runEval(t, tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("%q - expected=%q, got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestGenerators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn* three() { yield 1; yield 2; yield 3; } [...three()]", "[1, 2, 3]"},
+		{"let g = fn* (n) { for (i in 0..<n) { yield i * i; } }; [...g(4)]", "[0, 1, 4, 9]"},
+		{"fn* g() { yield 1; } g()", "iterator"},
+		{"fn* g() { yield 1; } g", "fn* g() {\nyield 1;\n}"},
+		{"fn* none() { 1 } [...none()]", "[]"},
+		{"fn* g() { yield 1; return 5; yield 2; } [...g()]", "[1]"},
+		{"fn* from(n) { yield n; for (x in from(n + 1)) { yield x; } } [...take(from(1), 4)]", "[1, 2, 3, 4]"},
+		{"fn* naturals() { for (i in range(0, 9223372036854775807)) { yield i; } } [...take(filter(naturals(), n => n % 7 == 0), 3)]", "[0, 7, 14]"},
+		{"fn* g() { yield 1; yield 2; } let it = g(); let a = [...it]; let b = [...it]; [a, b]", "[[1, 2], []]"},
+		{"fn* g() { yield 1; yield 2; } let a = g(); let b = g(); [...map(a, x => x * 10), ...b]", "[10, 20, 1, 2]"},
+		{"let n = 0; fn* g() { n = n + 1; yield n; } let it = g(); n", "0"},
+		{"let f = fn() { let gen = fn* () { let x = 1; yield x; x = x + 1; yield x; }; [...gen()] }; f()", "[1, 2]"},
+		{
+			`let log = []; fn* g() { try { yield 1; yield 2; log = [...log, "after"]; } finally { log = [...log, "closed"]; } }
+			 let first = fn() { for (x in g()) { return x; } };
+			 [first(), [...take(g(), 1)], log]`,
+			"[1, [1], [closed, closed]]",
+		},
+		{"fn* g() { yield 1; yield 1 + true; yield 3; } [...g()]", "TypeError: type mismatch: INTEGER + BOOLEAN"},
+		{"fn* g() { yield 1; throw \"boom\"; } let n = 0; for (x in g()) { n = n + x; }", "Error: boom"},
+		{"fn* g() { yield 1; throw \"boom\"; } try { [...g()] } catch (e) { \"caught\" }", "caught"},
+		{"fn* g([a, b]) { yield a; } g(1)", "MatchError: cannot destructure [a, b] at 1:7: expected ARRAY, got INTEGER"},
+		{"fn* g(a) { yield a; } g()", "ArgumentError: wrong number of arguments to g: want=1, got=0"},
+		{"take([1, 2, 3], 2)", "[1, 2]"},
+		{"take(1..10, 0)", "iterator"},
+		{"take(1..10, \"2\")", "TypeError: argument to take must be INTEGER, got STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := runEval(t, tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("%q - expected=%q, got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestGeneratorsStopWhenClosed(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	input := `
+	fn* naturals() { for (i in range(0, 9223372036854775807)) { yield i; } }
+	let first = fn(it) { for (x in it) { return x; } };
+	for (_ in 0..<100) { first(naturals()); first(map(naturals(), x => x)); take(naturals(), 2); }
+	[...take(naturals(), 3)]
+	`
+	evaluated := runEval(t, input)
+	if evaluated.Inspect() != "[0, 1, 2]" {
+		t.Fatalf("expected=%q, got=%q", "[0, 1, 2]", evaluated.Inspect())
+	}
+
+	// closing waits for the body to return, its goroutine ends right after
+	for i := 0; i < 100 && runtime.NumGoroutine() > before; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("generators left running: %d goroutines before, %d after", before, after)
+	}
+}
+
+func TestStructs(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"struct Point { x, y } Point(1, 2)", "Point{x: 1, y: 2}"},
+		{"struct Point { x, y } Point", "struct Point { x, y }"},
+		{"struct Point { x, y } let p = Point(1, 2); p.x + p.y", "3"},
+		{"struct Point { x, y } let p = Point(1, 2); p.x = 3; p", "Point{x: 3, y: 2}"},
+		{"struct Point { x, y } let p = Point(1, 2); let q = p; q.y = 5; p.y", "5"},
+		{"struct Point { x, y } let p = Point(1, 2); p.x = p.y = 7; [p.x, p.y]", "[7, 7]"},
+		{"struct Line { from, to } struct Point { x, y } let l = Line(Point(0, 0), Point(1, 2)); l.to.y = 9; l", "Line{from: Point{x: 0, y: 0}, to: Point{x: 1, y: 9}}"},
+		{"let p = Point(1, 2); struct Point { x, y } p.y", "2"},
+		{"struct Empty {} [Empty(), type(Empty())]", "[Empty{}, Empty]"},
+		{"struct Point { x, y } type(Point(1, 2))", "Point"},
+		{"type(1)", "INTEGER"},
+		{`type("a")`, "STRING"},
+		{"struct Point { x, y } type(Point)", "STRUCT_TYPE"},
+		{"struct Point { x, y } Point(1, [2]) == Point(1, [2])", "true"},
+		{"struct Point { x, y } Point(1, 2) == Point(2, 1)", "false"},
+		{"struct A { v } struct B { v } A(1) == B(1)", "false"},
+		{"struct Point { x, y } let p = Point(1, 2); match (p.x) { 1 => \"one\", _ => \"other\" }", "one"},
+		{"struct Point { x, y } [Point(1, 2), Point(3, 4)] |> map(p => p.x * p.y)", "[2, 12]"},
+		{"let f = fn(n) { struct Box { v } let b = Box(n); b.v = b.v * 2; b }; f(4)", "Box{v: 8}"},
+		{"struct Point { x, y } Point(1)", "ArgumentError: wrong number of arguments to Point: want=2, got=1"},
+		{"struct Point { x, y } Point(1, 2).z", "NameError: Point has no field z"},
+		{"struct Point { x, y } let p = Point(1, 2); p.z = 1", "NameError: Point has no field z"},
+		{"let h = {\"x\": 1}; h.x", "NameError: HASH has no field x"},
+		{"5.x", "NameError: INTEGER has no field x"},
+		{"struct Point { x, y } let p = Point(1, 2); p.x = missing", "NameError: identifier not found: missing"},
+	}
+
+	for _, tt := range tests {
+		evaluated := runEval(t, tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("%q - expected=%q, got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestEnums(t *testing.T) {
+	shape := "enum Shape { Circle(r), Rect(w, h), Empty } "
+	area := "fn area(s) { match (s) { Shape.Circle(r) => 3 * r * r, Shape.Rect(w, h) => w * h, Shape.Empty => 0 } } "
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{shape + "Shape.Circle(3)", "Shape.Circle(3)"},
+		{shape + "Shape.Empty", "Shape.Empty"},
+		{shape + "Shape.Rect", "Shape.Rect"},
+		{shape + "Shape", "enum Shape { Circle(r), Rect(w, h), Empty }"},
+		{shape + "[Shape.Rect(2, 3).w, Shape.Rect(2, 3).h]", "[2, 3]"},
+		{shape + area + "[Shape.Circle(2), Shape.Rect(2, 3), Shape.Empty] |> map(area)", "[12, 6, 0]"},
+		{shape + "match (Shape.Rect(1, 2)) { Shape.Circle => \"circle\", Shape.Rect => \"rect\" }", "rect"},
+		{shape + "match (Shape.Rect(2, 2)) { Shape.Rect(w, h) if w == h => \"square\", _ => \"other\" }", "square"},
+		{shape + "match (Shape.Rect(1, [2])) { Shape.Rect(1, [h]) => h, _ => 0 }", "2"},
+		{shape + "match (Shape.Circle(1)) { Shape.Rect(_, _) => 1, Shape.Empty => 2 }", "MatchError: no match arm for Shape.Circle(1) at 1:45"},
+		{shape + "let Shape.Circle(r) = Shape.Circle(5); r", "5"},
+		{shape + "let Shape.Circle(r) = Shape.Empty; r", "MatchError: cannot destructure Shape.Circle(r) at 1:49: expected Shape.Circle, got Shape.Empty"},
+		{shape + "let [Shape.Rect(w, h)] = [Shape.Rect(1, 2)]; w + h", "3"},
+		{shape + "fn f(Shape.Circle(r)) { r } f(Shape.Circle(7))", "7"},
+		{shape + "Shape.Circle(1) == Shape.Circle(1)", "true"},
+		{shape + "Shape.Circle(1) == Shape.Circle(2)", "false"},
+		{shape + "Shape.Empty == Shape.Empty", "true"},
+		{shape + "enum Other { Empty } Shape.Empty == Other.Empty", "false"},
+		{shape + "[type(Shape.Empty), type(Shape.Circle(1)), type(Shape), type(Shape.Circle)]", "[Shape, Shape, ENUM_TYPE, VARIANT]"},
+		{shape + "[1, 2] |> map(Shape.Circle)", "[Shape.Circle(1), Shape.Circle(2)]"},
+		{shape + "impl Shape { fn empty(self) { self == Shape.Empty } } [Shape.Empty.empty(), Shape.Circle(1).empty()]", "[true, false]"},
+		{shape + "impl Shape { fn r(self) { 0 } } Shape.Circle(4).r", "4"},
+		{shape + "Shape.Square", "NameError: Shape has no variant Square"},
+		{shape + "Shape.Square(1)", "NameError: Shape has no variant Square"},
+		{shape + "Shape.Circle(1).x", "NameError: Shape has no field x"},
+		{shape + "Shape.Circle(1, 2)", "ArgumentError: wrong number of arguments to Shape.Circle: want=1, got=2"},
+		{shape + "Shape.Empty(1)", "TypeError: not a function: ENUM"},
+		{shape + "let c = Shape.Circle(1); c.r = 2", "TypeError: cannot assign to r of Shape.Circle(1), enum values cannot be changed"},
+		{"let s = 1; match (s) { s.A => 1 }", "MatchError: no match arm for 1 at 1:12"},
+	}
+
+	for _, tt := range tests {
+		evaluated := runEval(t, tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("%q - expected=%q, got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestMethods(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let xs = [1, 2]; xs.push(3); xs", "[1, 2, 3]"},
+		{"let xs = []; xs.push(1).push(2).len()", "2"},
+		{"let xs = [1, 2, 3]; [xs.pop(), xs]", "[3, [1, 2]]"},
+		{"[].pop()", "null"},
+		{"[1, [2]].contains([2])", "true"},
+		{`"héllo".len()`, "5"},
+		{`"Hello".upper() + "Hello".lower()`, "HELLOhello"},
+		{`let h = {"a": 1, "b": 2}; [h.keys(), h.values(), h.len(), h.has("b"), h.has("c")]`, "[[a, b], [1, 2], 2, true, false]"},
+		{"let xs = [1]; let push = xs.push; push(2); push(3); xs", "[1, 2, 3]"},
+		{"let xs = [1]; xs.push", "bound method push of [1]"},
+		{"let xs = [1]; xs.push == xs.push", "true"},
+		{"[1].push == [1].push", "false"},
+		{"let xs = [1, 2]; [3, 4] |> map(xs.push); xs", "[1, 2, 3, 4]"},
+		{
+			`struct Point { x, y }
+			 impl Point {
+				 fn norm2(self) { self.x * self.x + self.y * self.y }
+				 fn add(self, o) { Point(self.x + o.x, self.y + o.y) }
+				 fn scale(self, k) { self.x = self.x * k; self.y = self.y * k; self }
+			 }
+			 let p = Point(3, 4);
+			 [p.norm2(), p.add(Point(1, 1)), p.scale(2), p]`,
+			"[25, Point{x: 4, y: 5}, Point{x: 6, y: 8}, Point{x: 6, y: 8}]",
+		},
+		{"struct P { v } impl P { fn get(self) { self.v } } let get = P(7).get; get()", "7"},
+		{"struct P { v } impl P { fn* each(self) { yield self.v; yield self.v + 1; } } [...P(1).each()]", "[1, 2]"},
+		{"struct P { v } let p = P(1); impl P { fn twice(me) { me.v * 2 } } p.twice()", "2"},
+		{"struct P { v } impl P { fn f(self) { 1 } } impl P { fn f(self) { 2 } } P(0).f()", "2"},
+		{"struct P { f } P(x => x + 1).f(1)", "2"},
+		{"struct A { v } struct B { v } impl A { fn name(self) { \"A\" } } impl B { fn name(self) { \"B\" } } [A(1).name(), B(1).name()]", "[A, B]"},
+		{"let f = fn() { struct P { v } impl P { fn get(self) { self.v } } P(5) }; f().get()", "5"},
+		{"[1].nope()", "NameError: ARRAY has no method nope"},
+		{"struct P { v } P(1).nope()", "NameError: P has no method nope"},
+		{"struct P { v } P(1).nope", "NameError: P has no field nope"},
+		{"struct P { v } impl P { fn v(self) { 1 } }", "NameError: P already has a field v"},
+		{"let n = 1; impl n { fn f(self) { 1 } }", "TypeError: cannot impl INTEGER, only struct and enum types"},
+		{"[1].push()", "ArgumentError: wrong number of arguments to ARRAY.push: want=1, got=0"},
+		{"struct P { v } impl P { fn get(self) { self.v } } P(1).get(2)", "ArgumentError: wrong number of arguments to P.get: want=0, got=1"},
+		{"struct P { v } impl P { fn get(self) { self.v } } let g = P(1).get; g(2)", "ArgumentError: wrong number of arguments to P.get: want=0, got=1"},
+		{"{}.has([1])", "TypeError: unusable as hash key: ARRAY"},
+	}
+
+	for _, tt := range tests {
+		evaluated := runEval(t, tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("%q - expected=%q, got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestMethodErrorStack(t *testing.T) {
+	input := `struct P { v }
+impl P { fn bad(self) { self.v + true } }
+P(1).bad()`
+
+	evaluated := runEval(t, input)
+	err, ok := evaluated.(*obj.Error)
+	if !ok {
+		t.Fatalf("expected error, got=%T (%+v)", evaluated, evaluated)
+	}
+	if len(err.Stack) != 1 || err.Stack[0] != "bad at 3:9" {
+		t.Errorf("wrong stack, got=%v", err.Stack)
+	}
+}
+
+func TestRegisteredMethod(t *testing.T) {
+	methods := obj.Methods(obj.INTEGER_OBJ)
+	methods["double"] = &obj.Builtin{Name: "double", Fn: func(args ...obj.Object) obj.Object {
+		return &obj.Integer{Value: args[0].(*obj.Integer).Value * 2}
+	}}
+	defer delete(methods, "double")
+
+	assertOInteger(t, runEval(t, "let n = 21; n.double()"), 42)
+	assertOInteger(t, runEval(t, "let d = 4.double; d()"), 8)
+}
+
+func TestStringMethods(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a,b,,c".split(",")`, "[a, b, , c]"},
+		{`"héé".split("")`, "[h, é, é]"},
+		{`"abc".split("x")`, "[abc]"},
+		{`["a", "b", "c"].join(", ")`, "a, b, c"},
+		{`[].join("-")`, ""},
+		{`"a b c".split(" ").join("_")`, "a_b_c"},
+		{`" \t hi there\n ".trim()`, "hi there"},
+		{`"banana".replace("an", "AN")`, "bANANa"},
+		{`"banana".replace("x", "y")`, "banana"},
+		{`["héllo".contains("él"), "héllo".contains("le"), "x".contains("")]`, "[true, false, true]"},
+		{`["banana".starts_with("ban"), "ba".starts_with("ban")]`, "[true, false]"},
+		{`["banana".ends_with("na"), "banana".ends_with("ba")]`, "[true, false]"},
+		{`["naïve".index_of("v"), "naïve".index_of("x"), "abc".index_of("")]`, "[3, -1, 0]"},
+		{`"héllo".upper()`, "HÉLLO"},
+		{`"ÉTÉ".lower()`, "été"},
+		{`"ab".repeat(3) + "|" + "ab".repeat(0)`, "ababab|"},
+		{`"7".pad_left(3, "0")`, "007"},
+		{`"é".pad_right(3) + "|"`, "é  |"},
+		{`"hello".pad_left(2)`, "hello"},
+		{`"hé".chars()`, "[h, é]"},
+		{`"".chars()`, "[]"},
+		{`"aé".bytes()`, "[97, 195, 169]"},
+		{`["héllo".len(), "".len(), "日本語".len()]`, "[5, 0, 3]"},
+		{`"héllo wörld".substr(6)`, "wörld"},
+		{`"héllo".substr(1, 3)`, "éll"},
+		{`["abc".substr(2, 10), "abc".substr(5), "abc".substr(1, 0)]`, "[c, , ]"},
+		{`"abc".split(1)`, "TypeError: argument to split must be STRING, got INTEGER"},
+		{`["a", 1].join("")`, "TypeError: join needs an array of STRING, got INTEGER at 1"},
+		{`"ab".repeat(-1)`, "ArgumentError: argument to repeat must not be negative, got -1"},
+		{`"ab".repeat(4611686018427387904)`, "ArgumentError: repeat would make a string of over 268435456 bytes"},
+		{`"".repeat(4611686018427387904)`, ""},
+		{`"x".pad_left(9223372036854775807)`, "ArgumentError: pad_left would make a string of over 268435456 bytes"},
+		{`"x".pad_right(9223372036854775807, "é")`, "ArgumentError: pad_right would make a string of over 268435456 bytes"},
+		{`"ab".pad_left(4, "xy")`, "ArgumentError: pad of pad_left must be one character, got \"xy\""},
+		{`"ab".pad_left()`, "ArgumentError: wrong number of arguments to STRING.pad_left: want=1 to 2, got=0"},
+		{`"ab".substr(-1)`, "ArgumentError: argument to substr must not be negative, got -1"},
+		{`"ab".replace("a")`, "ArgumentError: wrong number of arguments to STRING.replace: want=2, got=1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := runEval(t, tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("%q - expected=%q, got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1.5 + 2", "3.5"},
+		{"7 / 2", "3"},
+		{"7.0 / 2", "3.5"},
+		{"0.1 + 0.2", "0.30000000000000004"},
+		{"2.0 * 3", "6.0"},
+		{"-1.5", "-1.5"},
+		{"7.5 % 2", "1.5"},
+		{"1e3", "1000.0"},
+		{"[1 < 1.5, 2.0 >= 2, 2 ** 70 > 1.0]", "[true, true, true]"},
+		{"[1 == 1.0, 1.0 == 1, 1 != 1.5, 0.5 == 0.5]", "[true, true, true, true]"},
+		{`{1: "a"}[1.0]`, "a"},
+		{`let h = {2.0: "x"}; h[2]`, "x"},
+		{`{1.5: "y"}[1.5]`, "y"},
+		{"[0.0 ? 1 : 2, 0.5 ? 1 : 2]", "[2, 1]"},
+		{"2 ** 10", "1024"},
+		{"(-3) ** 3", "-27"},
+		{"2 ** 0", "1"},
+		{"2 ** -2", "0.25"},
+		{"2 ** 0.5", "1.4142135623730951"},
+		{"2 ** 64", "18446744073709551616"},
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"-9223372036854775807 - 2", "-9223372036854775809"},
+		{"4611686018427387904 * 4", "18446744073709551616"},
+		{"(2 ** 64) / (2 ** 32)", "4294967296"},
+		{"(2 ** 64 + 5) % 2 ** 32", "5"},
+		{"2 ** 64 - 2 ** 64 + 1", "1"},
+		{"type(2 ** 63 - 1)", "INTEGER"},
+		{"type(2 ** 63)", "BIGINT"},
+		{"[type(1.0), type(1)]", "[FLOAT, INTEGER]"},
+		{"99999999999999999999", "99999999999999999999"},
+		{"-(2 ** 63) == -9223372036854775807 - 1", "true"},
+		{"[2 ** 64 == 2 ** 64, 2 ** 64 == 18446744073709551616.0]", "[true, true]"},
+		{`{2 ** 64: "big"}[2 ** 64]`, "big"},
+		{"(2 ** 70) * 1.0", "1.1805916207174113e+21"},
+		{"sort([3, 1.5, 2 ** 70, -1])", "[-1, 1.5, 3, 1180591620717411303424]"},
+		{"match (2.0) { 2 => \"two\", _ => \"other\" }", "two"},
+		{"match (-1.5) { -1.5 => \"yes\", _ => \"no\" }", "yes"},
+		{"1.0 / 0", "ArithmeticError: division by zero"},
+		{"(2 ** 64) % 0", "ArithmeticError: division by zero"},
+		{"0 ** -1", "ArithmeticError: division by zero"},
+		{"(-8.0) ** 0.5", "DomainError: -8.0 ** 0.5 is undefined"},
+		{"2 ** (2 ** 64)", "ArithmeticError: 2 ** 18446744073709551616 is too large"},
+		{"[1 ** (2 ** 64), (-1) ** (2 ** 64 + 1)]", "[1, -1]"},
+		{"1.5..3", "TypeError: unknown operator: FLOAT .. INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := runEval(t, tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("%q - expected=%q, got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestMathModule(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[m.PI, m.E]", "[3.141592653589793, 2.718281828459045]"},
+		{"[m.MAX_INT, m.MIN_INT]", "[9223372036854775807, -9223372036854775808]"},
+		{"[m.abs(-3), m.abs(2.5), m.abs(-2.5), m.abs(m.MIN_INT), m.abs(-(2 ** 70))]", "[3, 2.5, 2.5, 9223372036854775808, 1180591620717411303424]"},
+		{"[m.min(3, 1, 2), m.max(3, 1.5), m.min([4, 2 ** 64, -0.5]), m.max([7])]", "[1, 3, -0.5, 7]"},
+		{"[m.clamp(-1, 0, 10), m.clamp(5, 0, 10), m.clamp(11.5, 0, 10)]", "[0, 5, 10]"},
+		{"[m.pow(2, 10), m.pow(2, 100), m.pow(4, 0.5), m.pow(10, -1)]", "[1024, 1267650600228229401496703205376, 2.0, 0.1]"},
+		{"[m.sqrt(16), m.sqrt(2.25), m.sqrt(2 ** 64)]", "[4.0, 1.5, 4294967296.0]"},
+		{"[m.floor(2.7), m.floor(-2.5), m.ceil(2.1), m.ceil(-2.1), m.round(2.5), m.round(-2.5), m.round(7)]", "[2, -3, 3, -2, 3, -3, 7]"},
+		{"[m.floor(1e20), m.round(2 ** 70)]", "[100000000000000000000, 1180591620717411303424]"},
+		{"[m.log(m.E), m.log(8, 2), m.log2(1024), m.log10(0.001), m.exp(0)]", "[1.0, 3.0, 10.0, -3.0, 1.0]"},
+		{"[m.sin(0), m.cos(0), m.tan(0), m.asin(1) == m.PI / 2, m.acos(1), m.atan(0), m.atan2(1, 1) == m.PI / 4]", "[0.0, 1.0, 0.0, true, 0.0, 0.0, true]"},
+		{"[m.gcd(12, 18), m.gcd(-4, 6), m.gcd(0, 5), m.gcd(2 ** 70, 2 ** 65 * 3)]", "[6, 2, 5, 36893488147419103232]"},
+		{"[m.lcm(4, 6), m.lcm(-3, 5), m.lcm(0, 5), m.lcm(2 ** 40, 3 ** 30)]", "[12, 15, 0, 226379693794030958489370624]"},
+		{"m.sqrt(-1)", "DomainError: sqrt(-1) is undefined"},
+		{"m.log(0)", "DomainError: log(0) is undefined"},
+		{"m.log(-2.5)", "DomainError: log(-2.5) is undefined"},
+		{"m.log(8, 1)", "DomainError: log(8, 1) is undefined"},
+		{"m.log10(0)", "DomainError: log10(0) is undefined"},
+		{"m.asin(2)", "DomainError: asin(2) is undefined"},
+		{"m.pow(-8, 1 / 3.0)", "DomainError: -8 ** 0.3333333333333333 is undefined"},
+		{"m.floor(1e308 * 10)", "DomainError: floor(+Inf) is undefined"},
+		{"m.sqrt(\"4\")", "TypeError: argument to sqrt must be a number, got STRING"},
+		{"m.gcd(4.0, 2)", "TypeError: argument to gcd must be INTEGER, got FLOAT"},
+		{"m.min([])", "ArgumentError: min of no numbers"},
+		{"m.clamp(1, 5, 0)", "ArgumentError: bounds of clamp are reversed: 5 > 0"},
+		{"m.abs()", "ArgumentError: wrong number of arguments to abs: want=1, got=0"},
+		{"try { m.sqrt(-4) } catch (e) { e[\"kind\"] }", "DomainError"},
+		{"[m.sign(-3), m.sign(0), m.sign(2.5), m.sign(-(2 ** 70))]", "[-1, 0, 1, -1]"},
+		{"[m.is_even(4), m.is_even(-3), m.is_odd(-3), m.is_odd(2 ** 64 + 1)]", "[true, false, true, true]"},
+		{"[m.factorial(0), m.factorial(5), m.factorial(25)]", "[1, 120, 15511210043330985984000000]"},
+		{"[m.isqrt(0), m.isqrt(15), m.isqrt(16), m.isqrt(m.MAX_INT), m.isqrt(2 ** 100)]", "[0, 3, 4, 3037000499, 1125899906842624]"},
+		{"[m.abs(-3), m.abs(0), m.abs(4), m.sign(4), m.min(2, 5), m.max(2, 5)]", "[3, 0, 4, 1, 2, 5]"},
+		{"[m.factorial(1), m.isqrt(1), m.isqrt(17)]", "[1, 1, 4]"},
+		{"m.factorial(-1)", "DomainError: factorial(-1) is undefined"},
+		{"m.factorial(10000000)", "ArithmeticError: factorial(10000000) is too large"},
+		{"m.isqrt(-4)", "DomainError: isqrt(-4) is undefined"},
+		{"m.nope", "NameError: module math does not export nope"},
+	}
+
+	for _, tt := range tests {
+		evaluated := runEval(t, `import "math" as m; `+tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("%q - expected=%q, got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestRandomModule(t *testing.T) {
+	seed := int64(42)
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"all(map(0..<200, _ => r.int(-2, 2)), x => x >= -2 && x <= 2)", "true"},
+		{"[r.int(3, 3), r.int(-1, -1)]", "[3, -1]"},
+		{"type(r.int(m.MIN_INT, m.MAX_INT))", "INTEGER"},
+		{"all(map(0..<200, _ => r.float()), x => x >= 0 && x < 1)", "true"},
+		{"[r.choice([7]), [1, 2, 3].contains(r.choice(1..3))]", "[7, true]"},
+		{"sort(r.shuffle(1..10)) == sort(1..10)", "true"},
+		{"let xs = [1, 2, 3]; r.shuffle(xs); xs", "[1, 2, 3]"},
+		{"let xs = r.sample(1..10, 4); [xs.len(), unique(xs).len(), all(xs, x => x >= 1 && x <= 10)]", "[4, 4, true]"},
+		{"[r.sample([1, 2], 0), sort(r.sample([1, 2], 2))]", "[[], [1, 2]]"},
+		{"r.seed(5); let a = [r.int(1, 1000), r.float()]; r.seed(5); a == [r.int(1, 1000), r.float()]", "true"},
+		{"r.int(5, 1)", "ArgumentError: bounds of int are reversed: 5 > 1"},
+		{"r.int(1.5, 2)", "TypeError: argument to int must be INTEGER, got FLOAT"},
+		{"r.choice([])", "ArgumentError: choice from no elements"},
+		{"r.choice(5)", "TypeError: cannot iterate over INTEGER"},
+		{"r.sample([1], 2)", "ArgumentError: cannot sample 2 of 1 elements"},
+		{"r.sample([1], -1)", "ArgumentError: argument to sample must not be negative, got -1"},
+	}
+
+	for _, tt := range tests {
+		input := `import "std/random" as r; import "math" as m; ` + tt.input
+		evaluated := runEvalWithOptions(t, input, &obj.Options{Seed: &seed})
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("%q - expected=%q, got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+// Runs with the same seed draw the same numbers, even while another
+// interpreter draws from a generator of its own
+func TestRandomSeed(t *testing.T) {
+	input := `import "std/random" as r; [r.int(1, 1000000), r.float(), r.shuffle(1..8), r.sample(1..100, 3), r.choice("abcdef")]`
+	seed, other := int64(7), int64(8)
+
+	first := runEvalWithOptions(t, input, &obj.Options{Seed: &seed}).Inspect()
+	runEvalWithOptions(t, input, &obj.Options{Seed: &other})
+	second := runEvalWithOptions(t, input, &obj.Options{Seed: &seed}).Inspect()
+	if first != second {
+		t.Errorf("same seed drew %s, then %s", first, second)
+	}
+
+	if third := runEvalWithOptions(t, input, &obj.Options{Seed: &other}).Inspect(); third == first {
+		t.Errorf("seeds %d and %d both drew %s", seed, other, first)
+	}
+}
+
+func TestJSONModule(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`json.parse("{\"b\": 1, \"a\": [true, null, \"x\"]}")`, `{"b": 1, "a": [true, null, x]}`},
+		{`json.parse("[1, 1.0, 2.5e3, -0, 1E-2]")`, "[1, 1.0, 2500.0, 0, 0.01]"},
+		{`json.parse("12345678901234567890123")`, "12345678901234567890123"},
+		{`type(json.parse("9223372036854775808"))`, "BIGINT"},
+		{`json.parse("\"h\\u00e9\\n\"") == "hé\n"`, "true"},
+		{`json.parse(" [ {} , [] ] ")`, "[{}, []]"},
+		{`json.parse("{\"a\": 1, \"a\": 2}")`, `{"a": 2}`},
+		{`json.stringify({"b": [1, 2.5, null], "a": {"t": true}})`, `{"b":[1,2.5,null],"a":{"t":true}}`},
+		{`json.stringify([1.0, 1e21, 2 ** 70])`, "[1.0,1e+21,1180591620717411303424]"},
+		{`json.stringify("<é>\"\n")`, `"<é>\"\n"`},
+		{`json.stringify({"a": [1, {}], "b": []}, 2)`, "{\n  \"a\": [\n    1,\n    {}\n  ],\n  \"b\": []\n}"},
+		{`json.stringify([1], "\t")`, "[\n\t1\n]"},
+		{`json.stringify([1, 2], null)`, "[1,2]"},
+		{`struct P { x, y } json.stringify(P(1, [2]))`, `{"x":1,"y":[2]}`},
+		{`let v = {"n": 12345678901234567890123, "f": 0.1}; json.parse(json.stringify(v)) == v`, "true"},
+		{`let xs = [1]; json.stringify([xs, xs])`, "[[1],[1]]"},
+		{`json.parse("[1, 2")`, "JSONError: invalid JSON: unexpected end of JSON input, at offset 5"},
+		{`json.parse("{\"a\" 1}")`, "JSONError: invalid JSON: invalid character '1' after object key, at offset 6"},
+		{`json.parse("[1,]")`, "JSONError: invalid JSON: invalid character ',' looking for beginning of value, at offset 3"},
+		{`json.parse("1 2")`, "JSONError: invalid JSON: unexpected data after the value, at offset 3"},
+		{`json.parse("")`, "JSONError: invalid JSON: unexpected end of input"},
+		{`json.parse("1e400")`, "JSONError: invalid JSON: number 1e400 is out of range"},
+		{`json.parse(1)`, "TypeError: argument to parse must be STRING, got INTEGER"},
+		{`let xs = [1]; xs.push(xs); json.stringify(xs)`, "JSONError: cannot stringify a cycle, ARRAY contains itself"},
+		{`let xs = []; let h = {"xs": xs}; xs.push(h); json.stringify(h)`, "JSONError: cannot stringify a cycle, HASH contains itself"},
+		{`json.stringify([fn() { 1 }])`, "TypeError: cannot stringify FUNCTION"},
+		{`json.stringify(1..3)`, "TypeError: cannot stringify RANGE"},
+		{`json.stringify({1: 2})`, "TypeError: cannot stringify a hash key of type INTEGER, JSON keys are strings"},
+		{`json.stringify(1e308 * 10)`, "JSONError: cannot stringify +Inf, which JSON has no number for"},
+		{`json.stringify([1], true)`, "TypeError: indent of stringify must be INTEGER or STRING, got BOOLEAN"},
+		{`try { json.parse("{") } catch (e) { e["kind"] }`, "JSONError"},
+	}
+
+	for _, tt := range tests {
+		evaluated := runEval(t, `import "std/json" as json; `+tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("%q - expected=%q, got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+// Writes module files into a new directory, which the caller removes
+func writeModules(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "mkc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, source := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, []byte(source), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestModules(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"lib/math.mk": `
+			export fn square(x) { x * x }
+			export const TAU = 6;
+			export struct Pair { a, b }
+			export enum Sign { Neg, Pos }
+			export let count = 0;
+			export fn bump() { count = count + 1; count }
+			let hidden = 1;
+		`,
+		"lib/twice.mk": `import "math" as m; export fn twice(x) { m.bump(); m.square(x) * 2 }`,
+		"path/util.mk": `export let name = "util";`,
+		"cycle/a.mk":   `import "b" as b; export let x = 1;`,
+		"cycle/b.mk":   `import "a" as a; export let y = 2;`,
+		"broken.mk":    `export let x = y;`,
+		"failing.mk":   `export let x = 1 / 0;`,
+		"self.mk":      `import "self" as s;`,
+	})
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import "lib/math" as m; m.square(4) + m.TAU`, "22"},
+		{`import "lib/math" as m; [m.Pair(1, 2), m.Sign.Pos, m]`, "[Pair{a: 1, b: 2}, Sign.Pos, module lib/math]"},
+		{`import "lib/math" as m; match (m.Sign.Neg) { s => s }`, "Sign.Neg"},
+		{`import "lib/math.mk" as m; m.TAU`, "6"},
+		{`import "lib/math" as m; m.bump(); m.bump(); m.count`, "2"},
+		{`import "lib/math" as m; import "lib/twice" as t; m.bump(); [t.twice(3), m.count]`, "[18, 2]"},
+		{`import "lib/math" as m; import "lib/math" as n; n.bump(); m.count`, "1"},
+		{`import "util" as u; u.name`, "util"},
+		{`fn f() { import "lib/math" as m; m.square(5) } f()`, "25"},
+		{`import "lib/math" as m; m.hidden`, "NameError: module lib/math does not export hidden"},
+		{`import "lib/math" as m; m.hidden()`, "NameError: module lib/math does not export hidden"},
+		{`import "lib/math" as m; m.count = 1`, "TypeError: cannot assign to count of module lib/math, exports are changed by their module only"},
+		{`import "lib/math" as m; m = 1`, "BindingError: cannot assign to constant m, declared at 1:22"},
+		{`import "nope" as m;`, "ImportError: cannot find module nope"},
+		{`import "cycle/a" as a;`, "ImportError: import cycle: cycle/a -> b -> cycle/a"},
+		{`import "self" as s;`, "ImportError: import cycle: self -> self"},
+		{`import "broken" as b;`, "ImportError: in module broken: 1:16: error: identifier not found: y"},
+		{`import "failing" as f;`, "ArithmeticError: division by zero"},
+		{`let r = try { import "failing" as f; 1 } catch (e) { e["kind"] }; r`, "ArithmeticError"},
+	}
+
+	options := &obj.Options{Path: []string{filepath.Join(dir, "path")}}
+	for _, tt := range tests {
+		evaluated := runEvalFile(t, tt.input, filepath.Join(dir, "main.mk"), options)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("%q - expected=%q, got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestImportsStayInside(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"app/lib.mk":       `export let x = 1;`,
+		"app/data.conf":    `secret_token_abc123`,
+		"outside.mk":       `export let y = 2;`,
+		"granted/share.mk": `export let z = 3;`,
+	})
+	defer os.RemoveAll(dir)
+	if err := os.Symlink(filepath.Join(dir, "outside.mk"), filepath.Join(dir, "app", "link.mk")); err != nil {
+		t.Fatal(err)
+	}
+
+	// the sandbox is in force once any directory is granted
+	sandbox := &obj.Options{AllowRead: []string{filepath.Join(dir, "granted")}}
+	tests := []struct {
+		input    string
+		options  *obj.Options
+		expected string
+	}{
+		{`import "lib" as l; l.x`, &obj.Options{}, "1"},
+		{`import "../outside" as o; o.y`, &obj.Options{}, "2"},
+		{`import "link" as o; o.y`, &obj.Options{}, "2"},
+		{`import "DIR/outside.mk" as o; o.y`, &obj.Options{}, "2"},
+		{`import "lib" as l; l.x`, sandbox, "1"},
+		{`import "../outside" as o;`, sandbox, "ImportError: cannot find module ../outside"},
+		{`import "link" as o;`, sandbox, "ImportError: cannot find module link"},
+		{`import "data.conf" as d;`, sandbox, "ImportError: cannot find module data.conf"},
+		{`import "DIR/outside.mk" as o;`, sandbox, "ImportError: cannot find module DIR/outside.mk"},
+		{`import "../granted/share" as g; g.z`, sandbox, "3"},
+		{`import "DIR/granted/share.mk" as g; g.z`, sandbox, "3"},
+	}
+
+	for _, tt := range tests {
+		input := strings.ReplaceAll(tt.input, "DIR", filepath.ToSlash(dir))
+		expected := strings.ReplaceAll(tt.expected, "DIR", filepath.ToSlash(dir))
+		evaluated := runEvalFile(t, input, filepath.Join(dir, "app", "main.mk"), tt.options)
+		if evaluated == nil || evaluated.Inspect() != expected {
+			t.Errorf("%q - expected=%q, got=%+v", input, expected, evaluated)
+		}
+	}
+}
+
+func TestFSModule(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"data/a.txt":     "one\r\ntwo\n",
+		"data/sub/b.txt": "",
+		"secret.txt":     "secret",
+		"out/.keep":      "",
+	})
+	defer os.RemoveAll(dir)
+	if err := os.Symlink(filepath.Join(dir, "secret.txt"), filepath.Join(dir, "data", "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "nowhere"), filepath.Join(dir, "out", "dangling")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`fs.read("DIR/data/a.txt")`, "one\r\ntwo\n"},
+		{`fs.lines("DIR/data/a.txt")`, "[one, two]"},
+		{`fs.lines("DIR/data/sub/b.txt")`, "[]"},
+		{`fs.list("DIR/data")`, "[a.txt, link, sub]"},
+		{`[fs.exists("DIR/data/sub"), fs.exists("DIR/data/none")]`, "[true, false]"},
+		{`fs.write("DIR/out/c.txt", "a\nb"); fs.lines("DIR/out/c.txt")`, "[a, b]"},
+		{`fs.read("DIR/secret.txt")`, "PermissionError: no allow-read capability for DIR/secret.txt"},
+		{`fs.read("DIR/data/../secret.txt")`, "PermissionError: no allow-read capability for DIR/data/../secret.txt"},
+		{`fs.read("DIR/data/link")`, "PermissionError: no allow-read capability for DIR/data/link"},
+		{`fs.exists("DIR/nope")`, "PermissionError: no allow-read capability for DIR/nope"},
+		{`fs.list("DIR")`, "PermissionError: no allow-read capability for DIR"},
+		{`fs.write("DIR/data/c.txt", "")`, "PermissionError: no allow-write capability for DIR/data/c.txt"},
+		{`fs.write("DIR/out/dangling", "")`, "PermissionError: no allow-write capability for DIR/out/dangling"},
+		{`fs.read("DIR/data/none")`, "IOError: cannot read DIR/data/none: no such file or directory"},
+		{`fs.write("DIR/out/new/c.txt", "")`, "IOError: cannot write DIR/out/new/c.txt: no such file or directory"},
+		{`fs.write("DIR/out/d.txt", 1)`, "TypeError: argument to write must be STRING, got INTEGER"},
+		{`try { fs.read("DIR/secret.txt") } catch (e) { e["kind"] }`, "PermissionError"},
+	}
+
+	options := &obj.Options{
+		AllowRead:  []string{filepath.Join(dir, "data"), filepath.Join(dir, "out")},
+		AllowWrite: []string{filepath.Join(dir, "out")},
+	}
+	for _, tt := range tests {
+		input := strings.ReplaceAll(tt.input, "DIR", filepath.ToSlash(dir))
+		expected := strings.ReplaceAll(tt.expected, "DIR", filepath.ToSlash(dir))
+		evaluated := runEvalWithOptions(t, `import "std/fs" as fs; `+input, options)
+		if evaluated == nil || evaluated.Inspect() != expected {
+			t.Errorf("%q - expected=%q, got=%+v", input, expected, evaluated)
+		}
+	}
+
+	// without grants, nothing can be read or written
+	input := strings.ReplaceAll(`import "std/fs" as fs; fs.read("DIR/data/a.txt")`, "DIR", filepath.ToSlash(dir))
+	evaluated := runEval(t, input)
+	if !strings.HasPrefix(evaluated.Inspect(), "PermissionError: no allow-read capability") {
+		t.Errorf("expected a PermissionError without grants, got=%+v", evaluated)
+	}
+}
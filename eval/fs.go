@@ -0,0 +1,231 @@
+package eval
+
+import (
+	"errors"
+	"io/ioutil"
+	obj "mkc/object"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/////////////////
+// File system //
+/////////////////
+
+// Scripts may only touch files inside the directories granted by
+// Options.AllowRead and Options.AllowWrite, or by -allow-read and
+// -allow-write; without grants they cannot touch any.
+
+const (
+	READ_CAPABILITY  = "allow-read"
+	WRITE_CAPABILITY = "allow-write"
+)
+
+func init() {
+	nativeModules[STD_PREFIX+"fs"] = fsModule
+}
+
+// Returns the members of import "std/fs", limited to the directories the
+// interpreter's options grant
+func fsModule(env *obj.Environment) map[string]obj.Object {
+	fs := &fileSystem{options: env.Options()}
+
+	return map[string]obj.Object{
+		"read":   &obj.Builtin{Name: "read", Fn: fs.read},
+		"write":  &obj.Builtin{Name: "write", Fn: fs.write},
+		"lines":  &obj.Builtin{Name: "lines", Fn: fs.lines},
+		"exists": &obj.Builtin{Name: "exists", Fn: fs.exists},
+		"list":   &obj.Builtin{Name: "list", Fn: fs.list},
+	}
+}
+
+type fileSystem struct {
+	options *obj.Options
+}
+
+// Returns the real path of the file named by the argument to function, if
+// a directory granted capability holds it
+func (fs *fileSystem) open(function string, arg obj.Object, capability string) (string, *obj.Error) {
+	name, err := stringArgument(function, arg)
+	if err != nil {
+		return "", err
+	}
+
+	grants := fs.options.AllowRead
+	if capability == WRITE_CAPABILITY {
+		grants = fs.options.AllowWrite
+	}
+
+	if file, ok := grantedPath(grants, name); ok {
+		return file, nil
+	}
+	return "", newOPermissionError(capability, name)
+}
+
+// Returns the real path of the file name, if one of the directories of
+// grants holds it
+func grantedPath(grants []string, name string) (string, bool) {
+	file, err := realPath(name)
+	if err != nil {
+		return "", false
+	}
+	for _, grant := range grants {
+		if grant == "" {
+			continue
+		}
+		dir, err := realPath(grant)
+		if err == nil && isInside(dir, file) {
+			return file, true
+		}
+	}
+	return "", false
+}
+
+// Reports whether options grant any directory, which holds imports to
+// the sandbox as well as std/fs
+func sandboxed(options *obj.Options) bool {
+	return len(options.AllowRead) > 0 || len(options.AllowWrite) > 0
+}
+
+// Returns the absolute form of name with every symlink followed, so that a
+// link cannot lead out of a granted directory. Files that do not exist yet
+// are found through their nearest existing directory.
+func realPath(name string) (string, error) {
+	path, err := filepath.Abs(name)
+	if err != nil {
+		return "", err
+	}
+
+	var missing []string
+	for {
+		real, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(append([]string{real}, missing...)...), nil
+		}
+		// a link to a missing file exists itself, and could lead anywhere
+		if _, lerr := os.Lstat(path); !os.IsNotExist(err) || lerr == nil {
+			return "", err
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return "", err
+		}
+		missing = append([]string{filepath.Base(path)}, missing...)
+		path = parent
+	}
+}
+
+// Reports whether file is dir or is under it
+func isInside(dir string, file string) bool {
+	rel, err := filepath.Rel(dir, file)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Returns an error on a file as an IOError, naming the file as the script did
+func newOIOError(action string, arg obj.Object, err error) *obj.Error {
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+	return newError(IO_ERROR, "cannot %s %s: %s", action, arg.(*obj.String).Value, err)
+}
+
+// read(path) returns the contents of a file
+func (fs *fileSystem) read(args ...obj.Object) obj.Object {
+	if err := checkArgumentCount("read", args, 1); err != nil {
+		return err
+	}
+	file, err := fs.open("read", args[0], READ_CAPABILITY)
+	if err != nil {
+		return err
+	}
+
+	contents, rerr := ioutil.ReadFile(file)
+	if rerr != nil {
+		return newOIOError("read", args[0], rerr)
+	}
+	return &obj.String{Value: string(contents)}
+}
+
+// lines(path) returns the lines of a file, without their line endings
+func (fs *fileSystem) lines(args ...obj.Object) obj.Object {
+	if err := checkArgumentCount("lines", args, 1); err != nil {
+		return err
+	}
+	file, err := fs.open("lines", args[0], READ_CAPABILITY)
+	if err != nil {
+		return err
+	}
+
+	contents, rerr := ioutil.ReadFile(file)
+	if rerr != nil {
+		return newOIOError("read", args[0], rerr)
+	}
+
+	lines := &obj.Array{Elements: []obj.Object{}}
+	text := strings.TrimSuffix(string(contents), "\n")
+	if text == "" {
+		return lines
+	}
+	for _, line := range strings.Split(text, "\n") {
+		lines.Elements = append(lines.Elements, &obj.String{Value: strings.TrimSuffix(line, "\r")})
+	}
+	return lines
+}
+
+// write(path, text) replaces the contents of a file with text, creating
+// the file if needed, but not its directory
+func (fs *fileSystem) write(args ...obj.Object) obj.Object {
+	if err := checkArgumentCount("write", args, 2); err != nil {
+		return err
+	}
+	text, err := stringArgument("write", args[1])
+	if err != nil {
+		return err
+	}
+	file, err := fs.open("write", args[0], WRITE_CAPABILITY)
+	if err != nil {
+		return err
+	}
+
+	if werr := ioutil.WriteFile(file, []byte(text), 0644); werr != nil {
+		return newOIOError("write", args[0], werr)
+	}
+	return ONULL
+}
+
+// exists(path) reports whether a file or directory is at path
+func (fs *fileSystem) exists(args ...obj.Object) obj.Object {
+	if err := checkArgumentCount("exists", args, 1); err != nil {
+		return err
+	}
+	file, err := fs.open("exists", args[0], READ_CAPABILITY)
+	if err != nil {
+		return err
+	}
+
+	_, serr := os.Stat(file)
+	return nativeBoolToBooleanObject(serr == nil)
+}
+
+// list(path) returns the names in a directory, sorted
+func (fs *fileSystem) list(args ...obj.Object) obj.Object {
+	if err := checkArgumentCount("list", args, 1); err != nil {
+		return err
+	}
+	dir, err := fs.open("list", args[0], READ_CAPABILITY)
+	if err != nil {
+		return err
+	}
+
+	infos, rerr := ioutil.ReadDir(dir)
+	if rerr != nil {
+		return newOIOError("list", args[0], rerr)
+	}
+	names := &obj.Array{Elements: []obj.Object{}}
+	for _, info := range infos {
+		names.Elements = append(names.Elements, &obj.String{Value: info.Name()})
+	}
+	return names
+}
@@ -0,0 +1,148 @@
+package eval
+
+import (
+	"mkc/ast"
+	obj "mkc/object"
+	"runtime"
+)
+
+////////////////
+// Generators //
+////////////////
+
+// The body of a generator runs on its own goroutine, handing over to the
+// consumer at each yield, so only one of them runs at any time. The body
+// starts on the first Next, and is suspended at a yield until the next one.
+//
+// Closing a generator early makes its pending yield act as a return, so
+// finally blocks still run. A generator dropped without being closed is
+// abandoned once garbage collected: its goroutine exits without running
+// anything more.
+type generator struct {
+	channels *generatorChannels
+	run      func()
+	started  bool
+	done     bool
+}
+
+// Shared by the generator and its body. The body's goroutine must not
+// refer to the generator itself, so that it can be collected.
+type generatorChannels struct {
+	resume  chan struct{}   // consumer wants the next value
+	yields  chan obj.Object // body yielded a value, closed when it ends
+	stop    chan struct{}   // consumer closed the generator
+	abandon chan struct{}   // generator was garbage collected
+}
+
+// Returns the iterator for a call of a generator function
+func newGenerator(fn *obj.Function, env *obj.Environment) obj.Object {
+	channels := &generatorChannels{
+		resume:  make(chan struct{}),
+		yields:  make(chan obj.Object),
+		stop:    make(chan struct{}),
+		abandon: make(chan struct{}),
+	}
+	env.SetYielder(channels)
+
+	g := &generator{channels: channels}
+	g.run = func() {
+		defer close(channels.yields)
+		if !channels.wait() {
+			return
+		}
+
+		result := unwrapReturnValue(Eval(fn.Body, env))
+		if isError(result) {
+			channels.send(result)
+		}
+	}
+	runtime.SetFinalizer(g, (*generator).abandon)
+
+	return &obj.Sequence{Source: g}
+}
+
+func (g *generator) Next() (obj.Object, bool) {
+	if g.done {
+		return nil, false
+	}
+	if !g.started {
+		g.started = true
+		go g.run()
+	}
+
+	g.channels.resume <- struct{}{}
+	val, ok := <-g.channels.yields
+	if !ok || isError(val) {
+		g.done = true
+	}
+	return val, ok
+}
+
+// Stops a suspended generator, waiting for its body to return
+func (g *generator) Close() {
+	if g.done {
+		return
+	}
+	g.done = true
+
+	if g.started {
+		close(g.channels.stop)
+		for range g.channels.yields {
+		}
+	}
+}
+
+// Lets the goroutine of a collected generator exit
+func (g *generator) abandon() {
+	if g.started && !g.done {
+		close(g.channels.abandon)
+	}
+}
+
+// Called by the body, see obj.Yielder
+func (c *generatorChannels) Yield(val obj.Object) bool {
+	return c.send(val) && c.wait()
+}
+
+// Hands val to the consumer, unless it stopped
+func (c *generatorChannels) send(val obj.Object) bool {
+	select {
+	case c.yields <- val:
+		return true
+	case <-c.stop:
+		return false
+	case <-c.abandon:
+		runtime.Goexit()
+	}
+	return false
+}
+
+// Waits until the consumer wants the next value, or stopped
+func (c *generatorChannels) wait() bool {
+	select {
+	case <-c.resume:
+		return true
+	case <-c.stop:
+		return false
+	case <-c.abandon:
+		runtime.Goexit()
+	}
+	return false
+}
+
+// Suspends the generator until its next value is wanted
+// Once the generator is closed, a yield returns from the body
+func evalYieldStatement(ys *ast.YieldStatement, env *obj.Environment) obj.Object {
+	yielder := env.Yielder()
+	if yielder == nil {
+		return newOYieldError()
+	}
+
+	val := Eval(ys.Value, env)
+	if isError(val) { return val }
+
+	if !yielder.Yield(val) {
+		return &obj.ReturnValue{Value: ONULL}
+	}
+	return nil
+}
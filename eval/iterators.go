@@ -0,0 +1,223 @@
+package eval
+
+import (
+	"mkc/ast"
+	obj "mkc/object"
+)
+
+///////////////
+// Iteration //
+///////////////
+
+// Returns an iterator over an iterable value
+func iterate(o obj.Object) (obj.Iterator, *obj.Error) {
+	iterable, ok := o.(obj.Iterable)
+	if !ok {
+		return nil, newONotIterableError(o)
+	}
+	return iterable.Iter(), nil
+}
+
+// Adds every element of an iterable value to elements
+func appendAll(elements []obj.Object, o obj.Object) ([]obj.Object, *obj.Error) {
+	it, err := iterate(o)
+	if err != nil {
+		return elements, err
+	}
+	defer obj.CloseIterator(it)
+
+	for {
+		val, ok := it.Next()
+		if !ok {
+			return elements, nil
+		}
+		if err, ok := val.(*obj.Error); ok {
+			return elements, err
+		}
+		elements = append(elements, val)
+	}
+}
+
+// Runs the body once for each element, each time in a fresh scope
+// binding the loop pattern, so lets in the body start over every time.
+// Leaving the loop early closes the iterator.
+func evalForStatement(fs *ast.ForStatement, env *obj.Environment) obj.Object {
+	iterable := Eval(fs.Iterable, env)
+	if isError(iterable) { return iterable }
+
+	it, err := iterate(iterable)
+	if err != nil {
+		return err
+	}
+	defer obj.CloseIterator(it)
+
+	for {
+		val, ok := it.Next()
+		if !ok {
+			return nil
+		}
+		if isError(val) { return val }
+
+		loopEnv := obj.NewSlottedEnvironment(env, fs.Slots)
+		if err := bindPattern(fs.Pattern, val, loopEnv, false); err != nil {
+			return err
+		}
+
+		result := Eval(fs.Body, loopEnv)
+		if result != nil && (result.Type() == obj.RETURN_OBJ || isError(result)) {
+			return result
+		}
+	}
+}
+
+//////////////
+// Builtins //
+//////////////
+
+// Registered on init, as they call back into the evaluator, which looks up builtins
+func init() {
+	builtins["range"] = &obj.Builtin{Name: "range", Fn: builtinRange}
+	builtins["map"] = &obj.Builtin{Name: "map", Fn: builtinMap}
+	optionBuiltins["filter"] = builtinFilter
+	builtins["take"] = &obj.Builtin{Name: "take", Fn: builtinTake}
+}
+
+// range(stop), range(start, stop) or range(start, stop, step) makes a lazy
+// range of integers from start, 0 by default, up to but not including stop
+func builtinRange(args ...obj.Object) obj.Object {
+	if err := checkArgumentRange("range", args, 1, 3); err != nil {
+		return err
+	}
+
+	bounds := []int64{0, 0, 1}
+	for i, arg := range args {
+		integer, ok := arg.(*obj.Integer)
+		if !ok {
+			return newOErrorArgumentType("range", obj.INTEGER_OBJ, arg)
+		}
+		bounds[i] = integer.Value
+	}
+
+	if len(args) == 1 {
+		bounds[0], bounds[1] = 0, bounds[0]
+	}
+	if bounds[2] == 0 {
+		return newError(ARGUMENT_ERROR, "range step must not be zero")
+	}
+
+	return &obj.Range{Start: bounds[0], Stop: bounds[1], Step: bounds[2]}
+}
+
+// map(iterable, fn) applies fn to each element
+// Arrays give an array, any other iterable a lazy iterator
+func builtinMap(args ...obj.Object) obj.Object {
+	if err := checkArgumentCount("map", args, 2); err != nil {
+		return err
+	}
+
+	it, err := iterate(args[0])
+	if err != nil {
+		return err
+	}
+	fn := args[1]
+
+	mapped := &derivedIterator{source: it, next: func() (obj.Object, bool) {
+		val, ok := it.Next()
+		if !ok || isError(val) {
+			return val, ok
+		}
+		return applyFunction(fn, []obj.Object{val}), true
+	}}
+
+	return collectIfArray(args[0], mapped)
+}
+
+// filter(iterable, fn) keeps the elements for which fn returns a truthy value
+// Arrays give an array, any other iterable a lazy iterator
+func builtinFilter(options *obj.Options, args ...obj.Object) obj.Object {
+	if err := checkArgumentCount("filter", args, 2); err != nil {
+		return err
+	}
+
+	it, err := iterate(args[0])
+	if err != nil {
+		return err
+	}
+	fn := args[1]
+
+	filtered := &derivedIterator{source: it, next: func() (obj.Object, bool) {
+		for {
+			val, ok := it.Next()
+			if !ok || isError(val) {
+				return val, ok
+			}
+
+			keep := applyFunction(fn, []obj.Object{val})
+			if isError(keep) {
+				return keep, true
+			}
+			holds, cerr := checkCondition(keep, options)
+			if cerr != nil {
+				return cerr, true
+			}
+			if holds {
+				return val, true
+			}
+		}
+	}}
+
+	return collectIfArray(args[0], filtered)
+}
+
+// take(iterable, n) keeps the first n elements, and stops the rest from
+// being computed. Arrays give an array, any other iterable a lazy iterator.
+func builtinTake(args ...obj.Object) obj.Object {
+	if err := checkArgumentCount("take", args, 2); err != nil {
+		return err
+	}
+
+	it, err := iterate(args[0])
+	if err != nil {
+		return err
+	}
+	n, ok := args[1].(*obj.Integer)
+	if !ok {
+		return newOErrorArgumentType("take", obj.INTEGER_OBJ, args[1])
+	}
+
+	left := n.Value
+	taken := &derivedIterator{source: it, next: func() (obj.Object, bool) {
+		if left <= 0 {
+			obj.CloseIterator(it)
+			return nil, false
+		}
+		left -= 1
+		return it.Next()
+	}}
+
+	return collectIfArray(args[0], taken)
+}
+
+// An iterator computing its elements from those of source, which it
+// closes when closed itself
+type derivedIterator struct {
+	source obj.Iterator
+	next   func() (obj.Object, bool)
+}
+
+func (it *derivedIterator) Next() (obj.Object, bool) { return it.next() }
+func (it *derivedIterator) Close() { obj.CloseIterator(it.source) }
+
+// Runs it to the end into an array when source is an array, and wraps
+// it as a lazy iterator otherwise
+func collectIfArray(source obj.Object, it obj.Iterator) obj.Object {
+	if source.Type() != obj.ARRAY_OBJ {
+		return &obj.Sequence{Source: it}
+	}
+
+	elements, err := appendAll([]obj.Object{}, &obj.Sequence{Source: it})
+	if err != nil {
+		return err
+	}
+	return &obj.Array{Elements: elements}
+}
@@ -0,0 +1,274 @@
+package eval
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	obj "mkc/object"
+	"strconv"
+	"strings"
+)
+
+//////////
+// JSON //
+//////////
+
+func init() {
+	nativeModules[STD_PREFIX+"json"] = jsonModule
+}
+
+// Returns the members of import "std/json"
+func jsonModule(env *obj.Environment) map[string]obj.Object {
+	return map[string]obj.Object{
+		"parse":     &obj.Builtin{Name: "parse", Fn: jsonParse},
+		"stringify": &obj.Builtin{Name: "stringify", Fn: jsonStringify},
+	}
+}
+
+// Parsing
+
+// parse(s) returns the value of the JSON text s. Objects become hashes,
+// with their keys in order, and numbers integers, big integers or floats,
+// as they are written: 1 is an integer and 1.0 a float.
+func jsonParse(args ...obj.Object) obj.Object {
+	if err := checkArgumentCount("parse", args, 1); err != nil {
+		return err
+	}
+	text, err := stringArgument("parse", args[0])
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+
+	value, err := decodeJSON(decoder)
+	if err != nil {
+		return err
+	}
+	if _, tokenErr := decoder.Token(); tokenErr != io.EOF {
+		return newError(JSON_ERROR, "invalid JSON: unexpected data after the value, at offset %d", decoder.InputOffset())
+	}
+	return value
+}
+
+// Reads the next value from decoder
+func decodeJSON(decoder *json.Decoder) (obj.Object, *obj.Error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, newOJSONSyntaxError(err, decoder)
+	}
+
+	switch token := token.(type) {
+	case nil:
+		return ONULL, nil
+	case bool:
+		return nativeBoolToBooleanObject(token), nil
+	case string:
+		return &obj.String{Value: token}, nil
+	case json.Number:
+		return jsonNumber(token)
+
+	case json.Delim:
+		if token == '[' {
+			array := &obj.Array{Elements: []obj.Object{}}
+			for decoder.More() {
+				element, err := decodeJSON(decoder)
+				if err != nil {
+					return nil, err
+				}
+				array.Elements = append(array.Elements, element)
+			}
+			_, err := decoder.Token()
+			return array, newOJSONSyntaxError(err, decoder)
+		}
+
+		hash := obj.NewHash()
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, newOJSONSyntaxError(err, decoder)
+			}
+			value, jerr := decodeJSON(decoder)
+			if jerr != nil {
+				return nil, jerr
+			}
+			hash.Set(&obj.String{Value: key.(string)}, value)
+		}
+		_, err := decoder.Token()
+		return hash, newOJSONSyntaxError(err, decoder)
+	}
+
+	return nil, newError(JSON_ERROR, "invalid JSON: unexpected %v", token)
+}
+
+// Returns a number without a fraction or an exponent as an integer, of any
+// size, and others as a float
+func jsonNumber(n json.Number) (obj.Object, *obj.Error) {
+	if !strings.ContainsAny(string(n), ".eE") {
+		if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+			return &obj.Integer{Value: i}, nil
+		}
+		if b, ok := new(big.Int).SetString(string(n), 10); ok {
+			return newInteger(b), nil
+		}
+	}
+	// the decoder only lets valid numbers through, but they may overflow
+	f, err := strconv.ParseFloat(string(n), 64)
+	if err != nil {
+		return nil, newError(JSON_ERROR, "invalid JSON: number %s is out of range", n)
+	}
+	return &obj.Float{Value: f}, nil
+}
+
+// Returns nil for a nil err, so it can wrap the result of a read
+func newOJSONSyntaxError(err error, decoder *json.Decoder) *obj.Error {
+	var syntax *json.SyntaxError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &syntax):
+		return newError(JSON_ERROR, "invalid JSON: %s, at offset %d", syntax, syntax.Offset)
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		return newError(JSON_ERROR, "invalid JSON: unexpected end of input")
+	}
+	return newError(JSON_ERROR, "invalid JSON: %s, at offset %d", err, decoder.InputOffset())
+}
+
+// Stringifying
+
+// stringify(value, indent) returns value as JSON text. Hashes need string
+// keys, and structs become objects of their fields. Without an indent the
+// text is on one line; indent is a number of spaces or a string.
+func jsonStringify(args ...obj.Object) obj.Object {
+	if err := checkArgumentRange("stringify", args, 1, 2); err != nil {
+		return err
+	}
+
+	s := &jsonStringifier{inside: map[obj.Object]bool{}}
+	if len(args) == 2 {
+		switch indent := args[1].(type) {
+		case *obj.Integer:
+			n, err := countArgument("stringify", indent)
+			if err != nil {
+				return err
+			}
+			s.indent = strings.Repeat(" ", n)
+		case *obj.String:
+			s.indent = indent.Value
+		case *obj.Null:
+		default:
+			return newError(TYPE_ERROR, "indent of stringify must be INTEGER or STRING, got %s", indent.Type())
+		}
+	}
+
+	if err := s.write(args[0], 0); err != nil {
+		return err
+	}
+	return &obj.String{Value: s.out.String()}
+}
+
+type jsonStringifier struct {
+	out    bytes.Buffer
+	indent string
+	inside map[obj.Object]bool // arrays, hashes and structs being written
+}
+
+// Writes value at a depth of nested arrays and objects
+func (s *jsonStringifier) write(value obj.Object, depth int) *obj.Error {
+	switch value := value.(type) {
+	case *obj.Null:
+		s.out.WriteString("null")
+	case *obj.Boolean:
+		s.out.WriteString(strconv.FormatBool(value.Value))
+	case *obj.Integer, *obj.BigInt:
+		s.out.WriteString(value.Inspect())
+	case *obj.Float:
+		if strings.ContainsAny(value.Inspect(), "IN") {
+			return newError(JSON_ERROR, "cannot stringify %s, which JSON has no number for", value.Inspect())
+		}
+		s.out.WriteString(value.Inspect())
+	case *obj.String:
+		s.writeString(value.Value)
+
+	case *obj.Array, *obj.Hash, *obj.Struct:
+		if s.inside[value] {
+			return newError(JSON_ERROR, "cannot stringify a cycle, %s contains itself", typeName(value))
+		}
+		s.inside[value] = true
+		defer delete(s.inside, value)
+		return s.writeContainer(value, depth)
+
+	default:
+		return newError(TYPE_ERROR, "cannot stringify %s", typeName(value))
+	}
+	return nil
+}
+
+// Writes an array, or an object from a hash or a struct
+func (s *jsonStringifier) writeContainer(value obj.Object, depth int) *obj.Error {
+	var keys []obj.Object
+	var values []obj.Object
+	open, close := "{", "}"
+
+	switch value := value.(type) {
+	case *obj.Array:
+		values = value.Elements
+		open, close = "[", "]"
+	case *obj.Hash:
+		for _, pair := range value.Pairs {
+			if _, ok := pair.Key.(*obj.String); !ok {
+				return newError(TYPE_ERROR, "cannot stringify a hash key of type %s, JSON keys are strings", pair.Key.Type())
+			}
+			keys = append(keys, pair.Key)
+			values = append(values, pair.Value)
+		}
+	case *obj.Struct:
+		for i, field := range value.StructType.Fields {
+			keys = append(keys, &obj.String{Value: field})
+			values = append(values, value.Values[i])
+		}
+	}
+
+	s.out.WriteString(open)
+	for i, v := range values {
+		if i > 0 {
+			s.out.WriteString(",")
+		}
+		s.newline(depth + 1)
+		if keys != nil {
+			s.writeString(keys[i].(*obj.String).Value)
+			s.out.WriteString(":")
+			if s.indent != "" {
+				s.out.WriteString(" ")
+			}
+		}
+		if err := s.write(v, depth+1); err != nil {
+			return err
+		}
+	}
+	if len(values) > 0 {
+		s.newline(depth)
+	}
+	s.out.WriteString(close)
+	return nil
+}
+
+// Starts a line at depth, when indenting
+func (s *jsonStringifier) newline(depth int) {
+	if s.indent != "" {
+		s.out.WriteString("\n")
+		s.out.WriteString(strings.Repeat(s.indent, depth))
+	}
+}
+
+// Writes a quoted string, escaping only what JSON requires
+func (s *jsonStringifier) writeString(value string) {
+	encoder := json.NewEncoder(&s.out)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(value)
+	// Encode ends the value with a newline
+	s.out.Truncate(s.out.Len() - 1)
+}
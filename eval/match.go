@@ -0,0 +1,211 @@
+package eval
+
+import (
+	"fmt"
+	"mkc/ast"
+	obj "mkc/object"
+)
+
+//////////////
+// Matching //
+//////////////
+
+// A name bound by a pattern, and its value
+type capture struct {
+	name  *ast.Identifier
+	value obj.Object
+}
+
+// Evaluates the body of the first arm whose pattern matches the subject
+// and whose guard holds. Each arm binds its captures in its own scope.
+func evalMatchExpression(me *ast.MatchExpression, env *obj.Environment) obj.Object {
+	subject := Eval(me.Subject, env)
+	if isError(subject) { return subject }
+
+	for _, arm := range me.Arms {
+		captures, why := destructure(arm.Pattern, subject, env, nil)
+		if why != nil {
+			continue
+		}
+
+		armEnv := obj.NewSlottedEnvironment(env, arm.Slots)
+		bindCaptures(armEnv, captures, false)
+
+		if arm.Guard != nil {
+			guard := Eval(arm.Guard, armEnv)
+			if isError(guard) { return guard }
+
+			holds, err := evalCondition(guard, armEnv)
+			if err != nil { return err }
+			if !holds {
+				continue
+			}
+		}
+
+		return Eval(arm.Body, armEnv)
+	}
+
+	return newONoMatchError(subject, me.Token.Position())
+}
+
+// Destructures val into the names of pattern, in the current scope
+// Nothing is bound unless the whole value has the shape of the pattern
+func bindPattern(pattern ast.Pattern, val obj.Object, env *obj.Environment, constant bool) *obj.Error {
+	captures, why := destructure(pattern, val, env, nil)
+	if why != nil {
+		return newODestructureError(pattern, why.String())
+	}
+
+	bindCaptures(env, captures, constant)
+	return nil
+}
+
+func bindCaptures(env *obj.Environment, captures []capture, constant bool) {
+	for _, c := range captures {
+		declare(env, c.name, c.value, obj.Binding{Token: c.name.Token, Const: constant})
+	}
+}
+
+// Why a value does not have the shape of a pattern. Its message is only
+// made when an error reports it, not for every match arm that fails.
+type mismatch struct {
+	format string
+	args   []interface{}
+}
+
+func mismatchf(format string, args ...interface{}) *mismatch {
+	return &mismatch{format: format, args: args}
+}
+
+func (m *mismatch) String() string {
+	return fmt.Sprintf(m.format, m.args...)
+}
+
+// A value printed by Inspect when a message is made
+type inspected struct {
+	value obj.Object
+}
+
+func (i inspected) String() string {
+	return i.value.Inspect()
+}
+
+// Matches val against pattern, adding the names it binds to captures.
+// Returns why val does not have the shape of pattern, or nil if it does.
+func destructure(pattern ast.Pattern, val obj.Object, env *obj.Environment, captures []capture) ([]capture, *mismatch) {
+	switch p := pattern.(type) {
+	case *ast.WildcardPattern:
+		return captures, nil
+
+	case *ast.BindingPattern:
+		return append(captures, capture{name: p.Name, value: val}), nil
+
+	case *ast.LiteralPattern:
+		literal := Eval(p.Value, env)
+		if !literal.Equals(val) {
+			return captures, mismatchf("expected %s, got %s", inspected{literal}, inspected{val})
+		}
+		return captures, nil
+
+	case *ast.ArrayPattern:
+		return destructureArray(p, val, env, captures)
+
+	case *ast.HashPattern:
+		return destructureHash(p, val, env, captures)
+
+	case *ast.VariantPattern:
+		return destructureVariant(p, val, env, captures)
+	}
+
+	return captures, mismatchf("unknown pattern %s", pattern)
+}
+
+// Matches elements in order, the rest binds whatever is left as an array
+func destructureArray(p *ast.ArrayPattern, val obj.Object, env *obj.Environment, captures []capture) ([]capture, *mismatch) {
+	array, ok := val.(*obj.Array)
+	if !ok {
+		return captures, mismatchf("expected %s, got %s", obj.ARRAY_OBJ, val.Type())
+	}
+
+	n, got := len(p.Elements), len(array.Elements)
+	if p.Rest == nil && got != n {
+		return captures, mismatchf("expected %d elements, got %d", n, got)
+	}
+	if got < n {
+		return captures, mismatchf("expected at least %d elements, got %d", n, got)
+	}
+
+	var why *mismatch
+	for i, element := range p.Elements {
+		if captures, why = destructure(element, array.Elements[i], env, captures); why != nil {
+			return captures, why
+		}
+	}
+
+	if p.Rest != nil {
+		rest := append([]obj.Object{}, array.Elements[n:]...)
+		return destructure(p.Rest, &obj.Array{Elements: rest}, env, captures)
+	}
+
+	return captures, nil
+}
+
+// Matches the listed keys, other keys of the hash are ignored
+func destructureHash(p *ast.HashPattern, val obj.Object, env *obj.Environment, captures []capture) ([]capture, *mismatch) {
+	hash, ok := val.(*obj.Hash)
+	if !ok {
+		return captures, mismatchf("expected %s, got %s", obj.HASH_OBJ, val.Type())
+	}
+
+	var why *mismatch
+	for i, keyNode := range p.Keys {
+		key, ok := Eval(keyNode, env).(obj.Hashable)
+		if !ok {
+			return captures, mismatchf("unusable key %s", keyNode)
+		}
+
+		value, ok := hash.Get(key)
+		if !ok {
+			return captures, mismatchf("missing key %s", keyNode)
+		}
+
+		if captures, why = destructure(p.Values[i], value, env, captures); why != nil {
+			return captures, why
+		}
+	}
+
+	return captures, nil
+}
+
+// Matches a value of the variant, and its fields when the pattern lists them
+func destructureVariant(p *ast.VariantPattern, val obj.Object, env *obj.Environment, captures []capture) ([]capture, *mismatch) {
+	et, ok := Eval(p.Enum, env).(*obj.EnumType)
+	if !ok {
+		return captures, mismatchf("%s is not an enum", p.Enum.Value)
+	}
+
+	variant := et.Variant(p.Variant.Value)
+	if variant == nil {
+		return captures, mismatchf("%s has no variant %s", et.Name, p.Variant.Value)
+	}
+
+	value, ok := val.(*obj.EnumValue)
+	if !ok || value.Variant != variant {
+		return captures, mismatchf("expected %s, got %s", variant.Inspect(), inspected{val})
+	}
+	if p.Fields == nil {
+		return captures, nil
+	}
+	if len(p.Fields) != len(value.Values) {
+		return captures, mismatchf("expected %d fields, got %d", len(p.Fields), len(value.Values))
+	}
+
+	var why *mismatch
+	for i, field := range p.Fields {
+		if captures, why = destructure(field, value.Values[i], env, captures); why != nil {
+			return captures, why
+		}
+	}
+
+	return captures, nil
+}
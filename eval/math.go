@@ -0,0 +1,342 @@
+package eval
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"math/bits"
+	obj "mkc/object"
+)
+
+//////////
+// Math //
+//////////
+
+func init() {
+	nativeModules["math"] = mathModule
+}
+
+// Returns the members of import "math"
+func mathModule(env *obj.Environment) map[string]obj.Object {
+	return map[string]obj.Object{
+		"PI":      &obj.Float{Value: math.Pi},
+		"E":       &obj.Float{Value: math.E},
+		"MAX_INT": &obj.Integer{Value: math.MaxInt64},
+		"MIN_INT": &obj.Integer{Value: math.MinInt64},
+
+		"abs":       &obj.Builtin{Name: "abs", Fn: mathAbs},
+		"min":       &obj.Builtin{Name: "min", Fn: mathMin},
+		"max":       &obj.Builtin{Name: "max", Fn: mathMax},
+		"clamp":     &obj.Builtin{Name: "clamp", Fn: mathClamp},
+		"pow":       &obj.Builtin{Name: "pow", Fn: mathPow},
+		"floor":     roundingFunction("floor", math.Floor),
+		"ceil":      roundingFunction("ceil", math.Ceil),
+		"round":     roundingFunction("round", math.Round),
+		"gcd":       &obj.Builtin{Name: "gcd", Fn: mathGcd},
+		"lcm":       &obj.Builtin{Name: "lcm", Fn: mathLcm},
+		"sign":      &obj.Builtin{Name: "sign", Fn: mathSign},
+		"is_even":   &obj.Builtin{Name: "is_even", Fn: mathIsEven},
+		"is_odd":    &obj.Builtin{Name: "is_odd", Fn: mathIsOdd},
+		"factorial": &obj.Builtin{Name: "factorial", Fn: mathFactorial},
+		"isqrt":     &obj.Builtin{Name: "isqrt", Fn: mathIsqrt},
+		"log":       &obj.Builtin{Name: "log", Fn: mathLog},
+
+		"sqrt":  floatFunction("sqrt", math.Sqrt, func(x float64) bool { return x >= 0 }),
+		"log2":  floatFunction("log2", math.Log2, func(x float64) bool { return x > 0 }),
+		"log10": floatFunction("log10", math.Log10, func(x float64) bool { return x > 0 }),
+		"exp":   floatFunction("exp", math.Exp, nil),
+		"sin":   floatFunction("sin", math.Sin, nil),
+		"cos":   floatFunction("cos", math.Cos, nil),
+		"tan":   floatFunction("tan", math.Tan, nil),
+		"asin":  floatFunction("asin", math.Asin, func(x float64) bool { return -1 <= x && x <= 1 }),
+		"acos":  floatFunction("acos", math.Acos, func(x float64) bool { return -1 <= x && x <= 1 }),
+		"atan":  floatFunction("atan", math.Atan, nil),
+		"atan2": &obj.Builtin{Name: "atan2", Fn: mathAtan2},
+	}
+}
+
+// Errors unless every argument is a number
+func checkNumberArguments(name string, args []obj.Object) *obj.Error {
+	for _, arg := range args {
+		if !isNumber(arg) {
+			return newOErrorNumberArgument(name, arg)
+		}
+	}
+	return nil
+}
+
+// Errors unless every argument is an integer, of any size
+func checkIntegerArguments(name string, args []obj.Object) *obj.Error {
+	for _, arg := range args {
+		switch arg.(type) {
+		case *obj.Integer, *obj.BigInt:
+		default:
+			return newOErrorArgumentType(name, obj.INTEGER_OBJ, arg)
+		}
+	}
+	return nil
+}
+
+// Makes a builtin of one number that returns a float, and raises a domain
+// error for numbers that defined does not hold for, if it is given
+func floatFunction(name string, fn func(float64) float64, defined func(float64) bool) *obj.Builtin {
+	return &obj.Builtin{Name: name, Fn: func(args ...obj.Object) obj.Object {
+		if err := checkArgumentCount(name, args, 1); err != nil {
+			return err
+		}
+		if err := checkNumberArguments(name, args); err != nil {
+			return err
+		}
+		x := toFloat(args[0])
+		if defined != nil && !defined(x) {
+			return newODomainError(fmt.Sprintf("%s(%s)", name, args[0].Inspect()))
+		}
+		return &obj.Float{Value: fn(x)}
+	}}
+}
+
+// Makes a builtin that rounds a float to an integer with fn, and returns
+// integers as they are
+func roundingFunction(name string, fn func(float64) float64) *obj.Builtin {
+	return &obj.Builtin{Name: name, Fn: func(args ...obj.Object) obj.Object {
+		if err := checkArgumentCount(name, args, 1); err != nil {
+			return err
+		}
+		if err := checkNumberArguments(name, args); err != nil {
+			return err
+		}
+		f, ok := args[0].(*obj.Float)
+		if !ok {
+			return args[0]
+		}
+		if math.IsNaN(f.Value) || math.IsInf(f.Value, 0) {
+			return newODomainError(fmt.Sprintf("%s(%s)", name, f.Inspect()))
+		}
+		rounded := fn(f.Value)
+		if rounded >= math.MinInt64 && rounded < -math.MinInt64 {
+			return &obj.Integer{Value: int64(rounded)}
+		}
+		n, _ := big.NewFloat(rounded).Int(nil)
+		return newInteger(n)
+	}}
+}
+
+// abs(x) returns x without its sign
+func mathAbs(args ...obj.Object) obj.Object {
+	if err := checkArgumentCount("abs", args, 1); err != nil {
+		return err
+	}
+	switch x := args[0].(type) {
+	case *obj.Integer:
+		if x.Value >= 0 {
+			return x
+		}
+		return evalMinusPrefixOperatorExpression(x)
+	case *obj.Float:
+		return &obj.Float{Value: math.Abs(x.Value)}
+	case *obj.BigInt:
+		return &obj.BigInt{Value: new(big.Int).Abs(x.Value)}
+	}
+	return newOErrorNumberArgument("abs", args[0])
+}
+
+// min(x, ...) returns the least of its arguments, or of the elements of
+// an array given alone
+func mathMin(args ...obj.Object) obj.Object {
+	return extremum("min", args, -1)
+}
+
+// max(x, ...) returns the greatest of its arguments, or of the elements of
+// an array given alone
+func mathMax(args ...obj.Object) obj.Object {
+	return extremum("max", args, 1)
+}
+
+// Returns the first number that no other compares to as sign
+func extremum(name string, args []obj.Object, sign int) obj.Object {
+	if len(args) == 1 {
+		if array, ok := args[0].(*obj.Array); ok {
+			args = array.Elements
+		}
+	}
+	if len(args) == 0 {
+		return newError(ARGUMENT_ERROR, "%s of no numbers", name)
+	}
+	if err := checkNumberArguments(name, args); err != nil {
+		return err
+	}
+
+	result := args[0]
+	for _, arg := range args[1:] {
+		if compareNumbers(arg, result) == sign {
+			result = arg
+		}
+	}
+	return result
+}
+
+// clamp(x, lo, hi) returns x, moved into lo..hi
+func mathClamp(args ...obj.Object) obj.Object {
+	if err := checkArgumentCount("clamp", args, 3); err != nil {
+		return err
+	}
+	if err := checkNumberArguments("clamp", args); err != nil {
+		return err
+	}
+	x, lo, hi := args[0], args[1], args[2]
+	if compareNumbers(lo, hi) > 0 {
+		return newError(ARGUMENT_ERROR, "bounds of clamp are reversed: %s > %s", lo.Inspect(), hi.Inspect())
+	}
+
+	switch {
+	case compareNumbers(x, lo) < 0:
+		return lo
+	case compareNumbers(x, hi) > 0:
+		return hi
+	}
+	return x
+}
+
+// pow(x, y) returns x ** y
+func mathPow(args ...obj.Object) obj.Object {
+	if err := checkArgumentCount("pow", args, 2); err != nil {
+		return err
+	}
+	if err := checkNumberArguments("pow", args); err != nil {
+		return err
+	}
+	return power(args[0], args[1])
+}
+
+// gcd(a, b) returns the greatest common divisor of two integers, which is
+// never negative
+func mathGcd(args ...obj.Object) obj.Object {
+	if err := checkArgumentCount("gcd", args, 2); err != nil {
+		return err
+	}
+	if err := checkIntegerArguments("gcd", args); err != nil {
+		return err
+	}
+	return newInteger(new(big.Int).GCD(nil, nil, toBig(args[0]), toBig(args[1])))
+}
+
+// lcm(a, b) returns the least common multiple of two integers, which is
+// never negative
+func mathLcm(args ...obj.Object) obj.Object {
+	if err := checkArgumentCount("lcm", args, 2); err != nil {
+		return err
+	}
+	if err := checkIntegerArguments("lcm", args); err != nil {
+		return err
+	}
+	a, b := toBig(args[0]), toBig(args[1])
+	if a.Sign() == 0 || b.Sign() == 0 {
+		return &obj.Integer{Value: 0}
+	}
+	gcd := new(big.Int).GCD(nil, nil, a, b)
+	lcm := new(big.Int).Mul(new(big.Int).Quo(a, gcd), b)
+	return newInteger(lcm.Abs(lcm))
+}
+
+// sign(x) returns -1, 0 or 1, by the sign of x
+func mathSign(args ...obj.Object) obj.Object {
+	if err := checkArgumentCount("sign", args, 1); err != nil {
+		return err
+	}
+	if err := checkNumberArguments("sign", args); err != nil {
+		return err
+	}
+	return &obj.Integer{Value: int64(compareNumbers(args[0], &obj.Integer{}))}
+}
+
+// is_even(n) checks if the integer n is divisible by 2
+func mathIsEven(args ...obj.Object) obj.Object {
+	if err := checkArgumentCount("is_even", args, 1); err != nil {
+		return err
+	}
+	if err := checkIntegerArguments("is_even", args); err != nil {
+		return err
+	}
+	return nativeBoolToBooleanObject(toBig(args[0]).Bit(0) == 0)
+}
+
+// is_odd(n) checks if the integer n is not divisible by 2
+func mathIsOdd(args ...obj.Object) obj.Object {
+	if err := checkArgumentCount("is_odd", args, 1); err != nil {
+		return err
+	}
+	if err := checkIntegerArguments("is_odd", args); err != nil {
+		return err
+	}
+	return nativeBoolToBooleanObject(toBig(args[0]).Bit(0) == 1)
+}
+
+// factorial(n) returns 1 * 2 * ... * n
+func mathFactorial(args ...obj.Object) obj.Object {
+	if err := checkArgumentCount("factorial", args, 1); err != nil {
+		return err
+	}
+	n, ok := args[0].(*obj.Integer)
+	if !ok {
+		return newOErrorArgumentType("factorial", obj.INTEGER_OBJ, args[0])
+	}
+	if n.Value < 0 {
+		return newODomainError(fmt.Sprintf("factorial(%d)", n.Value))
+	}
+	// n! has fewer than n * log2(n) bits
+	if n.Value > 1 && n.Value > MAX_INTEGER_BITS/int64(bits.Len64(uint64(n.Value))) {
+		return newError(ARITHMETIC_ERROR, "factorial(%d) is too large", n.Value)
+	}
+	return newInteger(new(big.Int).MulRange(1, n.Value))
+}
+
+// isqrt(n) returns the largest integer whose square is at most n
+func mathIsqrt(args ...obj.Object) obj.Object {
+	if err := checkArgumentCount("isqrt", args, 1); err != nil {
+		return err
+	}
+	if err := checkIntegerArguments("isqrt", args); err != nil {
+		return err
+	}
+	n := toBig(args[0])
+	if n.Sign() < 0 {
+		return newODomainError(fmt.Sprintf("isqrt(%s)", args[0].Inspect()))
+	}
+	return newInteger(new(big.Int).Sqrt(n))
+}
+
+// log(x) returns the natural logarithm of x, and log(x, base) its
+// logarithm in base
+func mathLog(args ...obj.Object) obj.Object {
+	if err := checkArgumentRange("log", args, 1, 2); err != nil {
+		return err
+	}
+	if err := checkNumberArguments("log", args); err != nil {
+		return err
+	}
+
+	x := toFloat(args[0])
+	if x <= 0 {
+		return newODomainError(fmt.Sprintf("log(%s)", args[0].Inspect()))
+	}
+	if len(args) == 1 {
+		return &obj.Float{Value: math.Log(x)}
+	}
+
+	base := toFloat(args[1])
+	if base <= 0 || base == 1 {
+		return newODomainError(fmt.Sprintf("log(%s, %s)", args[0].Inspect(), args[1].Inspect()))
+	}
+	return &obj.Float{Value: math.Log(x) / math.Log(base)}
+}
+
+// atan2(y, x) returns the angle of the point (x, y) from the x axis
+func mathAtan2(args ...obj.Object) obj.Object {
+	if err := checkArgumentCount("atan2", args, 2); err != nil {
+		return err
+	}
+	if err := checkNumberArguments("atan2", args); err != nil {
+		return err
+	}
+	return &obj.Float{Value: math.Atan2(toFloat(args[0]), toFloat(args[1]))}
+}
@@ -0,0 +1,230 @@
+package eval
+
+import (
+	"mkc/ast"
+	obj "mkc/object"
+	"strings"
+)
+
+/////////////
+// Methods //
+/////////////
+
+// Handles value.method(ARGUMENTS)
+// A field holding a function is called as is, without the receiver, and so
+// is a variant, as in Shape.Circle(1)
+func evalMethodCallExpression(mc *ast.MethodCallExpression, env *obj.Environment) obj.Object {
+	receiver := Eval(mc.Object, env)
+	if isError(receiver) { return receiver }
+
+	args := evalExpressions(mc.Arguments, env)
+	if len(args) == 1 && isError(args[0]) { return args[0] }
+
+	name := mc.Method.Value
+	var function obj.Object
+	var result obj.Object
+
+	if field, ok := member(receiver, name); ok {
+		function = field
+		result = applyFunction(function, args)
+	} else if method, ok := obj.LookupMethod(receiver, name); ok {
+		function = method
+		result = callMethod(receiver, name, method, args)
+	} else {
+		return newOMethodError(receiver, name)
+	}
+
+	return addStackFrame(result, function, mc.Token)
+}
+
+// Calls a method with its receiver as first argument
+func callMethod(receiver obj.Object, name string, method obj.Object, args []obj.Object) obj.Object {
+	if fn, ok := method.(*obj.Function); ok && len(args)+1 != len(fn.Parameters) {
+		return newOMethodArgumentCountError(receiver, name, len(fn.Parameters)-1, len(args))
+	}
+	return applyFunction(method, append([]obj.Object{receiver}, args...))
+}
+
+// Adds methods to a struct or enum type, as closures over the current scope
+func evalImplStatement(is *ast.ImplStatement, env *obj.Environment) obj.Object {
+	target := Eval(is.Name, env)
+	if isError(target) { return target }
+
+	var methods *obj.MethodTable
+	var st *obj.StructType
+	switch t := target.(type) {
+	case *obj.StructType:
+		methods, st = &t.Methods, t
+	case *obj.EnumType:
+		methods = &t.Methods
+	default:
+		return newError(TYPE_ERROR, "cannot impl %s, only struct and enum types", target.Type())
+	}
+
+	if *methods == nil {
+		*methods = obj.MethodTable{}
+	}
+	for _, m := range is.Methods {
+		if st != nil && st.FieldIndex(m.Name.Value) >= 0 {
+			return newError(NAME_ERROR, "%s already has a field %s", st.Name, m.Name.Value)
+		}
+		(*methods)[m.Name.Value] = newFunction(m.Function, env)
+	}
+	return nil
+}
+
+/////////////////////
+// Builtin methods //
+/////////////////////
+
+func init() {
+	array := obj.Methods(obj.ARRAY_OBJ)
+	array["len"] = &obj.Builtin{Name: "len", Fn: arrayLen}
+	array["push"] = &obj.Builtin{Name: "push", Fn: arrayPush}
+	array["pop"] = &obj.Builtin{Name: "pop", Fn: arrayPop}
+	array["contains"] = &obj.Builtin{Name: "contains", Fn: arrayContains}
+	array["join"] = &obj.Builtin{Name: "join", Fn: arrayJoin}
+
+	hash := obj.Methods(obj.HASH_OBJ)
+	hash["len"] = &obj.Builtin{Name: "len", Fn: hashLen}
+	hash["keys"] = &obj.Builtin{Name: "keys", Fn: hashKeys}
+	hash["values"] = &obj.Builtin{Name: "values", Fn: hashValues}
+	hash["has"] = &obj.Builtin{Name: "has", Fn: hashHas}
+}
+
+// Errors if a builtin method did not get the number of arguments it
+// takes, not counting the receiver, which args starts with
+func checkMethodArgumentCount(name string, args []obj.Object, want int) *obj.Error {
+	if len(args)-1 != want {
+		return newOMethodArgumentCountError(args[0], name, want, len(args)-1)
+	}
+	return nil
+}
+
+// Errors if a builtin method did not get from min to max arguments, not
+// counting the receiver
+func checkMethodArgumentRange(name string, args []obj.Object, min int, max int) *obj.Error {
+	if got := len(args) - 1; got < min || got > max {
+		return newError(ARGUMENT_ERROR, "wrong number of arguments to %s.%s: want=%d to %d, got=%d",
+			typeName(args[0]), name, min, max, got)
+	}
+	return nil
+}
+
+// Arrays
+
+// xs.len() returns the number of elements
+func arrayLen(args ...obj.Object) obj.Object {
+	if err := checkMethodArgumentCount("len", args, 0); err != nil {
+		return err
+	}
+	return &obj.Integer{Value: int64(len(args[0].(*obj.Array).Elements))}
+}
+
+// xs.push(x) adds x at the end of xs, and returns xs
+func arrayPush(args ...obj.Object) obj.Object {
+	if err := checkMethodArgumentCount("push", args, 1); err != nil {
+		return err
+	}
+	array := args[0].(*obj.Array)
+	array.Elements = append(array.Elements, args[1])
+	return array
+}
+
+// xs.pop() removes the last element and returns it, or null if xs is empty
+func arrayPop(args ...obj.Object) obj.Object {
+	if err := checkMethodArgumentCount("pop", args, 0); err != nil {
+		return err
+	}
+	array := args[0].(*obj.Array)
+	if len(array.Elements) == 0 {
+		return ONULL
+	}
+	last := array.Elements[len(array.Elements)-1]
+	array.Elements = array.Elements[:len(array.Elements)-1]
+	return last
+}
+
+// xs.contains(x) checks if an element of xs equals x
+func arrayContains(args ...obj.Object) obj.Object {
+	if err := checkMethodArgumentCount("contains", args, 1); err != nil {
+		return err
+	}
+	for _, el := range args[0].(*obj.Array).Elements {
+		if el.Equals(args[1]) {
+			return OTRUE
+		}
+	}
+	return OFALSE
+}
+
+// xs.join(sep) joins an array of strings, with sep between them
+func arrayJoin(args ...obj.Object) obj.Object {
+	if err := checkMethodArgumentCount("join", args, 1); err != nil {
+		return err
+	}
+	sep, err := stringArgument("join", args[1])
+	if err != nil {
+		return err
+	}
+	elements := args[0].(*obj.Array).Elements
+	parts := make([]string, len(elements))
+	for i, el := range elements {
+		s, ok := el.(*obj.String)
+		if !ok {
+			return newError(TYPE_ERROR, "join needs an array of %s, got %s at %d",
+				obj.STRING_OBJ, el.Type(), i)
+		}
+		parts[i] = s.Value
+	}
+	return &obj.String{Value: strings.Join(parts, sep)}
+}
+
+// Hashes
+
+// h.len() returns the number of pairs
+func hashLen(args ...obj.Object) obj.Object {
+	if err := checkMethodArgumentCount("len", args, 0); err != nil {
+		return err
+	}
+	return &obj.Integer{Value: int64(len(args[0].(*obj.Hash).Pairs))}
+}
+
+// h.keys() returns the keys, in insertion order
+func hashKeys(args ...obj.Object) obj.Object {
+	if err := checkMethodArgumentCount("keys", args, 0); err != nil {
+		return err
+	}
+	hash := args[0].(*obj.Hash)
+	keys := make([]obj.Object, len(hash.Pairs))
+	for i, pair := range hash.Pairs {
+		keys[i] = pair.Key
+	}
+	return &obj.Array{Elements: keys}
+}
+
+// h.values() returns the values, in insertion order of their keys
+func hashValues(args ...obj.Object) obj.Object {
+	if err := checkMethodArgumentCount("values", args, 0); err != nil {
+		return err
+	}
+	hash := args[0].(*obj.Hash)
+	values := make([]obj.Object, len(hash.Pairs))
+	for i, pair := range hash.Pairs {
+		values[i] = pair.Value
+	}
+	return &obj.Array{Elements: values}
+}
+
+// h.has(key) checks if h holds a pair with the key
+func hashHas(args ...obj.Object) obj.Object {
+	if err := checkMethodArgumentCount("has", args, 1); err != nil {
+		return err
+	}
+	key, ok := args[1].(obj.Hashable)
+	if !ok {
+		return newOHashKeyError(args[1])
+	}
+	_, found := args[0].(*obj.Hash).Get(key)
+	return nativeBoolToBooleanObject(found)
+}
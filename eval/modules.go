@@ -0,0 +1,249 @@
+package eval
+
+import (
+	"io/ioutil"
+	"mkc/ast"
+	"mkc/lexer"
+	obj "mkc/object"
+	"mkc/parser"
+	"mkc/resolver"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"mkc/std"
+)
+
+/////////////
+// Modules //
+/////////////
+
+// Added to import paths without an extension
+const MODULE_EXTENSION = ".mk"
+
+// Import paths starting with it are std modules, built into the binary.
+// Their files are named by their import path, such as "std/list", unless
+// they are native modules, such as "std/random".
+const STD_PREFIX = "std/"
+
+// A module written in Go, under std/ or, like "math", a name of its own. It
+// makes its members for each interpreter, so state it keeps is not shared
+// between interpreters.
+type nativeModule func(env *obj.Environment) map[string]obj.Object
+
+var nativeModules = map[string]nativeModule{}
+
+// Evaluates the program read from file as the main module, so its imports
+// are found next to it, and importing it back is a cycle
+func EvalFile(program *ast.Program, file string, env *obj.Environment) obj.Object {
+	module := &obj.Module{Name: file, File: absolutePath(file)}
+	env.SetModule(module)
+	return runModule(program, module, env)
+}
+
+// Handles import "PATH" as NAME, binding the module as a constant
+func evalImportStatement(is *ast.ImportStatement, env *obj.Environment) obj.Object {
+	if err := checkRedeclaration(is.Name, true, env); err != nil {
+		return err
+	}
+
+	module := importModule(is.Path.Value, env)
+	if isError(module) { return module }
+
+	declare(env, is.Name, module, obj.Binding{Token: is.Name.Token, Const: true})
+	return nil
+}
+
+// Returns the module at path, evaluating it on its first import only. A
+// native module outside of std/ is only imported when no file is found for
+// its name, so a math.mk next to the importing file is imported instead.
+func importModule(path string, env *obj.Environment) obj.Object {
+	name := strings.TrimSuffix(path, MODULE_EXTENSION)
+	members, native := nativeModules[name]
+	if native && strings.HasPrefix(name, STD_PREFIX) {
+		return importNativeModule(name, members, env)
+	}
+
+	file, err := findModule(path, env)
+	if err != nil {
+		if native {
+			return importNativeModule(name, members, env)
+		}
+		return err
+	}
+
+	modules := env.Modules()
+	if module, ok := modules.Loaded[file]; ok {
+		for i, loading := range modules.Loading {
+			if loading == module {
+				return newOImportCycleError(append(modules.Loading[i:], module))
+			}
+		}
+		return module
+	}
+
+	source, err := readModule(path, file)
+	if err != nil {
+		return err
+	}
+
+	program, err := parseModule(path, source, env.Options())
+	if err != nil {
+		return err
+	}
+
+	module := &obj.Module{Name: path, File: file}
+	result := runModule(program, module, obj.NewModuleEnvironment(env, module))
+	if isError(result) { return result }
+
+	return module
+}
+
+// Returns the native module named name, making it on its first import only
+func importNativeModule(name string, members nativeModule, env *obj.Environment) *obj.Module {
+	modules := env.Modules()
+	if module, ok := modules.Loaded[name]; ok {
+		return module
+	}
+
+	module := &obj.Module{Name: name, File: name}
+	menv := obj.NewModuleEnvironment(env, module)
+	for name, member := range members(env) {
+		menv.Set(name, member)
+		module.Exports = append(module.Exports, name)
+	}
+	sort.Strings(module.Exports)
+
+	modules.Loaded[name] = module
+	return module
+}
+
+// Finds the file of an import path, first next to the importing file,
+// then in each directory of the search path. In a sandbox only .mk files
+// are imported, and only from under the directory they are found in or
+// a directory granted reading, so that imports cannot read what std/fs
+// may not.
+func findModule(path string, env *obj.Environment) (string, *obj.Error) {
+	if strings.HasPrefix(path, STD_PREFIX) {
+		name := strings.TrimSuffix(strings.TrimPrefix(path, STD_PREFIX), MODULE_EXTENSION)
+		if _, ok := std.Source(name); ok {
+			return STD_PREFIX + name, nil
+		}
+		return "", newError(IMPORT_ERROR, "cannot find module %s", path)
+	}
+
+	options := env.Options()
+	sandbox := sandboxed(options)
+
+	name := filepath.FromSlash(path)
+	if filepath.Ext(name) == "" || (sandbox && !strings.HasSuffix(name, MODULE_EXTENSION)) {
+		name += MODULE_EXTENSION
+	}
+
+	if filepath.IsAbs(name) {
+		if isFile(name) && (!sandbox || readable(options, name)) {
+			return filepath.Clean(name), nil
+		}
+		return "", newError(IMPORT_ERROR, "cannot find module %s", path)
+	}
+
+	dirs := []string{"."}
+	if module := env.Module(); module != nil {
+		dirs[0] = filepath.Dir(module.File)
+	}
+	dirs = append(dirs, options.Path...)
+
+	for _, dir := range dirs {
+		file := filepath.Join(dir, name)
+		if isFile(file) && (!sandbox || isUnder(dir, file) || readable(options, file)) {
+			return absolutePath(file), nil
+		}
+	}
+	return "", newError(IMPORT_ERROR, "cannot find module %s", path)
+}
+
+// Reports whether file is under dir once symlinks are followed, so that
+// neither .. nor a link imports a file from elsewhere
+func isUnder(dir string, file string) bool {
+	realDir, err := realPath(dir)
+	if err != nil {
+		return false
+	}
+	realFile, err := realPath(file)
+	return err == nil && isInside(realDir, realFile)
+}
+
+// Reports whether options grant reading file
+func readable(options *obj.Options, file string) bool {
+	_, ok := grantedPath(options.AllowRead, file)
+	return ok
+}
+
+// Returns the source of a module file
+func readModule(path string, file string) (string, *obj.Error) {
+	if strings.HasPrefix(file, STD_PREFIX) {
+		source, _ := std.Source(strings.TrimPrefix(file, STD_PREFIX))
+		return source, nil
+	}
+
+	source, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", newError(IMPORT_ERROR, "cannot read module %s: %s", path, err)
+	}
+	return string(source), nil
+}
+
+// Parses and resolves the source of a module, whose errors fail the import
+func parseModule(path string, source string, options *obj.Options) (*ast.Program, *obj.Error) {
+	p := parser.New(lexer.New(source))
+	p.SetStrictLet(options.StrictLet)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, newError(IMPORT_ERROR, "in module %s: %s", path, p.Errors()[0])
+	}
+
+	r := resolver.New()
+	r.SetStrictLet(options.StrictLet)
+	r.Define(BuiltinNames()...)
+	for _, d := range r.Resolve(program) {
+		if d.Severity == resolver.ERROR {
+			return nil, newError(IMPORT_ERROR, "in module %s: %s", path, d.String())
+		}
+	}
+
+	return program, nil
+}
+
+// Evaluates the program of a module in its own environment.
+// A module that fails is forgotten, so importing it again retries it.
+func runModule(program *ast.Program, module *obj.Module, env *obj.Environment) obj.Object {
+	for _, name := range program.Exports() {
+		module.Exports = append(module.Exports, name.Value)
+	}
+
+	modules := env.Modules()
+	modules.Loaded[module.File] = module
+	modules.Loading = append(modules.Loading, module)
+
+	result := Eval(program, env)
+
+	modules.Loading = modules.Loading[:len(modules.Loading)-1]
+	if isError(result) {
+		delete(modules.Loaded, module.File)
+	}
+	return result
+}
+
+func isFile(name string) bool {
+	info, err := os.Stat(name)
+	return err == nil && !info.IsDir()
+}
+
+// Returns the absolute form of a file name, which identifies a module
+func absolutePath(name string) string {
+	if abs, err := filepath.Abs(name); err == nil {
+		return abs
+	}
+	return filepath.Clean(name)
+}
@@ -0,0 +1,279 @@
+package eval
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	obj "mkc/object"
+)
+
+/////////////
+// Numbers //
+/////////////
+
+// Integers that overflow 64 bits become BigInts, and BigInts that fit
+// become Integers again. An operation on a float and any other number is
+// done in floats.
+
+// Results of ** and factorial are limited to this many bits, which a few
+// seconds of work can still compute and print
+const MAX_INTEGER_BITS = 1 << 24
+
+func isNumber(o obj.Object) bool {
+	switch o.(type) {
+	case *obj.Integer, *obj.Float, *obj.BigInt:
+		return true
+	}
+	return false
+}
+
+func isFloat(o obj.Object) bool {
+	_, ok := o.(*obj.Float)
+	return ok
+}
+
+// Returns the value of an Integer or a BigInt
+func toBig(o obj.Object) *big.Int {
+	if b, ok := o.(*obj.BigInt); ok {
+		return b.Value
+	}
+	return big.NewInt(o.(*obj.Integer).Value)
+}
+
+// Returns the value of a number, rounded to the nearest float
+func toFloat(o obj.Object) float64 {
+	switch o := o.(type) {
+	case *obj.Integer:
+		return float64(o.Value)
+	case *obj.BigInt:
+		f, _ := new(big.Float).SetInt(o.Value).Float64()
+		return f
+	}
+	return o.(*obj.Float).Value
+}
+
+// Returns n as an Integer if it fits, or else as a BigInt
+func newInteger(n *big.Int) obj.Object {
+	if n.IsInt64() {
+		return &obj.Integer{Value: n.Int64()}
+	}
+	return &obj.BigInt{Value: n}
+}
+
+// Returns -1, 0 or 1 as a is less than, equal to or greater than b.
+// NaN is equal to every number.
+func compareNumbers(a obj.Object, b obj.Object) int {
+	if a, ok := a.(*obj.Integer); ok {
+		if b, ok := b.(*obj.Integer); ok {
+			switch {
+			case a.Value < b.Value:
+				return -1
+			case a.Value > b.Value:
+				return 1
+			}
+			return 0
+		}
+	}
+	if isFloat(a) || isFloat(b) {
+		fa, fb := toFloat(a), toFloat(b)
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	}
+	return toBig(a).Cmp(toBig(b))
+}
+
+// Evaluates an arithmetic operation on numbers that are not both Integers
+func evalNumberInfixExpression(operator string, left obj.Object, right obj.Object) obj.Object {
+	if isFloat(left) || isFloat(right) {
+		return evalFloatInfixExpression(operator, left, right)
+	}
+	return evalBigInfixExpression(operator, left, right)
+}
+
+// Evaluates an arithmetic operation on integers of any size
+func evalBigInfixExpression(operator string, left obj.Object, right obj.Object) obj.Object {
+	lval := toBig(left)
+	rval := toBig(right)
+
+	switch operator {
+	case "+":
+		return newInteger(new(big.Int).Add(lval, rval))
+
+	case "-":
+		return newInteger(new(big.Int).Sub(lval, rval))
+
+	case "*":
+		return newInteger(new(big.Int).Mul(lval, rval))
+
+	case "/":
+		if rval.Sign() == 0 {
+			return newOErrorDivisionByZero()
+		}
+		return newInteger(new(big.Int).Quo(lval, rval))
+
+	case "%":
+		if rval.Sign() == 0 {
+			return newOErrorDivisionByZero()
+		}
+		return newInteger(new(big.Int).Rem(lval, rval))
+
+	case "**":
+		return power(left, right)
+
+	case "<", "<=", ">", ">=":
+		return compareWith(operator, lval.Cmp(rval))
+
+	default:
+		return newOErrorUnknownInfixOp(left, operator, right)
+	}
+}
+
+// Evaluates an arithmetic operation with a float operand
+func evalFloatInfixExpression(operator string, left obj.Object, right obj.Object) obj.Object {
+	lval := toFloat(left)
+	rval := toFloat(right)
+
+	switch operator {
+	case "+":
+		return &obj.Float{Value: lval + rval}
+
+	case "-":
+		return &obj.Float{Value: lval - rval}
+
+	case "*":
+		return &obj.Float{Value: lval * rval}
+
+	case "/":
+		if rval == 0 {
+			return newOErrorDivisionByZero()
+		}
+		return &obj.Float{Value: lval / rval}
+
+	case "%":
+		if rval == 0 {
+			return newOErrorDivisionByZero()
+		}
+		return &obj.Float{Value: math.Mod(lval, rval)}
+
+	case "**":
+		return power(left, right)
+
+	case "<":
+		return nativeBoolToBooleanObject(lval < rval)
+
+	case "<=":
+		return nativeBoolToBooleanObject(lval <= rval)
+
+	case ">":
+		return nativeBoolToBooleanObject(lval > rval)
+
+	case ">=":
+		return nativeBoolToBooleanObject(lval >= rval)
+
+	default:
+		return newOErrorUnknownInfixOp(left, operator, right)
+	}
+}
+
+// Applies a comparison operator to the result of a Cmp
+func compareWith(operator string, cmp int) obj.Object {
+	switch operator {
+	case "<":
+		return nativeBoolToBooleanObject(cmp < 0)
+	case "<=":
+		return nativeBoolToBooleanObject(cmp <= 0)
+	case ">":
+		return nativeBoolToBooleanObject(cmp > 0)
+	}
+	return nativeBoolToBooleanObject(cmp >= 0)
+}
+
+// Returns base ** exponent, for ** and math.pow. Integers to a negative
+// power and floats to any power give floats, other integers an integer.
+func power(base obj.Object, exponent obj.Object) obj.Object {
+	b, bok := base.(*obj.Integer)
+	e, eok := exponent.(*obj.Integer)
+	if bok && eok && e.Value >= 0 {
+		if result, ok := integerPower(b.Value, e.Value); ok {
+			return &obj.Integer{Value: result}
+		}
+	}
+
+	if isFloat(base) || isFloat(exponent) || compareNumbers(exponent, &obj.Integer{}) < 0 {
+		b, e := toFloat(base), toFloat(exponent)
+		if b == 0 && e < 0 {
+			return newOErrorDivisionByZero()
+		}
+		result := math.Pow(b, e)
+		if math.IsNaN(result) {
+			return newODomainError(fmt.Sprintf("%s ** %s", base.Inspect(), exponent.Inspect()))
+		}
+		return &obj.Float{Value: result}
+	}
+
+	return bigPower(base, exponent)
+}
+
+// Computes base ** exponent by squaring, in O(log exponent) steps, and
+// reports false if it overflows. exponent must not be negative.
+func integerPower(base int64, exponent int64) (int64, bool) {
+	result := int64(1)
+	ok := true
+	for exponent > 0 && ok {
+		if exponent&1 == 1 {
+			result, ok = multiplyIntegers(result, base)
+		}
+		exponent >>= 1
+		if exponent > 0 && ok {
+			base, ok = multiplyIntegers(base, base)
+		}
+	}
+	return result, ok
+}
+
+// Computes base ** exponent for integers of any size, after checking that
+// the result is not too large to hold
+func bigPower(base obj.Object, exponent obj.Object) obj.Object {
+	b, e := toBig(base), toBig(exponent)
+
+	// 0, 1 and -1 stay small whatever the exponent
+	if b.CmpAbs(big.NewInt(1)) <= 0 {
+		if e.Sign() == 0 || (b.Sign() < 0 && e.Bit(0) == 0) {
+			return &obj.Integer{Value: 1}
+		}
+		return newInteger(b)
+	}
+	if !e.IsInt64() || e.Int64() > MAX_INTEGER_BITS/int64(b.BitLen()-1) {
+		return newError(ARITHMETIC_ERROR, "%s ** %s is too large", base.Inspect(), exponent.Inspect())
+	}
+	return newInteger(new(big.Int).Exp(b, e, nil))
+}
+
+// Returns a + b, and whether it did not overflow
+func addIntegers(a int64, b int64) (int64, bool) {
+	sum := a + b
+	return sum, (sum > a) == (b > 0)
+}
+
+// Returns a - b, and whether it did not overflow
+func subtractIntegers(a int64, b int64) (int64, bool) {
+	difference := a - b
+	return difference, (difference < a) == (b > 0)
+}
+
+// Returns a * b, and whether it did not overflow
+func multiplyIntegers(a int64, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	if (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, false
+	}
+	product := a * b
+	return product, product/b == a
+}
@@ -0,0 +1,165 @@
+package eval
+
+import (
+	"math"
+	"math/rand"
+	obj "mkc/object"
+	"time"
+)
+
+////////////
+// Random //
+////////////
+
+func init() {
+	nativeModules[STD_PREFIX+"random"] = randomModule
+}
+
+// Returns the members of import "std/random", which share a generator of
+// their own. It starts from Options.Seed if it is set, so that runs with
+// the same seed make the same numbers.
+func randomModule(env *obj.Environment) map[string]obj.Object {
+	seed := time.Now().UnixNano()
+	if env.Options().Seed != nil {
+		seed = *env.Options().Seed
+	}
+	r := &random{rand.New(rand.NewSource(seed))}
+
+	return map[string]obj.Object{
+		"seed":    &obj.Builtin{Name: "seed", Fn: r.seed},
+		"int":     &obj.Builtin{Name: "int", Fn: r.int},
+		"float":   &obj.Builtin{Name: "float", Fn: r.float},
+		"choice":  &obj.Builtin{Name: "choice", Fn: r.choice},
+		"shuffle": &obj.Builtin{Name: "shuffle", Fn: r.shuffle},
+		"sample":  &obj.Builtin{Name: "sample", Fn: r.sample},
+	}
+}
+
+type random struct {
+	rng *rand.Rand
+}
+
+// Returns a number in 0..<n, or any number if n is 0, which stands for 2**64
+func (r *random) uniform(n uint64) uint64 {
+	switch {
+	case n == 0:
+		return r.rng.Uint64()
+	case n <= math.MaxInt64:
+		return uint64(r.rng.Int63n(int64(n)))
+	}
+	// n is over 2**63, so a draw is rejected less than half of the time
+	for {
+		if x := r.rng.Uint64(); x < n {
+			return x
+		}
+	}
+}
+
+// Returns the elements of an array, or of any other iterable value
+func allElements(o obj.Object) ([]obj.Object, *obj.Error) {
+	if array, ok := o.(*obj.Array); ok {
+		return array.Elements, nil
+	}
+	return appendAll(nil, o)
+}
+
+// seed(n) restarts the generator from n
+func (r *random) seed(args ...obj.Object) obj.Object {
+	if err := checkArgumentCount("seed", args, 1); err != nil {
+		return err
+	}
+	n, ok := args[0].(*obj.Integer)
+	if !ok {
+		return newOErrorArgumentType("seed", obj.INTEGER_OBJ, args[0])
+	}
+	r.rng.Seed(n.Value)
+	return ONULL
+}
+
+// int(lo, hi) returns an integer in lo..hi, both included
+func (r *random) int(args ...obj.Object) obj.Object {
+	if err := checkArgumentCount("int", args, 2); err != nil {
+		return err
+	}
+	var bounds [2]int64
+	for i, arg := range args {
+		n, ok := arg.(*obj.Integer)
+		if !ok {
+			return newOErrorArgumentType("int", obj.INTEGER_OBJ, arg)
+		}
+		bounds[i] = n.Value
+	}
+	lo, hi := bounds[0], bounds[1]
+	if lo > hi {
+		return newError(ARGUMENT_ERROR, "bounds of int are reversed: %d > %d", lo, hi)
+	}
+	// the difference wraps around as an int64, but not as a uint64
+	return &obj.Integer{Value: lo + int64(r.uniform(uint64(hi-lo)+1))}
+}
+
+// float() returns a float in 0..<1
+func (r *random) float(args ...obj.Object) obj.Object {
+	if err := checkArgumentCount("float", args, 0); err != nil {
+		return err
+	}
+	return &obj.Float{Value: r.rng.Float64()}
+}
+
+// choice(xs) returns an element of xs, an array or any iterable
+func (r *random) choice(args ...obj.Object) obj.Object {
+	if err := checkArgumentCount("choice", args, 1); err != nil {
+		return err
+	}
+	elements, err := allElements(args[0])
+	if err != nil {
+		return err
+	}
+	if len(elements) == 0 {
+		return newError(ARGUMENT_ERROR, "choice from no elements")
+	}
+	return elements[r.uniform(uint64(len(elements)))]
+}
+
+// shuffle(xs) returns the elements of xs in a new array and order, leaving
+// xs as it is
+func (r *random) shuffle(args ...obj.Object) obj.Object {
+	if err := checkArgumentCount("shuffle", args, 1); err != nil {
+		return err
+	}
+	elements, err := allElements(args[0])
+	if err != nil {
+		return err
+	}
+	return &obj.Array{Elements: r.pick(elements, len(elements))}
+}
+
+// sample(xs, k) returns k elements of xs, from k different positions
+func (r *random) sample(args ...obj.Object) obj.Object {
+	if err := checkArgumentCount("sample", args, 2); err != nil {
+		return err
+	}
+	elements, err := allElements(args[0])
+	if err != nil {
+		return err
+	}
+	k, err := countArgument("sample", args[1])
+	if err != nil {
+		return err
+	}
+	if k > len(elements) {
+		return newError(ARGUMENT_ERROR, "cannot sample %d of %d elements", k, len(elements))
+	}
+	return &obj.Array{Elements: r.pick(elements, k)}
+}
+
+// Returns k elements of a copy of elements, in random order, by the first
+// k steps of a Fisher-Yates shuffle
+func (r *random) pick(elements []obj.Object, k int) []obj.Object {
+	picked := make([]obj.Object, len(elements))
+	copy(picked, elements)
+	for i := 0; i < k; i++ {
+		j := i + int(r.uniform(uint64(len(picked)-i)))
+		picked[i], picked[j] = picked[j], picked[i]
+	}
+	return picked[:k]
+}
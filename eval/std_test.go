@@ -0,0 +1,71 @@
+package eval
+
+import (
+	"mkc/ast"
+	obj "mkc/object"
+	"mkc/std"
+	"strings"
+	"testing"
+)
+
+// Runs the test functions of every std module, those named test_*
+func TestStdModules(t *testing.T) {
+	for _, name := range std.Names() {
+		path := STD_PREFIX + name
+		source, _ := std.Source(name)
+
+		program, err := parseModule(path, source, &obj.Options{})
+		if err != nil {
+			t.Fatalf("%s: %s", path, err.Inspect())
+		}
+
+		env := obj.NewEnvironment()
+		module := &obj.Module{Name: path, File: path}
+		env.SetModule(module)
+		if result := runModule(program, module, env); isError(result) {
+			t.Fatalf("%s: %s", path, result.Inspect())
+		}
+
+		tests := 0
+		for _, statement := range program.Statements {
+			fs, ok := statement.(*ast.FunctionStatement)
+			if !ok || !strings.HasPrefix(fs.Name.Value, "test_") {
+				continue
+			}
+			tests += 1
+
+			fn, _ := env.Get(fs.Name.Value)
+			if result := applyFunction(fn, nil); isError(result) {
+				t.Errorf("%s: %s: %s", path, fs.Name.Value, result.Inspect())
+			}
+		}
+
+		if tests == 0 {
+			t.Errorf("%s has no test functions", path)
+		}
+	}
+}
+
+func TestImportStd(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import "std/list" as list; list.sort([3, 1, 2])`, "[1, 2, 3]"},
+		{`import "std/list.mk" as list; list.sum(1..10)`, "55"},
+		{`import "std/string" as s; s.join(["a", "b"], "-")`, "a-b"},
+		{`import "std/math" as m; m.gcd(12, 18)`, "6"},
+		{`import "std/math" as s; import "math" as m; [s, m, s.isqrt(17), m.sqrt(16)]`, "[module std/math, module math, 4, 4.0]"},
+		{`import "std/functional" as f; import "std/list" as l; f.compose(l.sum, l.reverse)([1, 2])`, "3"},
+		{`import "std/list" as l; l.test_sort`, "NameError: module std/list does not export test_sort"},
+		{`import "std/nope" as n;`, "ImportError: cannot find module std/nope"},
+		{`import "std/../go" as n;`, "ImportError: cannot find module std/../go"},
+	}
+
+	for _, tt := range tests {
+		evaluated := runEval(t, tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("%q - expected=%q, got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
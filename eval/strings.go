@@ -0,0 +1,282 @@
+package eval
+
+import (
+	obj "mkc/object"
+	"strings"
+	"unicode/utf8"
+)
+
+/////////////
+// Strings //
+/////////////
+
+// Strings are UTF-8, and their methods count in characters (runes), not bytes
+
+// Strings made by repeat and padding are limited to this many bytes, so a
+// huge count raises an error instead of exhausting memory
+const MAX_STRING_LENGTH = 1 << 28
+
+func init() {
+	str := obj.Methods(obj.STRING_OBJ)
+	str["len"] = &obj.Builtin{Name: "len", Fn: stringLen}
+	str["upper"] = &obj.Builtin{Name: "upper", Fn: stringUpper}
+	str["lower"] = &obj.Builtin{Name: "lower", Fn: stringLower}
+	str["trim"] = &obj.Builtin{Name: "trim", Fn: stringTrim}
+	str["split"] = &obj.Builtin{Name: "split", Fn: stringSplit}
+	str["replace"] = &obj.Builtin{Name: "replace", Fn: stringReplace}
+	str["contains"] = &obj.Builtin{Name: "contains", Fn: stringContains}
+	str["starts_with"] = &obj.Builtin{Name: "starts_with", Fn: stringStartsWith}
+	str["ends_with"] = &obj.Builtin{Name: "ends_with", Fn: stringEndsWith}
+	str["index_of"] = &obj.Builtin{Name: "index_of", Fn: stringIndexOf}
+	str["repeat"] = &obj.Builtin{Name: "repeat", Fn: stringRepeat}
+	str["pad_left"] = &obj.Builtin{Name: "pad_left", Fn: stringPadLeft}
+	str["pad_right"] = &obj.Builtin{Name: "pad_right", Fn: stringPadRight}
+	str["chars"] = &obj.Builtin{Name: "chars", Fn: stringChars}
+	str["bytes"] = &obj.Builtin{Name: "bytes", Fn: stringBytes}
+	str["substr"] = &obj.Builtin{Name: "substr", Fn: stringSubstr}
+}
+
+// Returns the value of a string argument
+func stringArgument(name string, arg obj.Object) (string, *obj.Error) {
+	s, ok := arg.(*obj.String)
+	if !ok {
+		return "", newOErrorArgumentType(name, obj.STRING_OBJ, arg)
+	}
+	return s.Value, nil
+}
+
+// Returns the value of an integer argument, which must not be negative
+func countArgument(name string, arg obj.Object) (int, *obj.Error) {
+	i, ok := arg.(*obj.Integer)
+	if !ok {
+		return 0, newOErrorArgumentType(name, obj.INTEGER_OBJ, arg)
+	}
+	if i.Value < 0 {
+		return 0, newError(ARGUMENT_ERROR, "argument to %s must not be negative, got %d", name, i.Value)
+	}
+	return int(i.Value), nil
+}
+
+// Applies a function of the receiver and one string argument
+func stringPredicate(name string, args []obj.Object, fn func(s, arg string) bool) obj.Object {
+	if err := checkMethodArgumentCount(name, args, 1); err != nil {
+		return err
+	}
+	arg, err := stringArgument(name, args[1])
+	if err != nil {
+		return err
+	}
+	return nativeBoolToBooleanObject(fn(args[0].(*obj.String).Value, arg))
+}
+
+// Makes an array of strings
+func stringArray(parts []string) *obj.Array {
+	elements := make([]obj.Object, len(parts))
+	for i, part := range parts {
+		elements[i] = &obj.String{Value: part}
+	}
+	return &obj.Array{Elements: elements}
+}
+
+// s.len() returns the number of characters
+func stringLen(args ...obj.Object) obj.Object {
+	if err := checkMethodArgumentCount("len", args, 0); err != nil {
+		return err
+	}
+	return &obj.Integer{Value: int64(utf8.RuneCountInString(args[0].(*obj.String).Value))}
+}
+
+// s.upper() returns s in upper case
+func stringUpper(args ...obj.Object) obj.Object {
+	if err := checkMethodArgumentCount("upper", args, 0); err != nil {
+		return err
+	}
+	return &obj.String{Value: strings.ToUpper(args[0].(*obj.String).Value)}
+}
+
+// s.lower() returns s in lower case
+func stringLower(args ...obj.Object) obj.Object {
+	if err := checkMethodArgumentCount("lower", args, 0); err != nil {
+		return err
+	}
+	return &obj.String{Value: strings.ToLower(args[0].(*obj.String).Value)}
+}
+
+// s.trim() returns s without leading and trailing white space
+func stringTrim(args ...obj.Object) obj.Object {
+	if err := checkMethodArgumentCount("trim", args, 0); err != nil {
+		return err
+	}
+	return &obj.String{Value: strings.TrimSpace(args[0].(*obj.String).Value)}
+}
+
+// s.split(sep) returns the parts of s between each sep, or its characters
+// if sep is empty
+func stringSplit(args ...obj.Object) obj.Object {
+	if err := checkMethodArgumentCount("split", args, 1); err != nil {
+		return err
+	}
+	sep, err := stringArgument("split", args[1])
+	if err != nil {
+		return err
+	}
+	return stringArray(strings.Split(args[0].(*obj.String).Value, sep))
+}
+
+// s.replace(old, with) replaces every old in s with with
+func stringReplace(args ...obj.Object) obj.Object {
+	if err := checkMethodArgumentCount("replace", args, 2); err != nil {
+		return err
+	}
+	old, err := stringArgument("replace", args[1])
+	if err != nil {
+		return err
+	}
+	with, err := stringArgument("replace", args[2])
+	if err != nil {
+		return err
+	}
+	return &obj.String{Value: strings.ReplaceAll(args[0].(*obj.String).Value, old, with)}
+}
+
+// s.contains(sub) checks if sub is in s
+func stringContains(args ...obj.Object) obj.Object {
+	return stringPredicate("contains", args, strings.Contains)
+}
+
+// s.starts_with(prefix) checks if s begins with prefix
+func stringStartsWith(args ...obj.Object) obj.Object {
+	return stringPredicate("starts_with", args, strings.HasPrefix)
+}
+
+// s.ends_with(suffix) checks if s ends with suffix
+func stringEndsWith(args ...obj.Object) obj.Object {
+	return stringPredicate("ends_with", args, strings.HasSuffix)
+}
+
+// s.index_of(sub) returns the character index of the first sub in s, or -1
+func stringIndexOf(args ...obj.Object) obj.Object {
+	if err := checkMethodArgumentCount("index_of", args, 1); err != nil {
+		return err
+	}
+	sub, err := stringArgument("index_of", args[1])
+	if err != nil {
+		return err
+	}
+	s := args[0].(*obj.String).Value
+	i := strings.Index(s, sub)
+	if i < 0 {
+		return &obj.Integer{Value: -1}
+	}
+	return &obj.Integer{Value: int64(utf8.RuneCountInString(s[:i]))}
+}
+
+// s.repeat(n) returns s, n times over
+func stringRepeat(args ...obj.Object) obj.Object {
+	if err := checkMethodArgumentCount("repeat", args, 1); err != nil {
+		return err
+	}
+	n, err := countArgument("repeat", args[1])
+	if err != nil {
+		return err
+	}
+	s := args[0].(*obj.String).Value
+	if err := checkStringLength("repeat", len(s), n); err != nil {
+		return err
+	}
+	return &obj.String{Value: strings.Repeat(s, n)}
+}
+
+// Errors if count copies of size bytes are over MAX_STRING_LENGTH
+func checkStringLength(name string, size int, count int) *obj.Error {
+	if size > 0 && count > MAX_STRING_LENGTH/size {
+		return newError(ARGUMENT_ERROR, "%s would make a string of over %d bytes", name, MAX_STRING_LENGTH)
+	}
+	return nil
+}
+
+// s.pad_left(width, pad) and s.pad_right(width, pad) fill s up to width
+// characters with pad, a single character that defaults to a space
+func stringPadLeft(args ...obj.Object) obj.Object {
+	return stringPad("pad_left", args, func(s, fill string) string { return fill + s })
+}
+
+func stringPadRight(args ...obj.Object) obj.Object {
+	return stringPad("pad_right", args, func(s, fill string) string { return s + fill })
+}
+
+func stringPad(name string, args []obj.Object, join func(s, fill string) string) obj.Object {
+	if err := checkMethodArgumentRange(name, args, 1, 2); err != nil {
+		return err
+	}
+	width, err := countArgument(name, args[1])
+	if err != nil {
+		return err
+	}
+	pad := " "
+	if len(args) == 3 {
+		if pad, err = stringArgument(name, args[2]); err != nil {
+			return err
+		}
+		if utf8.RuneCountInString(pad) != 1 {
+			return newError(ARGUMENT_ERROR, "pad of %s must be one character, got %q", name, pad)
+		}
+	}
+	s := args[0].(*obj.String)
+	n := width - utf8.RuneCountInString(s.Value)
+	if n <= 0 {
+		return s
+	}
+	if err := checkStringLength(name, len(pad), n); err != nil {
+		return err
+	}
+	return &obj.String{Value: join(s.Value, strings.Repeat(pad, n))}
+}
+
+// s.chars() returns the characters of s, as strings
+func stringChars(args ...obj.Object) obj.Object {
+	if err := checkMethodArgumentCount("chars", args, 0); err != nil {
+		return err
+	}
+	return stringArray(strings.Split(args[0].(*obj.String).Value, ""))
+}
+
+// s.bytes() returns the UTF-8 bytes of s, as integers
+func stringBytes(args ...obj.Object) obj.Object {
+	if err := checkMethodArgumentCount("bytes", args, 0); err != nil {
+		return err
+	}
+	s := args[0].(*obj.String).Value
+	elements := make([]obj.Object, len(s))
+	for i := 0; i < len(s); i++ {
+		elements[i] = &obj.Integer{Value: int64(s[i])}
+	}
+	return &obj.Array{Elements: elements}
+}
+
+// s.substr(start, count) returns count characters of s from start, or all
+// of them up to the end without count. Either may go past the end of s.
+func stringSubstr(args ...obj.Object) obj.Object {
+	if err := checkMethodArgumentRange("substr", args, 1, 2); err != nil {
+		return err
+	}
+	runes := []rune(args[0].(*obj.String).Value)
+	start, err := countArgument("substr", args[1])
+	if err != nil {
+		return err
+	}
+	if start > len(runes) {
+		start = len(runes)
+	}
+	end := len(runes)
+	if len(args) == 3 {
+		count, err := countArgument("substr", args[2])
+		if err != nil {
+			return err
+		}
+		if count < end-start {
+			end = start + count
+		}
+	}
+	return &obj.String{Value: string(runes[start:end])}
+}
@@ -0,0 +1,105 @@
+package eval
+
+import (
+	"mkc/ast"
+	obj "mkc/object"
+)
+
+/////////////
+// Structs //
+/////////////
+
+// Returns the type a struct declaration binds, hoisted like functions
+func newStructType(ss *ast.StructStatement) *obj.StructType {
+	fields := make([]string, len(ss.Fields))
+	for i, field := range ss.Fields {
+		fields[i] = field.Value
+	}
+	return &obj.StructType{Name: ss.Name.Value, Fields: fields}
+}
+
+// Calling a struct type makes an instance, with the fields in declared order
+func instantiateStruct(st *obj.StructType, args []obj.Object) obj.Object {
+	if len(args) != len(st.Fields) {
+		return newError(ARGUMENT_ERROR, "wrong number of arguments to %s: want=%d, got=%d",
+			st.Name, len(st.Fields), len(args))
+	}
+
+	values := make([]obj.Object, len(args))
+	copy(values, args)
+	return &obj.Struct{StructType: st, Values: values}
+}
+
+// Handles record.field and Enum.Variant
+// Without a field of that name, value.method is the method bound to value
+func evalMemberExpression(me *ast.MemberExpression, env *obj.Environment) obj.Object {
+	object := Eval(me.Object, env)
+	if isError(object) { return object }
+
+	name := me.Member.Value
+	if val, ok := member(object, name); ok {
+		return val
+	}
+	if method, ok := obj.LookupMethod(object, name); ok {
+		return &obj.BoundMethod{Receiver: object, Name: name, Method: method}
+	}
+	return newOFieldError(object, name)
+}
+
+// Returns a field of a struct instance or enum value, a variant of an enum
+// type, or an export of a module
+func member(object obj.Object, name string) (obj.Object, bool) {
+	switch o := object.(type) {
+	case *obj.Struct:
+		if idx := o.StructType.FieldIndex(name); idx >= 0 {
+			return o.Values[idx], true
+		}
+	case *obj.EnumValue:
+		if idx := o.Variant.FieldIndex(name); idx >= 0 {
+			return o.Values[idx], true
+		}
+	case *obj.EnumType:
+		return enumVariant(o, name)
+	case *obj.Module:
+		return o.Export(name)
+	}
+	return nil, false
+}
+
+// Handles record.field = value, updating the instance in place
+func evalMemberAssignExpression(ma *ast.MemberAssignExpression, env *obj.Environment) obj.Object {
+	object := Eval(ma.Target.Object, env)
+	if isError(object) { return object }
+
+	switch object.(type) {
+	case *obj.EnumValue:
+		return newOImmutableError(object, ma.Target.Member.Value, "enum values cannot be changed")
+	case *obj.Module:
+		return newOImmutableError(object, ma.Target.Member.Value, "exports are changed by their module only")
+	}
+
+	s, idx, err := structField(object, ma.Target.Member.Value)
+	if err != nil {
+		return err
+	}
+
+	val := Eval(ma.Value, env)
+	if isError(val) { return val }
+
+	s.Values[idx] = val
+	return val
+}
+
+// Finds a field of a struct instance
+func structField(object obj.Object, name string) (*obj.Struct, int, *obj.Error) {
+	s, ok := object.(*obj.Struct)
+	if !ok {
+		return nil, 0, newOFieldError(object, name)
+	}
+
+	idx := s.StructType.FieldIndex(name)
+	if idx < 0 {
+		return nil, 0, newOFieldError(object, name)
+	}
+	return s, idx, nil
+}
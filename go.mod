@@ -1,3 +1,3 @@
 module mkc
 
-go 1.14
+go 1.16
@@ -7,6 +7,8 @@ type Lexer struct {
 	position 		int // current position
 	readPosition 	int // after current position
 	ch 				byte
+	line			int // line of current character
+	column			int // column of current character
 }
 
 ////////////////
@@ -15,13 +17,19 @@ type Lexer struct {
 
 // Creates a lexer for given input string
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar()
 	return l
 }
 
 // Reads the next character in input
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line += 1
+		l.column = 0
+	}
+	l.column += 1
+
 	l.ch = 0 // eof
 
 	if l.readPosition < len(l.input) {
@@ -42,6 +50,17 @@ func (l *Lexer) peekChar() byte {
 	return l.input[l.readPosition]
 }
 
+// Returns the character n places after the current one
+// Doesn't affect pointer
+func (l *Lexer) peekCharAt(n int) byte {
+	idx := l.position + n
+	if idx >= len(l.input) {
+		return 0
+	}
+
+	return l.input[idx]
+}
+
 // Returns bunch of characters, starting with the current one
 // Provide length of string
 func (l *Lexer) readString(n int) string {
@@ -55,13 +74,41 @@ func (l *Lexer) readString(n int) string {
 	return string(ba)
 }
 
-// Returns numerical literal
-func (l *Lexer) readNumber() string {
+// Returns numerical literal, and whether it is a float: digits with a
+// fraction, as in 1.5, or an exponent, as in 2e10. A dot that no digit
+// follows is left alone, so 1..5 is a range and 4.double a method.
+func (l *Lexer) readNumber() (string, bool) {
 	startPosition := l.position
+	float := false
+	l.readDigits()
+
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		float = true
+		l.readChar()
+		l.readDigits()
+	}
+
+	if l.ch == 'e' || l.ch == 'E' {
+		exponent := 1
+		if next := l.peekChar(); next == '+' || next == '-' {
+			exponent = 2
+		}
+		if isDigit(l.peekCharAt(exponent)) {
+			float = true
+			for i := 0; i < exponent; i++ {
+				l.readChar()
+			}
+			l.readDigits()
+		}
+	}
+
+	return l.input[startPosition:l.position], float
+}
+
+func (l *Lexer) readDigits() {
 	for isDigit(l.ch) {
 		l.readChar()
 	}
-	return l.input[startPosition:l.position]
 }
 
 // Returns identifier, that is, the alphanumeric sequence till next end
@@ -73,10 +120,66 @@ func (l* Lexer) readIdentifier() string {
 	return l.input[startPosition:l.position]
 }
 
-// Skips over all whitespace
-func (l *Lexer) eatWhitespace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+// Returns string literal between double quotes, with escapes resolved.
+// A literal with an unknown escape, or without its closing quote, is not
+// legal: then the escape, or the literal up to the end of input, is
+// returned instead, with ok false.
+func (l *Lexer) readStringLiteral() (literal string, ok bool) {
+	start := l.position
+	var out []byte
+	unknown := ""
+	for {
 		l.readChar()
+		if l.ch == 0 {
+			return l.input[start:l.position], false
+		}
+		if l.ch == '"' {
+			break
+		}
+
+		if l.ch == '\\' {
+			l.readChar()
+			switch l.ch {
+			case 0:
+				return l.input[start:l.position], false
+			case 'n':
+				out = append(out, '\n')
+			case 't':
+				out = append(out, '\t')
+			case 'r':
+				out = append(out, '\r')
+			case '"', '\\':
+				out = append(out, l.ch)
+			default:
+				if unknown == "" {
+					unknown = "\\" + string(l.ch)
+				}
+			}
+			continue
+		}
+
+		out = append(out, l.ch)
+	}
+
+	if unknown != "" {
+		return unknown, false
+	}
+	return string(out), true
+}
+
+// Skips over all whitespace, and comments from // to the end of the line
+func (l *Lexer) eatWhitespace() {
+	for {
+		switch {
+		case l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r':
+			l.readChar()
+		case l.ch == '/' && l.peekChar() == '/':
+			for l.ch != '\n' && l.ch != 0 {
+				l.readChar()
+			}
+		default:
+			return
+		}
 	}
 }
 
@@ -99,11 +202,15 @@ func newTokenString(tokenType tk.TokenType, s string) tk.Token {
 ///////////////
 
 // Returns next token in input stream
-func (l *Lexer) NextToken() tk.Token {
-	var tok tk.Token
-
+func (l *Lexer) NextToken() (tok tk.Token) {
 	l.eatWhitespace()
 
+	line, column := l.line, l.column
+	defer func() {
+		tok.Line = line
+		tok.Column = column
+	}()
+
 	switch l.ch {
 	case '+':
 		tok = newToken(tk.PLUS, l.ch)
@@ -125,12 +232,23 @@ func (l *Lexer) NextToken() tk.Token {
 	case '%':
 		tok = newToken(tk.MOD, l.ch)
 
+	case '?':
+		tok = newToken(tk.QUESTION, l.ch)
+
+		if l.peekChar() == '?' {
+			s := l.readString(2)
+			tok = newTokenString(tk.NULLISH, s)
+		}
+
 	case '=':
 		tok = newToken(tk.ASSIGN, l.ch)
 
 		if l.peekChar() == '=' {
 			s := l.readString(2)
 			tok = newTokenString(tk.EQ, s)
+		} else if l.peekChar() == '>' {
+			s := l.readString(2)
+			tok = newTokenString(tk.ARROW, s)
 		}
 
 	case '!':
@@ -157,6 +275,25 @@ func (l *Lexer) NextToken() tk.Token {
 			tok = newTokenString(tk.GTEQ, s)
 		}
 
+	case '|':
+		tok = newToken(tk.ILLEGAL, l.ch)
+
+		if l.peekChar() == '>' {
+			s := l.readString(2)
+			tok = newTokenString(tk.PIPE, s)
+		} else if l.peekChar() == '|' {
+			s := l.readString(2)
+			tok = newTokenString(tk.OR, s)
+		}
+
+	case '&':
+		tok = newToken(tk.ILLEGAL, l.ch)
+
+		if l.peekChar() == '&' {
+			s := l.readString(2)
+			tok = newTokenString(tk.AND, s)
+		}
+
 	case '(':
 		tok = newToken(tk.LPAREN, l.ch)
 
@@ -169,12 +306,45 @@ func (l *Lexer) NextToken() tk.Token {
 	case '}':
 		tok = newToken(tk.RBRACE, l.ch)
 
+	case '[':
+		tok = newToken(tk.LBRACKET, l.ch)
+
+	case ']':
+		tok = newToken(tk.RBRACKET, l.ch)
+
+	case '"':
+		s, ok := l.readStringLiteral()
+		tok = newTokenString(tk.STRING, s)
+		if !ok {
+			tok = newTokenString(tk.ILLEGAL, s)
+		}
+
 	case ',':
 		tok = newToken(tk.COMMA, l.ch)
 
 	case ';':
 		tok = newToken(tk.SEMICOLON, l.ch)
 
+	case ':':
+		tok = newToken(tk.COLON, l.ch)
+
+	case '.':
+		tok = newToken(tk.DOT, l.ch)
+
+		if l.peekChar() == '.' {
+			switch l.peekCharAt(2) {
+			case '.':
+				s := l.readString(3)
+				tok = newTokenString(tk.ELLIPSIS, s)
+			case '<':
+				s := l.readString(3)
+				tok = newTokenString(tk.RANGE_EXCLUSIVE, s)
+			default:
+				s := l.readString(2)
+				tok = newTokenString(tk.RANGE, s)
+			}
+		}
+
 	case 0:
 		tok = newTokenString(tk.EOF, "")
 
@@ -182,13 +352,16 @@ func (l *Lexer) NextToken() tk.Token {
 		if isLetter(l.ch) {
 			s := l.readIdentifier()
 			t := tk.LookupIdent(s)
-			tok := newTokenString(t, s)
+			tok = newTokenString(t, s)
 			return tok
 		}
 
 		if isDigit(l.ch) {
-			n := l.readNumber()
-			tok := newTokenString(tk.INT, n)
+			n, float := l.readNumber()
+			if float {
+				return newTokenString(tk.FLOAT, n)
+			}
+			tok = newTokenString(tk.INT, n)
 			return tok
 		}
 
@@ -23,3 +23,38 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestTokenPosition(t *testing.T) {
+	input := "let x = 5;\n  const y = x;"
+
+	expected := []struct {
+		literal string
+		line    int
+		column  int
+	}{
+		{"let", 1, 1},
+		{"x", 1, 5},
+		{"=", 1, 7},
+		{"5", 1, 9},
+		{";", 1, 10},
+		{"const", 2, 3},
+		{"y", 2, 9},
+		{"=", 2, 11},
+		{"x", 2, 13},
+		{";", 2, 14},
+	}
+
+	l := New(input)
+	for i, tt := range expected {
+		tok := l.NextToken()
+
+		if tok.Literal != tt.literal {
+			t.Fatalf("[%d] - token Literal wrong. expected = %q. got = %q", i, tt.literal, tok.Literal)
+		}
+
+		if tok.Line != tt.line || tok.Column != tt.column {
+			t.Fatalf("[%d] %q - position wrong. expected = %d:%d. got = %s",
+				i, tt.literal, tt.line, tt.column, tok.Position())
+		}
+	}
+}
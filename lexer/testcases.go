@@ -122,6 +122,302 @@ var lexerTestCases = map[string]testCase {
 		},
 	},
 
+	"const": {
+		input: `const limit = 10;`,
+		expect: []expectations{
+			{tk.CONST, "const"},
+			{tk.IDENTIFIER, "limit"},
+			{tk.ASSIGN, "="},
+			{tk.INT, "10"},
+			{tk.SEMICOLON, ";"},
+			{tk.EOF, ""},
+		},
+	},
+
+	"strings-arrays": {
+		input: `"foo bar" "say \"hi\"\n" [1, "two"][0]`,
+		expect: []expectations{
+			{tk.STRING, "foo bar"},
+			{tk.STRING, "say \"hi\"\n"},
+			{tk.LBRACKET, "["},
+			{tk.INT, "1"},
+			{tk.COMMA, ","},
+			{tk.STRING, "two"},
+			{tk.RBRACKET, "]"},
+			{tk.LBRACKET, "["},
+			{tk.INT, "0"},
+			{tk.RBRACKET, "]"},
+			{tk.EOF, ""},
+		},
+	},
+
+	"illegal-strings": {
+		input: `"a\\b" "caf\u00e9" "open`,
+		expect: []expectations{
+			{tk.STRING, "a\\b"},
+			{tk.ILLEGAL, "\\u"},
+			{tk.ILLEGAL, "\"open"},
+			{tk.EOF, ""},
+		},
+	},
+
+	"try-catch": {
+		input: `try { throw "x"; } catch (e) { e } finally { 1 }`,
+		expect: []expectations{
+			{tk.TRY, "try"},
+			{tk.LBRACE, "{"},
+			{tk.THROW, "throw"},
+			{tk.STRING, "x"},
+			{tk.SEMICOLON, ";"},
+			{tk.RBRACE, "}"},
+			{tk.CATCH, "catch"},
+			{tk.LPAREN, "("},
+			{tk.IDENTIFIER, "e"},
+			{tk.RPAREN, ")"},
+			{tk.LBRACE, "{"},
+			{tk.IDENTIFIER, "e"},
+			{tk.RBRACE, "}"},
+			{tk.FINALLY, "finally"},
+			{tk.LBRACE, "{"},
+			{tk.INT, "1"},
+			{tk.RBRACE, "}"},
+			{tk.EOF, ""},
+		},
+	},
+
+	"postfix": {
+		input: `parse(x)?;`,
+		expect: []expectations{
+			{tk.IDENTIFIER, "parse"},
+			{tk.LPAREN, "("},
+			{tk.IDENTIFIER, "x"},
+			{tk.RPAREN, ")"},
+			{tk.QUESTION, "?"},
+			{tk.SEMICOLON, ";"},
+			{tk.EOF, ""},
+		},
+	},
+
+	"match": {
+		input: `match (x) { [a, ...b] => {"k": a} }`,
+		expect: []expectations{
+			{tk.MATCH, "match"},
+			{tk.LPAREN, "("},
+			{tk.IDENTIFIER, "x"},
+			{tk.RPAREN, ")"},
+			{tk.LBRACE, "{"},
+			{tk.LBRACKET, "["},
+			{tk.IDENTIFIER, "a"},
+			{tk.COMMA, ","},
+			{tk.ELLIPSIS, "..."},
+			{tk.IDENTIFIER, "b"},
+			{tk.RBRACKET, "]"},
+			{tk.ARROW, "=>"},
+			{tk.LBRACE, "{"},
+			{tk.STRING, "k"},
+			{tk.COLON, ":"},
+			{tk.IDENTIFIER, "a"},
+			{tk.RBRACE, "}"},
+			{tk.RBRACE, "}"},
+			{tk.EOF, ""},
+		},
+	},
+
+	"pipe": {
+		input: `xs |> f(1)`,
+		expect: []expectations{
+			{tk.IDENTIFIER, "xs"},
+			{tk.PIPE, "|>"},
+			{tk.IDENTIFIER, "f"},
+			{tk.LPAREN, "("},
+			{tk.INT, "1"},
+			{tk.RPAREN, ")"},
+			{tk.EOF, ""},
+		},
+	},
+
+	"conditional": {
+		input: `c ? null : a ?? b`,
+		expect: []expectations{
+			{tk.IDENTIFIER, "c"},
+			{tk.QUESTION, "?"},
+			{tk.NULL, "null"},
+			{tk.COLON, ":"},
+			{tk.IDENTIFIER, "a"},
+			{tk.NULLISH, "??"},
+			{tk.IDENTIFIER, "b"},
+			{tk.EOF, ""},
+		},
+	},
+
+	"logical": {
+		input: `a && b || c`,
+		expect: []expectations{
+			{tk.IDENTIFIER, "a"},
+			{tk.AND, "&&"},
+			{tk.IDENTIFIER, "b"},
+			{tk.OR, "||"},
+			{tk.IDENTIFIER, "c"},
+			{tk.EOF, ""},
+		},
+	},
+
+	"range": {
+		input: `[...0..<n, 1..2]`,
+		expect: []expectations{
+			{tk.LBRACKET, "["},
+			{tk.ELLIPSIS, "..."},
+			{tk.INT, "0"},
+			{tk.RANGE_EXCLUSIVE, "..<"},
+			{tk.IDENTIFIER, "n"},
+			{tk.COMMA, ","},
+			{tk.INT, "1"},
+			{tk.RANGE, ".."},
+			{tk.INT, "2"},
+			{tk.RBRACKET, "]"},
+			{tk.EOF, ""},
+		},
+	},
+
+	"for-in": {
+		input: `for (x in xs)`,
+		expect: []expectations{
+			{tk.FOR, "for"},
+			{tk.LPAREN, "("},
+			{tk.IDENTIFIER, "x"},
+			{tk.IN, "in"},
+			{tk.IDENTIFIER, "xs"},
+			{tk.RPAREN, ")"},
+			{tk.EOF, ""},
+		},
+	},
+
+	"generator": {
+		input: `fn* (n) { yield n; }`,
+		expect: []expectations{
+			{tk.FUNCTION, "fn"},
+			{tk.ASTRICK, "*"},
+			{tk.LPAREN, "("},
+			{tk.IDENTIFIER, "n"},
+			{tk.RPAREN, ")"},
+			{tk.LBRACE, "{"},
+			{tk.YIELD, "yield"},
+			{tk.IDENTIFIER, "n"},
+			{tk.SEMICOLON, ";"},
+			{tk.RBRACE, "}"},
+			{tk.EOF, ""},
+		},
+	},
+
+	"struct": {
+		input: `struct Point { x, y } p.x = 1`,
+		expect: []expectations{
+			{tk.STRUCT, "struct"},
+			{tk.IDENTIFIER, "Point"},
+			{tk.LBRACE, "{"},
+			{tk.IDENTIFIER, "x"},
+			{tk.COMMA, ","},
+			{tk.IDENTIFIER, "y"},
+			{tk.RBRACE, "}"},
+			{tk.IDENTIFIER, "p"},
+			{tk.DOT, "."},
+			{tk.IDENTIFIER, "x"},
+			{tk.ASSIGN, "="},
+			{tk.INT, "1"},
+			{tk.EOF, ""},
+		},
+	},
+
+	"impl": {
+		input: `impl P { fn get(self) }`,
+		expect: []expectations{
+			{tk.IMPL, "impl"},
+			{tk.IDENTIFIER, "P"},
+			{tk.LBRACE, "{"},
+			{tk.FUNCTION, "fn"},
+			{tk.IDENTIFIER, "get"},
+			{tk.LPAREN, "("},
+			{tk.IDENTIFIER, "self"},
+			{tk.RPAREN, ")"},
+			{tk.RBRACE, "}"},
+			{tk.EOF, ""},
+		},
+	},
+
+	"enum": {
+		input: `enum Shape { Circle(r), Empty }`,
+		expect: []expectations{
+			{tk.ENUM, "enum"},
+			{tk.IDENTIFIER, "Shape"},
+			{tk.LBRACE, "{"},
+			{tk.IDENTIFIER, "Circle"},
+			{tk.LPAREN, "("},
+			{tk.IDENTIFIER, "r"},
+			{tk.RPAREN, ")"},
+			{tk.COMMA, ","},
+			{tk.IDENTIFIER, "Empty"},
+			{tk.RBRACE, "}"},
+			{tk.EOF, ""},
+		},
+	},
+
+	"modules": {
+		input: `import "lib/math" as m; export let x = m.pi;`,
+		expect: []expectations{
+			{tk.IMPORT, "import"},
+			{tk.STRING, "lib/math"},
+			{tk.AS, "as"},
+			{tk.IDENTIFIER, "m"},
+			{tk.SEMICOLON, ";"},
+			{tk.EXPORT, "export"},
+			{tk.LET, "let"},
+			{tk.IDENTIFIER, "x"},
+			{tk.ASSIGN, "="},
+			{tk.IDENTIFIER, "m"},
+			{tk.DOT, "."},
+			{tk.IDENTIFIER, "pi"},
+			{tk.SEMICOLON, ";"},
+			{tk.EOF, ""},
+		},
+	},
+
+	"comments": {
+		input: "// a comment\nlet x = 10 / 2; // another\n// last",
+		expect: []expectations{
+			{tk.LET, "let"},
+			{tk.IDENTIFIER, "x"},
+			{tk.ASSIGN, "="},
+			{tk.INT, "10"},
+			{tk.SLASH, "/"},
+			{tk.INT, "2"},
+			{tk.SEMICOLON, ";"},
+			{tk.EOF, ""},
+		},
+	},
+
+	"floats": {
+		input: "1.5 0.25 2e10 1E-3 6.02e+23 1..5 4.len 3e x1.5",
+		expect: []expectations{
+			{tk.FLOAT, "1.5"},
+			{tk.FLOAT, "0.25"},
+			{tk.FLOAT, "2e10"},
+			{tk.FLOAT, "1E-3"},
+			{tk.FLOAT, "6.02e+23"},
+			{tk.INT, "1"},
+			{tk.RANGE, ".."},
+			{tk.INT, "5"},
+			{tk.INT, "4"},
+			{tk.DOT, "."},
+			{tk.IDENTIFIER, "len"},
+			{tk.INT, "3"},
+			{tk.IDENTIFIER, "e"},
+			{tk.IDENTIFIER, "x1"},
+			{tk.DOT, "."},
+			{tk.INT, "5"},
+			{tk.EOF, ""},
+		},
+	},
+
 	"double-symbols": {
 		input: `
 			if x == 5
@@ -4,28 +4,58 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"mkc/ast"
 	"mkc/eval"
 	"mkc/lexer"
 	obj "mkc/object"
 	"mkc/parser"
 	"mkc/repl"
+	"mkc/resolver"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 )
 
 const VERSION = "0.1.0"
 
 func main() {
+	options := &obj.Options{}
+	flag.BoolVar(&options.StrictLet, "strict-let", false, "reject let redeclaration in the same scope")
+	flag.BoolVar(&options.StrictBool, "strict-bool", false, "only accept booleans as conditions")
+	flag.Func("seed", "seed std/random with an integer, for reproducible runs", func(s string) error {
+		seed, err := strconv.ParseInt(s, 10, 64)
+		options.Seed = &seed
+		return err
+	})
+	flag.Func("allow-read", "let std/fs read under directories, separated by commas", grant(&options.AllowRead))
+	flag.Func("allow-write", "let std/fs write under directories, separated by commas", grant(&options.AllowWrite))
 	flag.Parse()
+	options.Path = filepath.SplitList(os.Getenv("MONKEY_PATH"))
 
 	if len(flag.Args()) == 0 {
-		repl.Start(os.Stdin, os.Stdout)
+		repl.Start(os.Stdin, os.Stdout, options)
 		return
 	}
 
-	runFile(flag.Arg(0))
+	runFile(flag.Arg(0), options)
 }
 
-func runFile(fname string) {
+// Returns a flag setter adding the directories in its value to dirs, so
+// the flag can be repeated
+func grant(dirs *[]string) func(string) error {
+	return func(s string) error {
+		for _, dir := range strings.Split(s, ",") {
+			if dir == "" {
+				return fmt.Errorf("empty directory in %q", s)
+			}
+			*dirs = append(*dirs, dir)
+		}
+		return nil
+	}
+}
+
+func runFile(fname string, options *obj.Options) {
 	f, err := os.Open(fname)
 	if err != nil {
 		fmt.Printf(
@@ -52,10 +82,45 @@ func runFile(fname string) {
 
 	l := lexer.New(string(contents))
 	p := parser.New(l)
+	p.SetStrictLet(options.StrictLet)
 	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		fmt.Println("Parser errors:")
+		for _, msg := range p.Errors() {
+			fmt.Println("\t" + msg)
+		}
+		return
+	}
+
+	if !check(program, options) {
+		return
+	}
 
-	env := obj.NewEnvironment()
+	env := obj.NewEnvironmentWithOptions(options)
+
+	evaluated := eval.EvalFile(program, fname, env)
+	if evaluated != nil {
+		fmt.Println(evaluated.Inspect())
+	}
+
+	if err, ok := evaluated.(*obj.Error); ok {
+		for _, frame := range err.Stack {
+			fmt.Println("\tin " + frame)
+		}
+	}
+}
+
+// Resolves the program before running it, printing its diagnostics
+// Returns false if it has errors
+func check(program *ast.Program, options *obj.Options) bool {
+	r := resolver.New()
+	r.SetStrictLet(options.StrictLet)
+	r.Define(eval.BuiltinNames()...)
+
+	diagnostics := r.Resolve(program)
+	for _, d := range diagnostics {
+		fmt.Fprintln(os.Stderr, d.String())
+	}
 
-	evaluated := eval.Eval(program, env)
-	fmt.Println(evaluated.Inspect())
+	return !resolver.HasErrors(diagnostics)
 }
@@ -1,30 +1,223 @@
 package object
 
+import "mkc/token"
+
+// Settings shared by every scope of one interpreter
+type Options struct {
+	StrictLet  bool     // reject let redeclaration in the same scope
+	StrictBool bool     // conditions must be booleans, rather than truthy values
+	Path       []string // directories searched for imports, after the importing file's
+	Seed       *int64   // seeds std/random, which is seeded from the clock without it
+	AllowRead  []string // directories std/fs may read under, none without it
+	AllowWrite []string // directories std/fs may write under, none without it
+}
+
+// Modules loaded by one interpreter, shared by the environments of all of
+// them. A module is in Loaded by file from the start of its evaluation, and
+// in Loading until its end, so an import cycle finds it there. Builtins
+// bound to the interpreter's options are kept by name in Builtins.
+type Modules struct {
+	Loaded		map[string]*Module
+	Loading		[]*Module
+	Builtins	map[string]*Builtin
+}
+
+// Where and how a name was declared
+type Binding struct {
+	Token tk.Token
+	Const bool
+	Block int // block of its scope it was declared in, 0 for the whole scope
+}
+
+// Names are bound either by name in store, or by index in slots when the
+// resolver could place them statically. Maps are only allocated once a
+// name is bound in them, so function calls that use slots alone stay cheap.
 type Environment struct {
 	store	map[string]Object
+	decls	map[string]Binding
+	slots	[]Object
 	outer	*Environment
+	options	*Options
+	modules	*Modules
+	module	*Module  // set on the top scope of a module
+	yielder	Yielder // set on the scope of a running generator's call
+
+	// Blocks of if, try and other statements share the scope around them,
+	// as the resolver's do. depth of them are running in this scope, and
+	// blocks holds the ids of the outermost ones, given out when something
+	// is declared in them.
+	depth		int
+	blocks		[]int
+	lastBlock	int
 }
 
 func NewEnvironment() *Environment {
-	s := make(map[string]Object)
-	return &Environment{store: s}
+	return NewEnvironmentWithOptions(&Options{})
+}
+
+func NewEnvironmentWithOptions(options *Options) *Environment {
+	return &Environment{
+		options: options,
+		modules: &Modules{Loaded: map[string]*Module{}, Builtins: map[string]*Builtin{}},
+	}
+}
+
+// Returns the top scope of a module, sharing the options and loaded
+// modules of the interpreter that imports it
+func NewModuleEnvironment(importer *Environment, module *Module) *Environment {
+	env := &Environment{options: importer.options, modules: importer.modules}
+	env.SetModule(module)
+	return env
+}
+
+// Makes this scope the top scope of module
+func (e *Environment) SetModule(module *Module) {
+	e.module = module
+	module.Env = e
 }
 
 func NewEnclosedEnvironment(outer *Environment) *Environment {
-	env := NewEnvironment()
-	env.outer = outer
+	return NewSlottedEnvironment(outer, 0)
+}
+
+// Returns an enclosed environment with room for size resolved names
+func NewSlottedEnvironment(outer *Environment, size int) *Environment {
+	env := &Environment{options: outer.options, modules: outer.modules, outer: outer}
+	if size > 0 {
+		env.slots = make([]Object, size)
+	}
 	return env
 }
 
+func (e *Environment) Options() *Options {
+	return e.options
+}
+
+func (e *Environment) Modules() *Modules {
+	return e.modules
+}
+
+// Returns the module env is part of, or nil outside of any file
+func (e *Environment) Module() *Module {
+	for env := e; env != nil; env = env.outer {
+		if env.module != nil {
+			return env.module
+		}
+	}
+	return nil
+}
+
 func (e *Environment) Get(name string) (Object, bool) {
 	obj, ok := e.store[name]
 	if !ok && e.outer != nil {
-		obj, ok = e.outer.store[name]
+		obj, ok = e.outer.Get(name)
 	}
 	return obj, ok
 }
 
 func (e *Environment) Set(name string, val Object) (Object, bool) {
+	if e.store == nil {
+		e.store = make(map[string]Object)
+	}
 	e.store[name] = val
 	return val, true
 }
+
+// Binds name in this scope and remembers its declaration, in the
+// innermost block running
+func (e *Environment) Declare(name string, val Object, b Binding) {
+	e.Set(name, val)
+	if e.decls == nil {
+		e.decls = make(map[string]Binding)
+	}
+	for len(e.blocks) < e.depth {
+		e.lastBlock += 1
+		e.blocks = append(e.blocks, e.lastBlock)
+	}
+	if e.depth > 0 {
+		b.Block = e.blocks[e.depth-1]
+	}
+	e.decls[name] = b
+}
+
+// Starts running a block in this scope, its declarations are made in it
+// until EndBlock
+func (e *Environment) BeginBlock() {
+	e.depth += 1
+	if len(e.blocks) >= e.depth {
+		e.blocks = e.blocks[:e.depth-1]
+	}
+}
+
+func (e *Environment) EndBlock() {
+	e.depth -= 1
+	if len(e.blocks) > e.depth {
+		e.blocks = e.blocks[:e.depth]
+	}
+}
+
+// Reports whether the block a declaration was made in is still running,
+// so that declaring its name again conflicts with it. A declaration in a
+// block that has ended, such as a sibling if block, does not.
+func (e *Environment) Active(b Binding) bool {
+	if b.Block == 0 {
+		return true
+	}
+	for _, block := range e.blocks {
+		if block == b.Block {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns how name was declared in this scope, ignoring outer scopes
+func (e *Environment) Declaration(name string) (Binding, bool) {
+	b, ok := e.decls[name]
+	return b, ok
+}
+
+// Returns the yielder of the generator whose body env is in, or nil
+func (e *Environment) Yielder() Yielder {
+	for env := e; env != nil; env = env.outer {
+		if env.yielder != nil {
+			return env.yielder
+		}
+	}
+	return nil
+}
+
+// Makes this scope the body of a generator, yielding to y
+func (e *Environment) SetYielder(y Yielder) {
+	e.yielder = y
+}
+
+// Returns the nearest scope holding name, or nil
+func (e *Environment) Scope(name string) *Environment {
+	if _, ok := e.store[name]; ok {
+		return e
+	}
+	if e.outer != nil {
+		return e.outer.Scope(name)
+	}
+	return nil
+}
+
+// Returns the value in a slot of the environment depth levels out,
+// or nil if nothing is bound there yet
+func (e *Environment) GetSlot(depth, slot int) Object {
+	env := e
+	for ; depth > 0; depth-- {
+		env = env.outer
+	}
+	return env.slots[slot]
+}
+
+// Binds a value to a slot of the environment depth levels out
+func (e *Environment) SetSlot(depth, slot int, val Object) {
+	env := e
+	for ; depth > 0; depth-- {
+		env = env.outer
+	}
+	env.slots[slot] = val
+}
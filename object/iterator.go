@@ -0,0 +1,193 @@
+package object
+
+import (
+	"fmt"
+	"math"
+	"unicode/utf8"
+)
+
+///////////////
+// Iteration //
+///////////////
+
+// Produces the elements of a sequence one at a time, until ok is false.
+// When producing an element fails, the element is the *Error and
+// iteration should stop there.
+type Iterator interface {
+	Next() (Object, bool)
+}
+
+// Implemented by iterators that hold on to something, such as a suspended
+// generator, to release it when the consumer stops before the end
+type Closer interface {
+	Close()
+}
+
+// Closes it if it holds on to something, a no-op otherwise
+func CloseIterator(it Iterator) {
+	if c, ok := it.(Closer); ok {
+		c.Close()
+	}
+}
+
+// Receives what the body of a generator yields
+type Yielder interface {
+	// Hands val to the consumer and waits until the next one is wanted.
+	// Returns false if the consumer stopped instead, and the body
+	// should return.
+	Yield(val Object) bool
+}
+
+// Implemented by objects that can be looped over with for-in or spread
+type Iterable interface {
+	Iter() Iterator
+}
+
+// Arrays yield their elements
+func (a *Array) Iter() Iterator {
+	return &arrayIterator{elements: a.Elements}
+}
+
+type arrayIterator struct {
+	elements []Object
+	next     int
+}
+
+func (it *arrayIterator) Next() (Object, bool) {
+	if it.next >= len(it.elements) {
+		return nil, false
+	}
+	it.next += 1
+	return it.elements[it.next-1], true
+}
+
+// Strings yield their characters, one string per rune
+func (s *String) Iter() Iterator {
+	return &stringIterator{value: s.Value}
+}
+
+type stringIterator struct {
+	value string
+	next  int
+}
+
+func (it *stringIterator) Next() (Object, bool) {
+	if it.next >= len(it.value) {
+		return nil, false
+	}
+	_, size := utf8.DecodeRuneInString(it.value[it.next:])
+	it.next += size
+	return &String{Value: it.value[it.next-size : it.next]}, true
+}
+
+// Hashes yield their keys, in insertion order
+func (h *Hash) Iter() Iterator {
+	keys := make([]Object, len(h.Pairs))
+	for i, pair := range h.Pairs {
+		keys[i] = pair.Key
+	}
+	return &arrayIterator{elements: keys}
+}
+
+// Ranges
+
+// Integers from Start up to but not including Stop, Step apart, or up to
+// and including Stop for an inclusive range such as 1..5, whose Stop may be
+// the largest integer. The numbers are produced as they are iterated, never
+// stored.
+type Range struct {
+	Start		int64
+	Stop		int64
+	Step		int64 // never zero, negative to count down
+	Inclusive	bool
+}
+
+func (r *Range) Type() ObjectType { return RANGE_OBJ }
+func (r *Range) Inspect() string {
+	switch {
+	case r.Step == 1 && r.Inclusive:
+		return fmt.Sprintf("%d..%d", r.Start, r.Stop)
+	case r.Step == 1:
+		return fmt.Sprintf("%d..<%d", r.Start, r.Stop)
+	}
+	return fmt.Sprintf("range(%d, %d, %d)", r.Start, r.Stop, r.Step)
+}
+
+// Ranges with the same numbers are equal, however they were written
+func (r *Range) Equals(other Object) bool {
+	o, ok := other.(*Range)
+	if !ok || r.Len() != o.Len() {
+		return false
+	}
+	switch r.Len() {
+	case 0:
+		return true
+	case 1:
+		return r.Start == o.Start
+	}
+	return r.Start == o.Start && r.Step == o.Step
+}
+
+// Returns how many numbers the range holds. It counts in uint64, where the
+// distance between any two integers fits, and ranges of more numbers than
+// the largest integer count as that many.
+func (r *Range) Len() int64 {
+	var distance, step uint64
+	if r.Step > 0 {
+		if r.Start > r.Stop || (r.Start == r.Stop && !r.Inclusive) {
+			return 0
+		}
+		distance, step = uint64(r.Stop-r.Start), uint64(r.Step)
+	} else {
+		if r.Start < r.Stop || (r.Start == r.Stop && !r.Inclusive) {
+			return 0
+		}
+		distance, step = uint64(r.Start-r.Stop), uint64(-r.Step)
+	}
+
+	// the last number is at most distance away from Start
+	if !r.Inclusive {
+		distance -= 1
+	}
+	n := distance/step + 1
+	if n == 0 || n > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(n)
+}
+
+// Returns the i-th number of the range
+func (r *Range) At(i int64) int64 {
+	return r.Start + i*r.Step
+}
+
+func (r *Range) Iter() Iterator {
+	return &rangeIterator{r: r, len: r.Len()}
+}
+
+type rangeIterator struct {
+	r    *Range
+	len  int64
+	next int64
+}
+
+func (it *rangeIterator) Next() (Object, bool) {
+	if it.next >= it.len {
+		return nil, false
+	}
+	it.next += 1
+	return &Integer{Value: it.r.At(it.next - 1)}, true
+}
+
+// Sequences
+
+// A lazy sequence as a value, such as map over a range returns.
+// Its elements are produced as it is iterated, and only once.
+type Sequence struct {
+	Source	Iterator
+}
+
+func (s *Sequence) Type() ObjectType { return ITERATOR_OBJ }
+func (s *Sequence) Inspect() string { return "iterator" }
+func (s *Sequence) Equals(other Object) bool { return s == other }
+func (s *Sequence) Iter() Iterator { return s.Source }
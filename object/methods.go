@@ -0,0 +1,63 @@
+package object
+
+/////////////
+// Methods //
+/////////////
+
+// Methods of the values of one type, by name. A method is a function
+// called with the value it was looked up on, its receiver, as first
+// argument, so value.name(a, b) calls name(value, a, b).
+type MethodTable map[string]Object
+
+var methodTables = map[ObjectType]MethodTable{}
+
+// Returns the methods of a builtin type, making its table when needed.
+// Builtin methods are registered in it from Go, like builtin functions:
+//
+//	obj.Methods(obj.ARRAY_OBJ)["push"] = &obj.Builtin{Name: "push", Fn: arrayPush}
+//
+// Tables are shared by every interpreter, so they should only be filled
+// in before any program runs, such as from init.
+func Methods(t ObjectType) MethodTable {
+	table, ok := methodTables[t]
+	if !ok {
+		table = MethodTable{}
+		methodTables[t] = table
+	}
+	return table
+}
+
+// Returns the method name of a value, from its struct or enum type for
+// their values, and from the table of its builtin type otherwise
+func LookupMethod(o Object, name string) (Object, bool) {
+	switch v := o.(type) {
+	case *Struct:
+		method, ok := v.StructType.Methods[name]
+		return method, ok
+	case *EnumValue:
+		method, ok := v.Variant.Enum.Methods[name]
+		return method, ok
+	}
+
+	method, ok := methodTables[o.Type()][name]
+	return method, ok
+}
+
+// A method looked up without being called, as in let push = xs.push;
+// Calling it calls the method with the receiver it was looked up on.
+type BoundMethod struct {
+	Receiver	Object
+	Name		string
+	Method		Object
+}
+
+func (bm *BoundMethod) Type() ObjectType { return BOUND_METHOD_OBJ }
+func (bm *BoundMethod) Inspect() string {
+	return "bound method " + bm.Name + " of " + bm.Receiver.Inspect()
+}
+
+// Bound methods are equal when they bind the same method to the same receiver
+func (bm *BoundMethod) Equals(other Object) bool {
+	o, ok := other.(*BoundMethod)
+	return ok && bm.Receiver == o.Receiver && bm.Method == o.Method
+}
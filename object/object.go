@@ -3,7 +3,11 @@ package object
 import (
 	"bytes"
 	"fmt"
+	"hash/fnv"
+	"math"
+	"math/big"
 	"mkc/ast"
+	"strconv"
 	"strings"
 )
 
@@ -17,15 +21,37 @@ type ObjectType string
 type Object interface {
 	Type() ObjectType
 	Inspect() string
+	// Structural equality, used by == and !=. Values of different
+	// types are never equal, except numbers of equal value, as 1 == 1.0.
+	// Functions and errors are equal only to themselves.
+	Equals(other Object) bool
 }
 
 const (
 	INTEGER_OBJ 	= "INTEGER"
+	FLOAT_OBJ		= "FLOAT"
+	BIGINT_OBJ		= "BIGINT"
 	BOOLEAN_OBJ 	= "BOOLEAN"
 	NULL_OBJ 		= "NULL"
+	STRING_OBJ		= "STRING"
+	ARRAY_OBJ		= "ARRAY"
+	HASH_OBJ		= "HASH"
+	RANGE_OBJ		= "RANGE"
+	ITERATOR_OBJ	= "ITERATOR"
+	STRUCT_OBJ		= "STRUCT"
+	STRUCT_TYPE_OBJ	= "STRUCT_TYPE"
+	ENUM_OBJ		= "ENUM"
+	ENUM_TYPE_OBJ	= "ENUM_TYPE"
+	VARIANT_OBJ		= "VARIANT"
 	ERROR_OBJ		= "ERROR"
+	EXCEPTION_OBJ	= "EXCEPTION"
 	RETURN_OBJ		= "RETURN"
+	EARLY_RETURN_OBJ = "EARLY_RETURN"
 	FUNCTION_OBJ	= "FUNCTION"
+	BUILTIN_OBJ		= "BUILTIN"
+	BOUND_METHOD_OBJ = "BOUND_METHOD"
+	RESULT_OBJ		= "RESULT"
+	MODULE_OBJ		= "MODULE"
 )
 
 /////////////
@@ -40,6 +66,71 @@ type Integer struct {
 
 func (i *Integer) Inspect() string { return fmt.Sprintf("%d", i.Value) }
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
+func (i *Integer) Equals(other Object) bool {
+	switch o := other.(type) {
+	case *Integer:
+		return i.Value == o.Value
+	case *Float:
+		return o.Equals(i)
+	}
+	return false
+}
+
+
+type Float struct {
+	Value	float64
+}
+
+// Shows the shortest digits that read back as the same float, with an
+// exponent only for very small or large values, and always a fraction or
+// an exponent, so 1.0 does not print as 1
+func (f *Float) Inspect() string {
+	format := byte('f')
+	if abs := math.Abs(f.Value); abs != 0 && (abs < 1e-4 || abs >= 1e21) {
+		format = 'e'
+	}
+	s := strconv.FormatFloat(f.Value, format, -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+func (f *Float) Equals(other Object) bool {
+	switch o := other.(type) {
+	case *Float:
+		return f.Value == o.Value
+	case *Integer:
+		return f.isInteger() && big.NewFloat(f.Value).Cmp(new(big.Float).SetInt64(o.Value)) == 0
+	case *BigInt:
+		return f.isInteger() && big.NewFloat(f.Value).Cmp(new(big.Float).SetInt(o.Value)) == 0
+	}
+	return false
+}
+
+// Reports whether f has no fraction, and is neither infinite nor NaN
+func (f *Float) isInteger() bool {
+	return !math.IsInf(f.Value, 0) && f.Value == math.Trunc(f.Value)
+}
+
+
+// Integers that overflow 64 bits. Arithmetic makes a BigInt only for
+// values out of the range of Integer, so the two are never equal.
+type BigInt struct {
+	Value	*big.Int
+}
+
+func (b *BigInt) Inspect() string { return b.Value.String() }
+func (b *BigInt) Type() ObjectType { return BIGINT_OBJ }
+func (b *BigInt) Equals(other Object) bool {
+	switch o := other.(type) {
+	case *BigInt:
+		return b.Value.Cmp(o.Value) == 0
+	case *Float:
+		return o.Equals(b)
+	}
+	return false
+}
 
 
 type Boolean struct {
@@ -48,12 +139,455 @@ type Boolean struct {
 
 func (b *Boolean) Inspect() string { return fmt.Sprintf("%t", b.Value) }
 func (b *Boolean) Type() ObjectType { return BOOLEAN_OBJ }
+func (b *Boolean) Equals(other Object) bool {
+	o, ok := other.(*Boolean)
+	return ok && b.Value == o.Value
+}
 
 
 type Null struct {}
 
 func (n *Null) Inspect() string { return "null" }
 func (n *Null) Type() ObjectType { return NULL_OBJ }
+func (n *Null) Equals(other Object) bool {
+	_, ok := other.(*Null)
+	return ok
+}
+
+
+type String struct {
+	Value	string
+}
+
+func (s *String) Inspect() string { return s.Value }
+func (s *String) Type() ObjectType { return STRING_OBJ }
+func (s *String) Equals(other Object) bool {
+	o, ok := other.(*String)
+	return ok && s.Value == o.Value
+}
+
+
+type Array struct {
+	Elements	[]Object
+}
+
+func (a *Array) Type() ObjectType { return ARRAY_OBJ }
+func (a *Array) Equals(other Object) bool { return equals(a, other, comparing{}) }
+func (a *Array) equals(other Object, c comparing) bool {
+	o, ok := other.(*Array)
+	if !ok || len(a.Elements) != len(o.Elements) {
+		return false
+	}
+	for i, e := range a.Elements {
+		if !equals(e, o.Elements[i], c) {
+			return false
+		}
+	}
+	return true
+}
+func (a *Array) Inspect() string { return inspect(a, inspecting{}) }
+func (a *Array) inspect(in inspecting) string {
+	var elements []string
+	for _, e := range a.Elements {
+		elements = append(elements, inspect(e, in))
+	}
+
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// Cycles
+
+// Arrays and structs can be changed to contain themselves, directly or
+// through other values, so values holding others print and compare through
+// inspect and equals, which stop at a cycle.
+type composite interface {
+	Object
+	inspect(in inspecting) string
+	equals(other Object, c comparing) bool
+}
+
+// Values being printed, from the outermost one in
+type inspecting map[Object]bool
+
+// Pairs of values being compared, or already found equal
+type comparing map[[2]Object]bool
+
+// Returns the text of o, printing a value inside itself as [...], {...},
+// Name{...} or Variant(...)
+func inspect(o Object, in inspecting) string {
+	c, ok := o.(composite)
+	if !ok {
+		return o.Inspect()
+	}
+	if in[o] {
+		switch o := o.(type) {
+		case *Array:
+			return "[...]"
+		case *Hash:
+			return "{...}"
+		case *Struct:
+			return o.StructType.Name + "{...}"
+		case *EnumValue:
+			return o.Variant.Inspect() + "(...)"
+		}
+		return "..."
+	}
+	in[o] = true
+	defer delete(in, o)
+	return c.inspect(in)
+}
+
+// Reports whether a equals b. A pair met again while it is compared is
+// taken as equal, so two values with the same cycles are equal.
+func equals(a Object, b Object, c comparing) bool {
+	ca, ok := a.(composite)
+	if !ok {
+		return a.Equals(b)
+	}
+	if a == b {
+		return true
+	}
+	pair := [2]Object{a, b}
+	if c[pair] {
+		return true
+	}
+	c[pair] = true
+	return ca.equals(b, c)
+}
+
+// Truthiness
+
+// Reports whether a value counts as true in a condition.
+// false, null, 0, 0.0, "", and empty arrays, hashes and ranges are false,
+// every other value is true.
+func Truthy(o Object) bool {
+	switch o := o.(type) {
+	case *Boolean:
+		return o.Value
+	case *Null:
+		return false
+	case *Integer:
+		return o.Value != 0
+	case *Float:
+		return o.Value != 0
+	case *BigInt:
+		return o.Value.Sign() != 0
+	case *String:
+		return o.Value != ""
+	case *Array:
+		return len(o.Elements) > 0
+	case *Hash:
+		return len(o.Pairs) > 0
+	case *Range:
+		return o.Len() > 0
+	}
+	return true
+}
+
+// Hashes
+
+// Identifies a hash key by type and value, so equal keys find the same pair
+type HashKey struct {
+	Type	ObjectType
+	Value	uint64
+}
+
+// Implemented by objects that can be used as hash keys
+// Keys that are Equals have the same HashKey
+type Hashable interface {
+	HashKey() HashKey
+}
+
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+// An integral float has the key of the integer it equals
+func (f *Float) HashKey() HashKey {
+	if f.isInteger() {
+		if f.Value >= math.MinInt64 && f.Value < -math.MinInt64 {
+			return (&Integer{Value: int64(f.Value)}).HashKey()
+		}
+		n, _ := big.NewFloat(f.Value).Int(nil)
+		return (&BigInt{Value: n}).HashKey()
+	}
+	return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+}
+
+func (b *BigInt) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(b.Value.String()))
+	return HashKey{Type: b.Type(), Value: h.Sum64()}
+}
+
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+	if b.Value {
+		value = 1
+	}
+	return HashKey{Type: b.Type(), Value: value}
+}
+
+func (s *String) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(s.Value))
+	return HashKey{Type: s.Type(), Value: h.Sum64()}
+}
+
+type HashPair struct {
+	Key		Object
+	Value	Object
+}
+
+// Pairs are kept in insertion order, so hashes print and iterate predictably.
+// Keys are looked up by their HashKey, then told apart by Equals from other
+// keys with the same one.
+type Hash struct {
+	Pairs	[]HashPair
+	buckets	map[HashKey][]int // positions in Pairs of the keys with each HashKey
+}
+
+func NewHash() *Hash {
+	return &Hash{buckets: make(map[HashKey][]int)}
+}
+
+// Returns the position in Pairs of key, whose HashKey is hk, or -1
+func (h *Hash) find(key Object, hk HashKey) int {
+	for _, i := range h.buckets[hk] {
+		if h.Pairs[i].Key.Equals(key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Returns the value stored under key
+func (h *Hash) Get(key Hashable) (Object, bool) {
+	if i := h.find(key.(Object), key.HashKey()); i >= 0 {
+		return h.Pairs[i].Value, true
+	}
+	return nil, false
+}
+
+// Stores a value under key, keeping the position of an existing key
+func (h *Hash) Set(key Hashable, value Object) {
+	hk := key.HashKey()
+	pair := HashPair{Key: key.(Object), Value: value}
+	if i := h.find(pair.Key, hk); i >= 0 {
+		h.Pairs[i] = pair
+		return
+	}
+	h.buckets[hk] = append(h.buckets[hk], len(h.Pairs))
+	h.Pairs = append(h.Pairs, pair)
+}
+
+func (h *Hash) Type() ObjectType { return HASH_OBJ }
+// Hashes with the same pairs are equal, whatever their order
+func (h *Hash) Equals(other Object) bool { return equals(h, other, comparing{}) }
+func (h *Hash) equals(other Object, c comparing) bool {
+	o, ok := other.(*Hash)
+	if !ok || len(h.Pairs) != len(o.Pairs) {
+		return false
+	}
+	for _, pair := range h.Pairs {
+		i := o.find(pair.Key, pair.Key.(Hashable).HashKey())
+		if i < 0 || !equals(pair.Value, o.Pairs[i].Value, c) {
+			return false
+		}
+	}
+	return true
+}
+func (h *Hash) Inspect() string { return inspect(h, inspecting{}) }
+func (h *Hash) inspect(in inspecting) string {
+	var pairs []string
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, inspectKey(pair.Key)+": "+inspect(pair.Value, in))
+	}
+
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// Quotes string keys, so {"1": 1} and {1: 1} print differently
+func inspectKey(key Object) string {
+	if s, ok := key.(*String); ok {
+		return fmt.Sprintf("%q", s.Value)
+	}
+	return key.Inspect()
+}
+
+// Structs
+
+// Declared by struct NAME { FIELDS }, calling it makes an instance.
+// impl NAME { ... } blocks add to its methods.
+type StructType struct {
+	Name	string
+	Fields	[]string
+	Methods	MethodTable
+}
+
+func (st *StructType) Type() ObjectType { return STRUCT_TYPE_OBJ }
+func (st *StructType) Equals(other Object) bool { return st == other }
+func (st *StructType) Inspect() string {
+	return "struct " + st.Name + " { " + strings.Join(st.Fields, ", ") + " }"
+}
+
+// Returns the position of a field, or -1 if the struct has no such field
+func (st *StructType) FieldIndex(name string) int {
+	for i, field := range st.Fields {
+		if field == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// An instance of a struct, with a value for each field of its type.
+// Instances are mutable, updating a field is seen through every reference.
+type Struct struct {
+	StructType	*StructType
+	Values		[]Object
+}
+
+func (s *Struct) Type() ObjectType { return STRUCT_OBJ }
+func (s *Struct) Inspect() string { return inspect(s, inspecting{}) }
+func (s *Struct) inspect(in inspecting) string {
+	fields := make([]string, len(s.Values))
+	for i, val := range s.Values {
+		fields[i] = s.StructType.Fields[i] + ": " + inspect(val, in)
+	}
+	return s.StructType.Name + "{" + strings.Join(fields, ", ") + "}"
+}
+
+// Instances of the same struct are equal when all their fields are
+func (s *Struct) Equals(other Object) bool { return equals(s, other, comparing{}) }
+func (s *Struct) equals(other Object, c comparing) bool {
+	o, ok := other.(*Struct)
+	if !ok || s.StructType != o.StructType {
+		return false
+	}
+	for i, val := range s.Values {
+		if !equals(val, o.Values[i], c) {
+			return false
+		}
+	}
+	return true
+}
+
+// Enums
+
+// Declared by enum NAME { VARIANTS }, its variants are reached as NAME.VARIANT.
+// impl NAME { ... } blocks add to its methods.
+type EnumType struct {
+	Name		string
+	Variants	[]*Variant
+	Methods		MethodTable
+}
+
+func (et *EnumType) Type() ObjectType { return ENUM_TYPE_OBJ }
+func (et *EnumType) Equals(other Object) bool { return et == other }
+func (et *EnumType) Inspect() string {
+	variants := make([]string, len(et.Variants))
+	for i, v := range et.Variants {
+		variants[i] = v.Name
+		if v.Fields != nil {
+			variants[i] += "(" + strings.Join(v.Fields, ", ") + ")"
+		}
+	}
+	return "enum " + et.Name + " { " + strings.Join(variants, ", ") + " }"
+}
+
+// Returns the variant with name, or nil
+func (et *EnumType) Variant(name string) *Variant {
+	for _, v := range et.Variants {
+		if v.Name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+// One variant of an enum. A variant with fields is called to make a value,
+// one without fields has a single value, Unit.
+type Variant struct {
+	Enum	*EnumType
+	Name	string
+	Fields	[]string // nil for a variant without fields
+	Unit	*EnumValue
+}
+
+func (v *Variant) Type() ObjectType { return VARIANT_OBJ }
+func (v *Variant) Equals(other Object) bool { return v == other }
+func (v *Variant) Inspect() string { return v.Enum.Name + "." + v.Name }
+
+// Returns the position of a field, or -1 if the variant has no such field
+func (v *Variant) FieldIndex(name string) int {
+	for i, field := range v.Fields {
+		if field == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// A value of an enum, with a value for each field of its variant.
+// Enum values cannot be changed.
+type EnumValue struct {
+	Variant	*Variant
+	Values	[]Object
+}
+
+func (ev *EnumValue) Type() ObjectType { return ENUM_OBJ }
+func (ev *EnumValue) Inspect() string { return inspect(ev, inspecting{}) }
+func (ev *EnumValue) inspect(in inspecting) string {
+	if ev.Variant.Fields == nil {
+		return ev.Variant.Inspect()
+	}
+	values := make([]string, len(ev.Values))
+	for i, val := range ev.Values {
+		values[i] = inspect(val, in)
+	}
+	return ev.Variant.Inspect() + "(" + strings.Join(values, ", ") + ")"
+}
+
+// Values of the same variant are equal when all their fields are
+func (ev *EnumValue) Equals(other Object) bool { return equals(ev, other, comparing{}) }
+func (ev *EnumValue) equals(other Object, c comparing) bool {
+	o, ok := other.(*EnumValue)
+	if !ok || ev.Variant != o.Variant {
+		return false
+	}
+	for i, val := range ev.Values {
+		if !equals(val, o.Values[i], c) {
+			return false
+		}
+	}
+	return true
+}
+
+// Modules
+
+// The top level of a file, bound by import PATH as NAME. Exports are read
+// from the environment the module ran in, so they see later assignments.
+type Module struct {
+	Name	string // path the module was imported by
+	File	string
+	Env		*Environment
+	Exports	[]string
+}
+
+func (m *Module) Type() ObjectType { return MODULE_OBJ }
+func (m *Module) Equals(other Object) bool { return m == other }
+func (m *Module) Inspect() string { return "module " + m.Name }
+
+// Returns the value of an exported name
+func (m *Module) Export(name string) (Object, bool) {
+	for _, export := range m.Exports {
+		if export == name {
+			return m.Env.Get(name)
+		}
+	}
+	return nil, false
+}
 
 // Functions
 
@@ -63,14 +597,30 @@ type ReturnValue struct {
 
 func (r *ReturnValue) Inspect() string  { return r.Value.Inspect() }
 func (r *ReturnValue) Type() ObjectType { return RETURN_OBJ }
+func (r *ReturnValue) Equals(other Object) bool { return r == other }
+
+// An err result leaving its function through the ? operator
+// Unlike ReturnValue it unwinds through expressions, not only statements
+type EarlyReturn struct {
+	Value *Result
+}
+
+func (r *EarlyReturn) Inspect() string  { return r.Value.Inspect() }
+func (r *EarlyReturn) Type() ObjectType { return EARLY_RETURN_OBJ }
+func (r *EarlyReturn) Equals(other Object) bool { return r == other }
 
 type Function struct {
+	Name		string // empty for anonymous functions
 	Parameters []*ast.Identifier
+	Patterns	[]ast.Pattern // destructured parameters, nil for plain names
 	Body		*ast.BlockStatement
+	Slots		int
+	Generator	bool // calling it returns an iterator over what the body yields
 	Env 		*Environment
 }
 
 func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
+func (f *Function) Equals(other Object) bool { return f == other }
 func (f *Function) Inspect() string  {
 	var out bytes.Buffer
 
@@ -80,6 +630,12 @@ func (f *Function) Inspect() string  {
 	}
 
 	out.WriteString("fn")
+	if f.Generator {
+		out.WriteString("*")
+	}
+	if f.Name != "" {
+		out.WriteString(" " + f.Name)
+	}
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") {\n")
@@ -89,11 +645,60 @@ func (f *Function) Inspect() string  {
 	return out.String()
 }
 
+type BuiltinFunction func(args ...Object) Object
+
+type Builtin struct {
+	Name	string
+	Fn		BuiltinFunction
+}
+
+func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
+func (b *Builtin) Equals(other Object) bool { return b == other }
+func (b *Builtin) Inspect() string  { return "builtin function " + b.Name }
+
+// Results
+
+// Outcome of an operation that can fail, made by ok(value) or err(error)
+type Result struct {
+	Ok		bool
+	Value	Object
+}
+
+func (r *Result) Type() ObjectType { return RESULT_OBJ }
+func (r *Result) Equals(other Object) bool { return equals(r, other, comparing{}) }
+func (r *Result) equals(other Object, c comparing) bool {
+	o, ok := other.(*Result)
+	return ok && r.Ok == o.Ok && equals(r.Value, o.Value, c)
+}
+func (r *Result) Inspect() string { return inspect(r, inspecting{}) }
+func (r *Result) inspect(in inspecting) string {
+	if r.Ok {
+		return "ok(" + inspect(r.Value, in) + ")"
+	}
+	return "err(" + inspect(r.Value, in) + ")"
+}
+
 // Errors
 
+// An error being raised, it unwinds evaluation until caught
 type Error struct {
+	Kind	string
 	Message	string
+	Stack	[]string // calls it unwound through, innermost first
 }
 
-func (e *Error) Inspect() string { return "Error" + e.Message }
+func (e *Error) Inspect() string { return e.Kind + ": " + e.Message }
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
+func (e *Error) Equals(other Object) bool { return e == other }
+
+// A caught error, an ordinary value that can be passed around or thrown again
+type Exception struct {
+	Error	*Error
+}
+
+func (e *Exception) Inspect() string { return e.Error.Inspect() }
+func (e *Exception) Type() ObjectType { return EXCEPTION_OBJ }
+func (e *Exception) Equals(other Object) bool {
+	o, ok := other.(*Exception)
+	return ok && e.Error == o.Error
+}
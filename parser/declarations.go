@@ -0,0 +1,61 @@
+package parser
+
+import (
+	"mkc/ast"
+	tk "mkc/token"
+)
+
+// A name bound in a scope, as seen by the parser
+type declaration struct {
+	token    tk.Token
+	constant bool
+}
+
+// Reports names declared twice among one list of statements.
+// Constants may never be redeclared, lets only when strictLet is off.
+// Redeclarations across blocks are left to the evaluator.
+func (p *Parser) checkDeclarations(params []*ast.Identifier, statements []ast.Statement) {
+	declared := map[string]declaration{}
+
+	declare := func(name *ast.Identifier, constant bool) {
+		if previous, ok := declared[name.Value]; ok {
+			if previous.constant || constant || p.strictLet {
+				p.redeclarationError(name, previous)
+			}
+		}
+		declared[name.Value] = declaration{token: name.Token, constant: constant}
+	}
+
+	for _, param := range params {
+		declare(param, false)
+	}
+
+	// statements that failed to parse are typed nils
+	for _, statement := range statements {
+		switch s := ast.Unexported(statement).(type) {
+		case *ast.LetStatement:
+			if s == nil {
+				continue
+			}
+			for _, name := range s.Names() {
+				declare(name, s.IsConst())
+			}
+		case *ast.FunctionStatement:
+			if s != nil {
+				declare(s.Name, false)
+			}
+		case *ast.StructStatement:
+			if s != nil {
+				declare(s.Name, false)
+			}
+		case *ast.EnumStatement:
+			if s != nil {
+				declare(s.Name, false)
+			}
+		case *ast.ImportStatement:
+			if s != nil {
+				declare(s.Name, true)
+			}
+		}
+	}
+}
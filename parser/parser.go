@@ -1,7 +1,9 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"math/big"
 	"mkc/ast"
 	"mkc/lexer"
 	tk "mkc/token"
@@ -11,9 +13,11 @@ import (
 type (
 	tPrefixParseFn 	func () ast.Expression
 	tInfixParseFn 	func(ast.Expression) ast.Expression
+	tPostfixParseFn	func(ast.Expression) ast.Expression
 
 	prefixParserTable 	map[tk.TokenType]tPrefixParseFn
 	infixParserTable 	map[tk.TokenType]tInfixParseFn
+	postfixParserTable	map[tk.TokenType]tPostfixParseFn
 )
 
 type Parser struct {
@@ -24,8 +28,26 @@ type Parser struct {
 
 	errors []string
 
+	// reject let redeclaration in the same scope
+	strictLet bool
+
+	// a name followed by => is not a lambda, as in a match guard
+	noArrow bool
+
+	// parsing the body of a generator, where yield is allowed
+	inGenerator bool
+
+	// number of blocks around the current statement, 0 at the top level
+	blocks int
+
+	// number of brackets, parentheses and braces open at the current token,
+	// and that number for each conditional whose : is still to come
+	depth int
+	conditionals []int
+
 	prefixParseFns 	prefixParserTable
 	infixParseFns 	infixParserTable
+	postfixParseFns	postfixParserTable
 }
 
 ////////////////
@@ -40,19 +62,27 @@ func New(l *lexer.Lexer) *Parser {
 
 		prefixParseFns: make(prefixParserTable),
 		infixParseFns:	make(infixParserTable),
+		postfixParseFns: make(postfixParserTable),
 	}
 
 	// All prefix operators
 	p.registerPrefix(tk.IDENTIFIER,	p.parseIdentifier)
 	p.registerPrefix(tk.INT,		p.parseIntegerLiteral)
+	p.registerPrefix(tk.FLOAT,		p.parseFloatLiteral)
 	p.registerPrefix(tk.TRUE,		p.parseBooleanLiteral)
 	p.registerPrefix(tk.FALSE,		p.parseBooleanLiteral)
+	p.registerPrefix(tk.NULL,		p.parseNullLiteral)
 	p.registerPrefix(tk.BANG,		p.parsePrefixExpression)
 	p.registerPrefix(tk.MINUS,		p.parsePrefixExpression)
 	p.registerPrefix(tk.PLUS,		p.parsePrefixExpression)
 	p.registerPrefix(tk.LPAREN,		p.parseGroupedExpression)
 	p.registerPrefix(tk.IF,			p.parseIfExpression)
 	p.registerPrefix(tk.FUNCTION,	p.parseFunctionLiteral)
+	p.registerPrefix(tk.STRING,		p.parseStringLiteral)
+	p.registerPrefix(tk.LBRACKET,	p.parseArrayLiteral)
+	p.registerPrefix(tk.TRY,		p.parseTryExpression)
+	p.registerPrefix(tk.LBRACE,		p.parseHashLiteral)
+	p.registerPrefix(tk.MATCH,		p.parseMatchExpression)
 
 	// All infix operators
 	p.registerInfix(tk.EQ, 			p.parseInfixExpression)
@@ -67,8 +97,23 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(tk.LTEQ, 		p.parseInfixExpression)
 	p.registerInfix(tk.GT, 			p.parseInfixExpression)
 	p.registerInfix(tk.GTEQ, 		p.parseInfixExpression)
+	p.registerInfix(tk.RANGE, 		p.parseInfixExpression)
+	p.registerInfix(tk.RANGE_EXCLUSIVE, p.parseInfixExpression)
+	p.registerInfix(tk.ASSIGN, 		p.parseAssignExpression)
+	p.registerInfix(tk.DOT, 		p.parseMemberExpression)
+	p.registerInfix(tk.PIPE, 		p.parsePipeExpression)
+	p.registerInfix(tk.NULLISH, 	p.parseInfixExpression)
+	p.registerInfix(tk.OR, 			p.parseInfixExpression)
+	p.registerInfix(tk.AND, 		p.parseInfixExpression)
+	// A ? followed by an expression is CONDITION ? CONSEQUENCE : ALTERNATIVE
+	p.registerInfix(tk.QUESTION,	p.parseConditionalExpression)
 	// Call arguments are like IDENTIFIER ( ARGUMENTS
 	p.registerInfix(tk.LPAREN,		p.parseCallExpression)
+	// Indexing is like EXPRESSION [ INDEX
+	p.registerInfix(tk.LBRACKET,	p.parseIndexExpression)
+
+	// All postfix operators
+	p.registerPostfix(tk.QUESTION,	p.parsePostfixExpression)
 
 	// Read two tokens, so curToken and peekToken are both set
 	p.nextToken()
@@ -77,6 +122,11 @@ func New(l *lexer.Lexer) *Parser {
 	return p
 }
 
+// Makes let redeclaration in the same scope a parse error
+func (p *Parser) SetStrictLet(strict bool) {
+	p.strictLet = strict
+}
+
 // Registers prefix parse function for a token
 func (p *Parser) registerPrefix(tokenType tk.TokenType, fn tPrefixParseFn) {
 	p.prefixParseFns[tokenType] = fn
@@ -87,10 +137,51 @@ func (p *Parser) registerInfix(tokenType tk.TokenType, fn tInfixParseFn) {
 	p.infixParseFns[tokenType] = fn
 }
 
+// Registers postfix parse function for a token
+func (p *Parser) registerPostfix(tokenType tk.TokenType, fn tPostfixParseFn) {
+	p.postfixParseFns[tokenType] = fn
+}
+
 // Go to next token
 func (p *Parser) nextToken() {
 	p.currToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+
+	switch p.currToken.Type {
+	case tk.LPAREN, tk.LBRACKET, tk.LBRACE:
+		p.depth++
+	case tk.RPAREN, tk.RBRACKET, tk.RBRACE:
+		p.depth--
+	}
+}
+
+// A point in the token stream the parser can go back to
+type parserState struct {
+	lexer     lexer.Lexer
+	currToken tk.Token
+	peekToken tk.Token
+	depth     int
+	errors    int
+}
+
+// Remembers the current position, to try parsing ahead
+func (p *Parser) save() parserState {
+	return parserState{
+		lexer: *p.l,
+		currToken: p.currToken,
+		peekToken: p.peekToken,
+		depth: p.depth,
+		errors: len(p.errors),
+	}
+}
+
+// Goes back to a saved position, forgetting errors found since
+func (p *Parser) restore(s parserState) {
+	*p.l = s.lexer
+	p.currToken = s.currToken
+	p.peekToken = s.peekToken
+	p.depth = s.depth
+	p.errors = p.errors[:s.errors]
 }
 
 // If next token is passed type, then move ahead
@@ -168,6 +259,20 @@ func (p* Parser) noPrefixParseFnError(t tk.Token) {
 	p.errors = append(p.errors, msg)
 }
 
+// Adds error for a token the lexer could not read: a string literal
+// without its closing quote, an unknown escape in one, or a character
+// that starts no token
+func (p *Parser) illegalTokenError(t tk.Token) {
+	format := "%s: illegal character %s"
+	switch t.Literal[0] {
+	case '"':
+		format = "%s: unterminated string %s"
+	case '\\':
+		format = "%s: unknown escape %s in string"
+	}
+	p.errors = append(p.errors, fmt.Sprintf(format, t.Position(), t.Literal))
+}
+
 // Adds error for unregistered infix parse function
 func (p* Parser) noInfixParseFnError(t tk.Token) {
 	msg := fmt.Sprintf(
@@ -177,6 +282,101 @@ func (p* Parser) noInfixParseFnError(t tk.Token) {
 	p.errors = append(p.errors, msg)
 }
 
+// Adds error for a name declared twice in the same scope
+func (p *Parser) redeclarationError(name *ast.Identifier, previous declaration) {
+	kind := ""
+	if previous.constant {
+		kind = "constant "
+	}
+	msg := fmt.Sprintf(
+		"%s: cannot redeclare %s%s, declared at %s",
+		name.Token.Position(), kind, name.Value, previous.token.Position(),
+	)
+	p.errors = append(p.errors, msg)
+}
+
+// Adds error for assigning to something that is not a name
+func (p *Parser) assignTargetError(t ast.Expression) {
+	msg := fmt.Sprintf(
+		"cannot assign to %s",
+		t.String(),
+	)
+	p.errors = append(p.errors, msg)
+}
+
+// Adds error for a try without catch or finally
+func (p *Parser) missingClauseError(t tk.Token) {
+	msg := fmt.Sprintf(
+		"%s: expected catch or finally after try block",
+		t.Position(),
+	)
+	p.errors = append(p.errors, msg)
+}
+
+// Adds error for a token that cannot start a pattern
+func (p *Parser) patternError(t tk.Token) {
+	msg := fmt.Sprintf(
+		"%s: unexpected %s in pattern",
+		t.Position(), t.Literal,
+	)
+	p.errors = append(p.errors, msg)
+}
+
+// Adds error for a function parameter that is neither a name nor a pattern
+func (p *Parser) parameterError(t tk.Token) {
+	msg := fmt.Sprintf(
+		"%s: expected parameter, got %s",
+		t.Position(), t.Literal,
+	)
+	p.errors = append(p.errors, msg)
+}
+
+// Adds error for a struct naming a field twice
+// Kind is what the name is, such as a field, and owner where it is declared
+func (p *Parser) duplicateMemberError(member *ast.Identifier, kind string, owner string) {
+	msg := fmt.Sprintf(
+		"%s: duplicate %s %s in %s",
+		member.Token.Position(), kind, member.Value, owner,
+	)
+	p.errors = append(p.errors, msg)
+}
+
+// Adds error for a method without a parameter for its receiver
+func (p *Parser) receiverError(name *ast.Identifier) {
+	msg := fmt.Sprintf(
+		"%s: method %s must take a receiver parameter, such as self",
+		name.Token.Position(), name.Value,
+	)
+	p.errors = append(p.errors, msg)
+}
+
+// Adds error for a yield outside of a generator body
+func (p *Parser) yieldError(t tk.Token) {
+	msg := fmt.Sprintf(
+		"%s: yield outside of a generator",
+		t.Position(),
+	)
+	p.errors = append(p.errors, msg)
+}
+
+// Adds error for an export that is not a top level declaration
+func (p *Parser) exportError(t tk.Token, reason string) {
+	msg := fmt.Sprintf(
+		"%s: cannot export %s",
+		t.Position(), reason,
+	)
+	p.errors = append(p.errors, msg)
+}
+
+// Adds error for a name bound twice by one pattern
+func (p *Parser) duplicateBindingError(name *ast.Identifier) {
+	msg := fmt.Sprintf(
+		"%s: %s is bound more than once in pattern",
+		name.Token.Position(), name.Value,
+	)
+	p.errors = append(p.errors, msg)
+}
+
 // Adds error for no if condition
 func (p* Parser) wrongBracketError(t tk.Token, e string) {
 	msg := fmt.Sprintf(
@@ -201,6 +401,8 @@ func (p *Parser) ParseProgram() *ast.Program {
 		p.nextToken()
 	}
 
+	p.checkDeclarations(nil, program.Statements)
+
 	return program
 }
 
@@ -211,25 +413,66 @@ func (p *Parser) ParseProgram() *ast.Program {
 // Call respective parse procedures
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.currToken.Type {
-	case tk.LET:
+	case tk.LET, tk.CONST:
 		return p.parseLetStatement()
 	case tk.RETURN:
 		return p.parseReturnStatement()
+	case tk.THROW:
+		return p.parseThrowStatement()
+	case tk.FOR:
+		return p.parseForStatement()
+	case tk.YIELD:
+		return p.parseYieldStatement()
+	case tk.STRUCT:
+		return p.parseStructStatement()
+	case tk.IMPL:
+		return p.parseImplStatement()
+	case tk.ENUM:
+		return p.parseEnumStatement()
+	case tk.IMPORT:
+		return p.parseImportStatement()
+	case tk.EXPORT:
+		return p.parseExportStatement()
+	case tk.FUNCTION:
+		// fn IDENTIFIER and fn* IDENTIFIER are declarations, anything else a literal
+		if p.peekIsFunctionName() {
+			return p.parseFunctionStatement()
+		}
+		return p.parseExpressionStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
 // let IDENTIFIER = EXPRESSION;
+// const IDENTIFIER = EXPRESSION;
+// let PATTERN = EXPRESSION; destructures an array or hash
 func (p *Parser) parseLetStatement() *ast.LetStatement {
 	stmt := &ast.LetStatement{Token: p.currToken}
 
-	if !p.expectPeek(tk.IDENTIFIER) {
-		return nil
+	if p.peekTokenIs(tk.LBRACKET) || p.peekTokenIs(tk.LBRACE) {
+		p.nextToken()
+		stmt.Pattern = p.parsePattern()
+		if stmt.Pattern == nil {
+			return nil
+		}
+		p.checkPatternBindings(stmt.Pattern)
+	} else {
+		if !p.expectPeek(tk.IDENTIFIER) {
+			return nil
+		}
+		if p.peekTokenIs(tk.DOT) {
+			// let Enum.Variant(...) = value
+			stmt.Pattern = p.parseVariantPattern()
+			if stmt.Pattern == nil {
+				return nil
+			}
+			p.checkPatternBindings(stmt.Pattern)
+		} else {
+			stmt.Name = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+		}
 	}
 
-	stmt.Name = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
-
 	if !p.expectPeek(tk.ASSIGN) {
 		return nil
 	}
@@ -260,11 +503,13 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
-// EXPRESSION
-func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
-	stmt := &ast.ExpressionStatement{Token: p.currToken}
+// throw EXPRESSION;
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	stmt := &ast.ThrowStatement{Token: p.currToken}
 
-	stmt.Expression = p.parseExpression(LOWEST)
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
 
 	if p.peekTokenIs(tk.SEMICOLON) {
 		p.nextToken()
@@ -273,233 +518,1254 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	return stmt
 }
 
-// { STATEMENTS[] }
-func (p *Parser) parseBlockStatement() *ast.BlockStatement {
-	be := &ast.BlockStatement{Token: p.currToken, Statements: []ast.Statement{}}
-
-	p.nextToken()
+// import "PATH" as IDENTIFIER;
+func (p *Parser) parseImportStatement() *ast.ImportStatement {
+	stmt := &ast.ImportStatement{Token: p.currToken}
 
-	for !p.currTokenIs(tk.RBRACE) && !p.currTokenIs(tk.EOF) {
-		stmt := p.parseStatement()
-		if stmt == nil {
-			continue
-		}
-		be.Statements = append(be.Statements, stmt)
-		p.nextToken()
+	if !p.expectPeek(tk.STRING) {
+		return nil
 	}
+	stmt.Path = &ast.StringLiteral{Token: p.currToken, Value: p.currToken.Literal}
 
-	return be
-}
-
-////////////////////////////////
-// Expression Parse Functions //
-////////////////////////////////
-
-// Parses any expression
-func (p *Parser) parseExpression(precedence pRank) ast.Expression {
-	prefix, ok := p.prefixParseFns[p.currToken.Type]
-	if !ok {
-		p.noPrefixParseFnError(p.currToken)
+	if !p.expectPeek(tk.AS) || !p.expectPeek(tk.IDENTIFIER) {
 		return nil
 	}
-	leftExp := prefix()
-
-	for !p.peekTokenIs(tk.SEMICOLON) && precedence < p.peekPrecedence() {
-		infix, ok := p.infixParseFns[p.peekToken.Type]
-		if !ok {
-			p.noInfixParseFnError(p.peekToken)
-			return nil
-		}
+	stmt.Name = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
 
+	if p.peekTokenIs(tk.SEMICOLON) {
 		p.nextToken()
-
-		leftExp = infix(leftExp)
 	}
 
-	return leftExp
+	return stmt
 }
 
-// IDENTIFIER;
-func (p *Parser) parseIdentifier() ast.Expression {
-	return &ast.Identifier{
-		Token: p.currToken,
-		Value: p.currToken.Literal,
+// export DECLARATION, only at the top level
+func (p *Parser) parseExportStatement() *ast.ExportStatement {
+	stmt := &ast.ExportStatement{Token: p.currToken}
+	if p.blocks > 0 {
+		p.exportError(stmt.Token, "outside of the top level")
+		return nil
 	}
-}
 
-// INTEGER
-func (p *Parser) parseIntegerLiteral() ast.Expression {
-	il := &ast.IntegerLiteral{ Token: p.currToken }
+	p.nextToken()
 
-	value, err := strconv.ParseInt(p.currToken.Literal, 0, 64)
-	if err != nil {
-		p.typeError(p.currToken.Literal, "integer")
+	// a failed declaration is a typed nil, which must not be wrapped
+	switch p.currToken.Type {
+	case tk.LET, tk.CONST:
+		if d := p.parseLetStatement(); d != nil {
+			stmt.Declaration = d
+		}
+	case tk.STRUCT:
+		if d := p.parseStructStatement(); d != nil {
+			stmt.Declaration = d
+		}
+	case tk.ENUM:
+		if d := p.parseEnumStatement(); d != nil {
+			stmt.Declaration = d
+		}
+	case tk.FUNCTION:
+		if !p.peekIsFunctionName() {
+			p.exportError(p.currToken, "a function literal, only declarations")
+			return nil
+		}
+		if d := p.parseFunctionStatement(); d != nil {
+			stmt.Declaration = d
+		}
+	default:
+		p.exportError(p.currToken, p.currToken.Literal + ", only declarations")
 		return nil
 	}
 
-	il.Value = value
-
-	return il
-}
-
-// BOOLEAN
-func (p *Parser) parseBooleanLiteral() ast.Expression {
-	il := &ast.BooleanLiteral{Token: p.currToken, Value: p.currTokenIs(tk.TRUE)}
-
-	return il
+	if stmt.Declaration == nil {
+		return nil
+	}
+	return stmt
 }
 
-// OPERATOR EXPRESSION
-func (p *Parser) parsePrefixExpression() ast.Expression {
-	pe := &ast.PrefixExpression{Token: p.currToken, Operator: p.currToken.Literal}
-
-	p.nextToken()
-	pe.Right = p.parseExpression(PREFIX)
-
-	return pe
-}
+// struct IDENTIFIER { FIELD, ... }
+func (p *Parser) parseStructStatement() *ast.StructStatement {
+	stmt := &ast.StructStatement{Token: p.currToken}
 
-// EXPRESSION OPERATOR EXPRESSION
-func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
-	ie := &ast.InfixExpression{
-		Token: p.currToken,
-		Operator: p.currToken.Literal,
-		Left: left,
+	if !p.expectPeek(tk.IDENTIFIER) {
+		return nil
 	}
+	stmt.Name = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
 
-	precedence := p.currPrecedence()
-	p.nextToken()
-	ie.Right = p.parseExpression(precedence)
+	if !p.expectPeek(tk.LBRACE) {
+		return nil
+	}
 
-	return ie
-}
+	seen := map[string]bool{}
+	for !p.peekTokenIs(tk.RBRACE) {
+		if !p.expectPeek(tk.IDENTIFIER) {
+			return nil
+		}
+		field := &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+		if seen[field.Value] {
+			p.duplicateMemberError(field, "field", "struct "+stmt.Name.Value)
+		}
+		seen[field.Value] = true
+		stmt.Fields = append(stmt.Fields, field)
 
-// (EXPRESSION)
-func (p *Parser) parseGroupedExpression() ast.Expression {
+		if !p.peekTokenIs(tk.RBRACE) && !p.expectPeek(tk.COMMA) {
+			return nil
+		}
+	}
 	p.nextToken()
 
-	exp := p.parseExpression(LOWEST)
-
-	if !p.expectPeek(tk.RPAREN) {
-		return nil
+	if p.peekTokenIs(tk.SEMICOLON) {
+		p.nextToken()
 	}
 
-	return exp
+	return stmt
 }
 
-// if CONDITION { CONSEQUENT } else { ALTERNATIVE }
-func (p *Parser) parseIfExpression() ast.Expression {
-	ie := &ast.IfExpression{Token: p.currToken}
-
-	if !p.expectPeek(tk.LPAREN) {
-		p.wrongBracketError(p.peekToken, tk.LPAREN)
-		return nil
-	}
-
-	p.nextToken()
-	ie.Condition = p.parseExpression(LOWEST)
+// enum IDENTIFIER { VARIANT, VARIANT(FIELD, ...), ... }
+func (p *Parser) parseEnumStatement() *ast.EnumStatement {
+	stmt := &ast.EnumStatement{Token: p.currToken}
 
-	if !p.expectPeek(tk.RPAREN) {
-		p.wrongBracketError(p.peekToken, tk.RPAREN)
+	if !p.expectPeek(tk.IDENTIFIER) {
 		return nil
 	}
+	stmt.Name = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
 
 	if !p.expectPeek(tk.LBRACE) {
-		p.wrongBracketError(p.peekToken, tk.LBRACE)
 		return nil
 	}
 
-	ie.Consequence = p.parseBlockStatement()
+	for !p.peekTokenIs(tk.RBRACE) {
+		if !p.expectPeek(tk.IDENTIFIER) {
+			return nil
+		}
+		variant := &ast.EnumVariant{Name: &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}}
+		if stmt.Variant(variant.Name.Value) != nil {
+			p.duplicateMemberError(variant.Name, "variant", "enum "+stmt.Name.Value)
+		}
 
-	if p.peekTokenIs(tk.ELSE) {
-		p.nextToken()
+		if p.peekTokenIs(tk.LPAREN) {
+			p.nextToken()
+			variant.Fields = p.parseVariantFields(stmt.Name.Value + "." + variant.Name.Value)
+			if variant.Fields == nil {
+				return nil
+			}
+		}
+		stmt.Variants = append(stmt.Variants, variant)
 
-		if !p.expectPeek(tk.LBRACE) {
-			p.wrongBracketError(p.peekToken, tk.LBRACE)
+		if !p.peekTokenIs(tk.RBRACE) && !p.expectPeek(tk.COMMA) {
 			return nil
 		}
+	}
+	p.nextToken()
 
-		ie.Alternative = p.parseBlockStatement()
+	if p.peekTokenIs(tk.SEMICOLON) {
+		p.nextToken()
 	}
 
-	return ie
+	return stmt
 }
 
-// fn (PARAMETERS) { BODY }
+// (FIELD, ...) of an enum variant, at least one
+func (p *Parser) parseVariantFields(variant string) []*ast.Identifier {
+	fields := []*ast.Identifier{}
+	seen := map[string]bool{}
 
-func (p *Parser) parseFunctionLiteral() ast.Expression {
-	fl := &ast.FunctionLiteral{Token: p.currToken}
+	for {
+		if !p.expectPeek(tk.IDENTIFIER) {
+			return nil
+		}
+		field := &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+		if seen[field.Value] {
+			p.duplicateMemberError(field, "field", "variant "+variant)
+		}
+		seen[field.Value] = true
+		fields = append(fields, field)
 
-	if !p.expectPeek(tk.LPAREN) {
-		return nil
+		if !p.peekTokenIs(tk.COMMA) {
+			break
+		}
+		p.nextToken()
 	}
 
-	fl.Parameters = p.parseFunctionParameters()
-
-	if !p.expectPeek(tk.LBRACE) {
+	if !p.expectPeek(tk.RPAREN) {
 		return nil
 	}
-	fl.Body = p.parseBlockStatement()
+	return fields
+}
+
+// impl IDENTIFIER { fn METHOD(self, ...) { BODY } ... }
+func (p *Parser) parseImplStatement() *ast.ImplStatement {
+	stmt := &ast.ImplStatement{Token: p.currToken}
+
+	if !p.expectPeek(tk.IDENTIFIER) {
+		return nil
+	}
+	stmt.Name = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+
+	if !p.expectPeek(tk.LBRACE) {
+		return nil
+	}
+
+	for !p.peekTokenIs(tk.RBRACE) {
+		if !p.expectPeek(tk.FUNCTION) {
+			return nil
+		}
+		if !p.peekIsFunctionName() {
+			p.peekError(tk.IDENTIFIER)
+			return nil
+		}
+
+		method := p.parseFunctionStatement()
+		if method == nil {
+			return nil
+		}
+		if len(method.Function.Parameters) == 0 {
+			p.receiverError(method.Name)
+		}
+		stmt.Methods = append(stmt.Methods, method)
+	}
+	p.nextToken()
+
+	if p.peekTokenIs(tk.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// yield EXPRESSION;
+func (p *Parser) parseYieldStatement() *ast.YieldStatement {
+	stmt := &ast.YieldStatement{Token: p.currToken}
+	if !p.inGenerator {
+		p.yieldError(stmt.Token)
+	}
+
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(tk.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// for (PATTERN in EXPRESSION) { BODY }
+func (p *Parser) parseForStatement() *ast.ForStatement {
+	stmt := &ast.ForStatement{Token: p.currToken}
+
+	if !p.expectPeek(tk.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Pattern = p.parsePattern()
+	if stmt.Pattern == nil {
+		return nil
+	}
+	bindings := p.checkPatternBindings(stmt.Pattern)
+
+	if !p.expectPeek(tk.IN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(tk.RPAREN) || !p.expectPeek(tk.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseBlockStatement()
+	p.checkDeclarations(bindings, stmt.Body.Statements)
+
+	if p.peekTokenIs(tk.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// fn IDENTIFIER (PARAMETERS) { BODY }
+func (p *Parser) parseFunctionStatement() *ast.FunctionStatement {
+	stmt := &ast.FunctionStatement{Token: p.currToken}
+	generator := p.skipGeneratorStar()
+
+	p.nextToken()
+	stmt.Name = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+
+	fl := &ast.FunctionLiteral{Token: stmt.Token, Name: stmt.Name.Value, Generator: generator}
+
+	if !p.expectPeek(tk.LPAREN) {
+		return nil
+	}
+
+	fl.Parameters, fl.Patterns = p.parseFunctionParameters()
+
+	if !p.expectPeek(tk.LBRACE) {
+		return nil
+	}
+	fl.Body = p.parseFunctionBody(generator)
+	p.checkDeclarations(fl.Bindings(), fl.Body.Statements)
+
+	stmt.Function = fl
+
+	if p.peekTokenIs(tk.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// EXPRESSION
+func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
+	stmt := &ast.ExpressionStatement{Token: p.currToken}
+
+	stmt.Expression = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(tk.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// { STATEMENTS[] }
+func (p *Parser) parseBlockStatement() *ast.BlockStatement {
+	be := &ast.BlockStatement{Token: p.currToken, Statements: []ast.Statement{}}
+
+	p.blocks += 1
+	defer func() { p.blocks -= 1 }()
+
+	p.nextToken()
+
+	for !p.currTokenIs(tk.RBRACE) && !p.currTokenIs(tk.EOF) {
+		stmt := p.parseStatement()
+		if stmt == nil {
+			continue
+		}
+		be.Statements = append(be.Statements, stmt)
+		p.nextToken()
+	}
+
+	return be
+}
+
+////////////////////////////////
+// Expression Parse Functions //
+////////////////////////////////
+
+// Parses any expression
+func (p *Parser) parseExpression(precedence pRank) ast.Expression {
+	if p.currTokenIs(tk.ILLEGAL) {
+		p.illegalTokenError(p.currToken)
+		return nil
+	}
+	prefix, ok := p.prefixParseFns[p.currToken.Type]
+	if !ok {
+		p.noPrefixParseFnError(p.currToken)
+		return nil
+	}
+	leftExp := prefix()
+
+	for !p.peekTokenIs(tk.SEMICOLON) {
+		peekPrecedence := p.peekPrecedence()
+		postfix, isPostfix := p.postfixParseFns[p.peekToken.Type]
+
+		if p.peekTokenIs(tk.QUESTION) && p.peekIsConditional() {
+			peekPrecedence, isPostfix = TERNARY, false
+		}
+
+		if precedence >= peekPrecedence {
+			break
+		}
+
+		if isPostfix {
+			p.nextToken()
+			leftExp = postfix(leftExp)
+			continue
+		}
+
+		infix, ok := p.infixParseFns[p.peekToken.Type]
+		if !ok {
+			p.noInfixParseFnError(p.peekToken)
+			return nil
+		}
+
+		p.nextToken()
+
+		leftExp = infix(leftExp)
+	}
+
+	return leftExp
+}
+
+// Reports whether the ? ahead starts a conditional rather than being the
+// postfix ?. A conditional needs an expression after the ?, and a : at the
+// same nesting. When the token after the ? could also continue an expression
+// ending in postfix ?, such as - or [, the tokens up to the end of the
+// expression are counted: the ? is a conditional if there are enough : for
+// it, for the conditionals ahead that are sure to be ones, and for those
+// it is inside of.
+func (p *Parser) peekIsConditional() bool {
+	state := p.save()
+	defer p.restore(state)
+	p.nextToken()
+
+	if conditional, sure := p.questionIsConditional(); sure {
+		return conditional
+	}
+
+	depth := p.depth
+	colons := 1
+	for _, d := range p.conditionals {
+		if d == depth {
+			colons++
+		}
+	}
+
+	for {
+		p.nextToken()
+		switch {
+		case p.currTokenIs(tk.EOF) || p.depth < depth:
+			return false
+		case p.depth > depth:
+			continue
+		case p.currTokenIs(tk.SEMICOLON) || p.currTokenIs(tk.COMMA):
+			return false
+		case p.currTokenIs(tk.QUESTION):
+			if conditional, sure := p.questionIsConditional(); conditional && sure {
+				colons++
+			}
+		case p.currTokenIs(tk.COLON):
+			colons--
+			if colons == 0 {
+				return true
+			}
+		}
+	}
+}
+
+// Tells whether the current ? is a conditional by the token after it
+// alone, and whether that is enough to be sure: the ? is postfix if no
+// expression can start with the token, and a conditional if the token
+// can only start one
+func (p *Parser) questionIsConditional() (conditional bool, sure bool) {
+	if _, ok := p.prefixParseFns[p.peekToken.Type]; !ok {
+		return false, true
+	}
+	if _, ok := p.infixParseFns[p.peekToken.Type]; !ok {
+		return true, true
+	}
+	return false, false
+}
+
+// IDENTIFIER;
+// IDENTIFIER => BODY
+func (p *Parser) parseIdentifier() ast.Expression {
+	ident := &ast.Identifier{
+		Token: p.currToken,
+		Value: p.currToken.Literal,
+	}
+
+	if p.peekTokenIs(tk.ARROW) && !p.noArrow {
+		fl := &ast.FunctionLiteral{
+			Token: p.currToken,
+			Arrow: true,
+			Parameters: []*ast.Identifier{ident},
+			Patterns: []ast.Pattern{nil},
+		}
+		p.nextToken()
+		p.parseArrowBody(fl)
+		return fl
+	}
+
+	return ident
+}
+
+// INTEGER
+func (p *Parser) parseIntegerLiteral() ast.Expression {
+	il := &ast.IntegerLiteral{ Token: p.currToken }
+
+	value, err := strconv.ParseInt(p.currToken.Literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		if n, ok := new(big.Int).SetString(p.currToken.Literal, 0); ok {
+			il.Big = n
+			return il
+		}
+	}
+	if err != nil {
+		p.typeError(p.currToken.Literal, "integer")
+		return nil
+	}
+
+	il.Value = value
+
+	return il
+}
+
+// FLOAT
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	fl := &ast.FloatLiteral{Token: p.currToken}
+
+	value, err := strconv.ParseFloat(p.currToken.Literal, 64)
+	if err != nil {
+		p.typeError(p.currToken.Literal, "float")
+		return nil
+	}
+
+	fl.Value = value
+
+	return fl
+}
+
+// BOOLEAN
+func (p *Parser) parseBooleanLiteral() ast.Expression {
+	il := &ast.BooleanLiteral{Token: p.currToken, Value: p.currTokenIs(tk.TRUE)}
+
+	return il
+}
+
+// null
+func (p *Parser) parseNullLiteral() ast.Expression {
+	return &ast.NullLiteral{Token: p.currToken}
+}
+
+// "STRING"
+func (p *Parser) parseStringLiteral() ast.Expression {
+	return &ast.StringLiteral{Token: p.currToken, Value: p.currToken.Literal}
+}
+
+// [ ELEMENTS ]
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	al := &ast.ArrayLiteral{Token: p.currToken}
+	al.Elements = p.parseExpressionList(tk.RBRACKET)
+	return al
+}
+
+// OPERATOR EXPRESSION
+func (p *Parser) parsePrefixExpression() ast.Expression {
+	pe := &ast.PrefixExpression{Token: p.currToken, Operator: p.currToken.Literal}
+
+	p.nextToken()
+	pe.Right = p.parseExpression(PREFIX)
+
+	return pe
+}
+
+// EXPRESSION OPERATOR EXPRESSION
+func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
+	ie := &ast.InfixExpression{
+		Token: p.currToken,
+		Operator: p.currToken.Literal,
+		Left: left,
+	}
+
+	precedence := p.currPrecedence()
+	p.nextToken()
+	ie.Right = p.parseExpression(precedence)
+
+	return ie
+}
+
+// IDENTIFIER = EXPRESSION
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	ae := &ast.AssignExpression{Token: p.currToken}
+
+	if member, ok := target.(*ast.MemberExpression); ok {
+		ma := &ast.MemberAssignExpression{Token: ae.Token, Target: member}
+		p.nextToken()
+		ma.Value = p.parseExpression(ASSIGN - 1)
+		return ma
+	}
+
+	name, ok := target.(*ast.Identifier)
+	if !ok {
+		p.assignTargetError(target)
+		return nil
+	}
+	ae.Name = name
+
+	// right associative, a = b = c is a = (b = c)
+	p.nextToken()
+	ae.Value = p.parseExpression(ASSIGN - 1)
+
+	return ae
+}
+
+// EXPRESSION |> FUNCTION(ARGUMENTS)
+// Desugars to FUNCTION(EXPRESSION, ARGUMENTS), and EXPRESSION |> FUNCTION
+// to FUNCTION(EXPRESSION), so a chain of pipes reads left to right
+func (p *Parser) parsePipeExpression(left ast.Expression) ast.Expression {
+	token := p.currToken
+
+	p.nextToken()
+	right := p.parseExpression(PIPE)
+	if right == nil {
+		return nil
+	}
+
+	if prependArgument(right, left) {
+		return right
+	}
+
+	// x |> parse()? propagates the result of parse(x)
+	if pe, ok := right.(*ast.PostfixExpression); ok && prependArgument(pe.Left, left) {
+		return pe
+	}
+
+	return &ast.CallExpression{Token: token, Function: right, Arguments: []ast.Expression{left}}
+}
+
+// Makes arg the first argument of a function or method call
+// Returns false if exp is no call
+func prependArgument(exp ast.Expression, arg ast.Expression) bool {
+	switch call := exp.(type) {
+	case *ast.CallExpression:
+		call.Arguments = append([]ast.Expression{arg}, call.Arguments...)
+	case *ast.MethodCallExpression:
+		call.Arguments = append([]ast.Expression{arg}, call.Arguments...)
+	default:
+		return false
+	}
+	return true
+}
+
+// CONDITION ? CONSEQUENCE : ALTERNATIVE
+// Right associative, a ? b : c ? d : e is a ? b : (c ? d : e)
+func (p *Parser) parseConditionalExpression(condition ast.Expression) ast.Expression {
+	ce := &ast.ConditionalExpression{Token: p.currToken, Condition: condition}
+
+	p.conditionals = append(p.conditionals, p.depth)
+	p.nextToken()
+	ce.Consequence = p.parseExpression(LOWEST)
+	p.conditionals = p.conditionals[:len(p.conditionals)-1]
+
+	if !p.expectPeek(tk.COLON) {
+		return nil
+	}
+
+	p.nextToken()
+	ce.Alternative = p.parseExpression(TERNARY - 1)
+
+	return ce
+}
+
+// EXPRESSION OPERATOR
+func (p *Parser) parsePostfixExpression(left ast.Expression) ast.Expression {
+	return &ast.PostfixExpression{
+		Token: p.currToken,
+		Operator: p.currToken.Literal,
+		Left: left,
+	}
+}
+
+// (EXPRESSION)
+// (PARAMETERS) => BODY
+func (p *Parser) parseGroupedExpression() ast.Expression {
+	if fl := p.parseArrowFunction(); fl != nil {
+		return fl
+	}
+
+	noArrow := p.noArrow
+	p.noArrow = false
+	defer func() { p.noArrow = noArrow }()
+
+	p.nextToken()
+
+	exp := p.parseExpression(LOWEST)
+
+	if !p.expectPeek(tk.RPAREN) {
+		return nil
+	}
+
+	return exp
+}
+
+// Parses (PARAMETERS) => BODY if the parentheses hold a parameter list
+// followed by =>, otherwise leaves the parser where it was and returns nil
+func (p *Parser) parseArrowFunction() ast.Expression {
+	state := p.save()
+
+	fl := &ast.FunctionLiteral{Token: p.currToken, Arrow: true}
+	fl.Parameters, fl.Patterns = p.parseFunctionParameters()
+
+	if len(p.errors) != state.errors || !p.peekTokenIs(tk.ARROW) {
+		p.restore(state)
+		return nil
+	}
+
+	p.nextToken()
+	p.parseArrowBody(fl)
+	return fl
+}
+
+// => BODY, where the body is a block or a single returned expression
+func (p *Parser) parseArrowBody(fl *ast.FunctionLiteral) {
+	inGenerator := p.inGenerator
+	p.inGenerator = false
+	defer func() { p.inGenerator = inGenerator }()
+
+	p.nextToken()
+	fl.Body = p.parseBody()
+	p.checkDeclarations(fl.Bindings(), fl.Body.Statements)
+}
+
+// { STATEMENTS[] } or EXPRESSION, the latter as a block of one statement
+func (p *Parser) parseBody() *ast.BlockStatement {
+	if p.currTokenIs(tk.LBRACE) {
+		return p.parseBlockStatement()
+	}
+
+	stmt := &ast.ExpressionStatement{Token: p.currToken}
+	stmt.Expression = p.parseExpression(LOWEST)
+	return &ast.BlockStatement{Token: stmt.Token, Statements: []ast.Statement{stmt}}
+}
+
+// if CONDITION { CONSEQUENT } else { ALTERNATIVE }
+func (p *Parser) parseIfExpression() ast.Expression {
+	ie := &ast.IfExpression{Token: p.currToken}
+
+	if !p.expectPeek(tk.LPAREN) {
+		p.wrongBracketError(p.peekToken, tk.LPAREN)
+		return nil
+	}
+
+	p.nextToken()
+	ie.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(tk.RPAREN) {
+		p.wrongBracketError(p.peekToken, tk.RPAREN)
+		return nil
+	}
+
+	if !p.expectPeek(tk.LBRACE) {
+		p.wrongBracketError(p.peekToken, tk.LBRACE)
+		return nil
+	}
+
+	ie.Consequence = p.parseBlockStatement()
+	p.checkDeclarations(nil, ie.Consequence.Statements)
+
+	if p.peekTokenIs(tk.ELSE) {
+		p.nextToken()
+
+		if !p.expectPeek(tk.LBRACE) {
+			p.wrongBracketError(p.peekToken, tk.LBRACE)
+			return nil
+		}
+
+		ie.Alternative = p.parseBlockStatement()
+		p.checkDeclarations(nil, ie.Alternative.Statements)
+	}
+
+	return ie
+}
+
+// fn (PARAMETERS) { BODY }
+
+func (p *Parser) parseFunctionLiteral() ast.Expression {
+	fl := &ast.FunctionLiteral{Token: p.currToken}
+	fl.Generator = p.skipGeneratorStar()
+
+	if !p.expectPeek(tk.LPAREN) {
+		return nil
+	}
+
+	fl.Parameters, fl.Patterns = p.parseFunctionParameters()
+
+	if !p.expectPeek(tk.LBRACE) {
+		return nil
+	}
+	fl.Body = p.parseFunctionBody(fl.Generator)
+	p.checkDeclarations(fl.Bindings(), fl.Body.Statements)
 
 	return fl
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
+// Moves past the * of fn*, reporting whether there was one
+func (p *Parser) skipGeneratorStar() bool {
+	if !p.peekTokenIs(tk.ASTRICK) {
+		return false
+	}
+	p.nextToken()
+	return true
+}
+
+// Checks if fn is followed by a name, as in fn NAME or fn* NAME
+func (p *Parser) peekIsFunctionName() bool {
+	if !p.peekTokenIs(tk.ASTRICK) {
+		return p.peekTokenIs(tk.IDENTIFIER)
+	}
+
+	state := p.save()
+	defer p.restore(state)
+
+	p.nextToken()
+	return p.peekTokenIs(tk.IDENTIFIER)
+}
+
+// { STATEMENTS[] } of a function, yield is only allowed in a generator's
+func (p *Parser) parseFunctionBody(generator bool) *ast.BlockStatement {
+	inGenerator := p.inGenerator
+	p.inGenerator = generator
+	defer func() { p.inGenerator = inGenerator }()
+
+	return p.parseBlockStatement()
+}
+
+// Parameters are names or array and hash patterns
+// A pattern gets a parameter named after its source, which no name can clash with
+func (p *Parser) parseFunctionParameters() ([]*ast.Identifier, []ast.Pattern) {
 	var identifiers []*ast.Identifier
+	var patterns []ast.Pattern
 	if p.peekTokenIs(tk.RPAREN) {
 		p.nextToken()
-		return identifiers
+		return identifiers, patterns
+	}
+
+	parameter := func() {
+		variant := p.currTokenIs(tk.IDENTIFIER) && p.peekTokenIs(tk.DOT)
+		if p.currTokenIs(tk.LBRACKET) || p.currTokenIs(tk.LBRACE) || variant {
+			start := p.currToken
+			pattern := p.parsePattern()
+			if pattern == nil {
+				return
+			}
+			p.checkPatternBindings(pattern)
+			ident := &ast.Identifier{Token: start, Value: pattern.String()}
+			identifiers = append(identifiers, ident)
+			patterns = append(patterns, pattern)
+			return
+		}
+
+		if !p.currTokenIs(tk.IDENTIFIER) {
+			p.parameterError(p.currToken)
+			return
+		}
+
+		ident := &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+		identifiers = append(identifiers, ident)
+		patterns = append(patterns, nil)
 	}
 
 	p.nextToken()
-	ident := &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
-	identifiers = append(identifiers, ident)
+	parameter()
 
 	for p.peekTokenIs(tk.COMMA) {
 		p.nextToken() // Skip comma
-		p.nextToken() // Go to identifier
-		ident := &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
-		identifiers = append(identifiers, ident)
+		p.nextToken() // Go to parameter
+		parameter()
 	}
 
 	if !p.expectPeek(tk.RPAREN) {
 		p.wrongBracketError(p.peekToken, tk.RPAREN)
-		return nil
+		return nil, nil
 	}
 
-	return identifiers
+	return identifiers, patterns
 }
 
 // FUNCTIONLITERAL ( ARGUMENTS )
 
+// VALUE.METHOD(ARGUMENTS) is a method call
 func (p *Parser) parseCallExpression(fl ast.Expression) ast.Expression {
+	if member, ok := fl.(*ast.MemberExpression); ok {
+		mc := &ast.MethodCallExpression{Token: p.currToken, Object: member.Object, Method: member.Member}
+		mc.Arguments = p.parseCallArguments()
+		return mc
+	}
+
 	exp := &ast.CallExpression{Token: p.currToken, Function: fl}
 	exp.Arguments = p.parseCallArguments()
 	return exp
 }
 
 func (p *Parser) parseCallArguments() []ast.Expression {
-	var exps []ast.Expression
-	if p.peekTokenIs(tk.RPAREN) {
+	return p.parseExpressionList(tk.RPAREN)
+}
+
+// EXPRESSION, EXPRESSION, ... END
+func (p *Parser) parseExpressionList(end tk.TokenType) []ast.Expression {
+	noArrow := p.noArrow
+	p.noArrow = false
+	defer func() { p.noArrow = noArrow }()
+
+	exps := []ast.Expression{}
+	if p.peekTokenIs(end) {
 		p.nextToken()
 		return exps
 	}
 
 	p.nextToken()
-	arg := p.parseExpression(LOWEST)
-	exps = append(exps, arg)
+	exps = append(exps, p.parseListElement())
 
 	for p.peekTokenIs(tk.COMMA) {
 		p.nextToken() // Skip comma
 		p.nextToken() // Go to expression
-		arg := p.parseExpression(LOWEST)
-		exps = append(exps, arg)
+		exps = append(exps, p.parseListElement())
 	}
 
-	if !p.expectPeek(tk.RPAREN) {
-		p.wrongBracketError(p.peekToken, tk.RPAREN)
+	if !p.expectPeek(end) {
+		p.wrongBracketError(p.peekToken, string(end))
 		return nil
 	}
 
 	return exps
 }
+
+// { KEY: VALUE, ... }
+
+func (p *Parser) parseHashLiteral() ast.Expression {
+	hl := &ast.HashLiteral{Token: p.currToken, Pairs: make(map[ast.Expression]ast.Expression)}
+
+	for !p.peekTokenIs(tk.RBRACE) {
+		p.nextToken()
+		key := p.parseExpression(LOWEST)
+
+		if !p.expectPeek(tk.COLON) {
+			return nil
+		}
+
+		p.nextToken()
+		hl.Keys = append(hl.Keys, key)
+		hl.Pairs[key] = p.parseExpression(LOWEST)
+
+		if !p.peekTokenIs(tk.RBRACE) && !p.expectPeek(tk.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(tk.RBRACE) {
+		return nil
+	}
+
+	return hl
+}
+
+// EXPRESSION, or ...EXPRESSION to spread an iterable into the list
+func (p *Parser) parseListElement() ast.Expression {
+	if !p.currTokenIs(tk.ELLIPSIS) {
+		return p.parseExpression(LOWEST)
+	}
+
+	se := &ast.SpreadExpression{Token: p.currToken}
+	p.nextToken()
+	se.Value = p.parseExpression(LOWEST)
+	return se
+}
+
+// EXPRESSION [ INDEX ]
+
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	exp := &ast.IndexExpression{Token: p.currToken, Left: left}
+
+	p.nextToken()
+	exp.Index = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(tk.RBRACKET) {
+		return nil
+	}
+
+	return exp
+}
+
+// EXPRESSION.IDENTIFIER
+
+func (p *Parser) parseMemberExpression(left ast.Expression) ast.Expression {
+	exp := &ast.MemberExpression{Token: p.currToken, Object: left}
+
+	if !p.expectPeek(tk.IDENTIFIER) {
+		return nil
+	}
+	exp.Member = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+
+	return exp
+}
+
+// try { BLOCK } catch (IDENTIFIER) { CATCH } finally { FINALLY }
+// Either the catch or the finally clause may be left out
+
+func (p *Parser) parseTryExpression() ast.Expression {
+	te := &ast.TryExpression{Token: p.currToken}
+
+	if !p.expectPeek(tk.LBRACE) {
+		return nil
+	}
+	te.Block = p.parseBlockStatement()
+	p.checkDeclarations(nil, te.Block.Statements)
+
+	if p.peekTokenIs(tk.CATCH) {
+		p.nextToken()
+
+		if !p.expectPeek(tk.LPAREN) || !p.expectPeek(tk.IDENTIFIER) {
+			return nil
+		}
+		te.Param = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+
+		if !p.expectPeek(tk.RPAREN) || !p.expectPeek(tk.LBRACE) {
+			return nil
+		}
+		te.Catch = p.parseBlockStatement()
+		p.checkDeclarations([]*ast.Identifier{te.Param}, te.Catch.Statements)
+	}
+
+	if p.peekTokenIs(tk.FINALLY) {
+		p.nextToken()
+
+		if !p.expectPeek(tk.LBRACE) {
+			return nil
+		}
+		te.Finally = p.parseBlockStatement()
+		p.checkDeclarations(nil, te.Finally.Statements)
+	}
+
+	if te.Catch == nil && te.Finally == nil {
+		p.missingClauseError(te.Token)
+		return nil
+	}
+
+	return te
+}
+
+
+// match (SUBJECT) { PATTERN if GUARD => BODY, ... }
+// A body is either a block or a single expression
+
+func (p *Parser) parseMatchExpression() ast.Expression {
+	me := &ast.MatchExpression{Token: p.currToken}
+
+	if !p.expectPeek(tk.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	me.Subject = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(tk.RPAREN) || !p.expectPeek(tk.LBRACE) {
+		return nil
+	}
+
+	for !p.peekTokenIs(tk.RBRACE) {
+		p.nextToken()
+		arm := p.parseMatchArm()
+		if arm == nil {
+			return nil
+		}
+		me.Arms = append(me.Arms, arm)
+
+		if !p.peekTokenIs(tk.RBRACE) && !p.expectPeek(tk.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(tk.RBRACE) {
+		return nil
+	}
+
+	return me
+}
+
+// PATTERN if GUARD => BODY
+func (p *Parser) parseMatchArm() *ast.MatchArm {
+	arm := &ast.MatchArm{Token: p.currToken}
+
+	arm.Pattern = p.parsePattern()
+	if arm.Pattern == nil {
+		return nil
+	}
+	bindings := p.checkPatternBindings(arm.Pattern)
+
+	if p.peekTokenIs(tk.IF) {
+		p.nextToken()
+		p.nextToken()
+
+		// the => after the guard ends it, rather than starting a lambda
+		p.noArrow = true
+		arm.Guard = p.parseExpression(LOWEST)
+		p.noArrow = false
+	}
+
+	if !p.expectPeek(tk.ARROW) {
+		return nil
+	}
+
+	p.nextToken()
+	arm.Body = p.parseBody()
+	p.checkDeclarations(bindings, arm.Body.Statements)
+
+	return arm
+}
+
+// Reports names bound twice by a pattern, and returns its names
+func (p *Parser) checkPatternBindings(pattern ast.Pattern) []*ast.Identifier {
+	bindings := ast.PatternBindings(pattern)
+
+	seen := map[string]bool{}
+	for _, name := range bindings {
+		if seen[name.Value] {
+			p.duplicateBindingError(name)
+		}
+		seen[name.Value] = true
+	}
+
+	return bindings
+}
+
+////////////////////
+// Parse Patterns //
+////////////////////
+
+// Parses the pattern starting at the current token
+func (p *Parser) parsePattern() ast.Pattern {
+	switch p.currToken.Type {
+	case tk.IDENTIFIER:
+		if p.currToken.Literal == "_" {
+			return &ast.WildcardPattern{Token: p.currToken}
+		}
+		if p.peekTokenIs(tk.DOT) {
+			return p.parseVariantPattern()
+		}
+		name := &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+		return &ast.BindingPattern{Token: p.currToken, Name: name}
+
+	case tk.INT, tk.FLOAT, tk.STRING, tk.TRUE, tk.FALSE, tk.NULL:
+		return &ast.LiteralPattern{Token: p.currToken, Value: p.parseExpression(PREFIX)}
+
+	case tk.MINUS:
+		if !p.peekTokenIs(tk.INT) && !p.peekTokenIs(tk.FLOAT) {
+			break
+		}
+		return &ast.LiteralPattern{Token: p.currToken, Value: p.parseExpression(PREFIX)}
+
+	case tk.LBRACKET:
+		return p.parseArrayPattern()
+
+	case tk.LBRACE:
+		return p.parseHashPattern()
+	}
+
+	p.patternError(p.currToken)
+	return nil
+}
+
+// ENUM.VARIANT or ENUM.VARIANT(PATTERN, ...)
+func (p *Parser) parseVariantPattern() ast.Pattern {
+	vp := &ast.VariantPattern{Token: p.currToken}
+	vp.Enum = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+
+	p.nextToken()
+	if !p.expectPeek(tk.IDENTIFIER) {
+		return nil
+	}
+	vp.Variant = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+
+	if !p.peekTokenIs(tk.LPAREN) {
+		return vp
+	}
+	p.nextToken()
+
+	vp.Fields = []ast.Pattern{}
+	for !p.peekTokenIs(tk.RPAREN) {
+		p.nextToken()
+		field := p.parsePattern()
+		if field == nil {
+			return nil
+		}
+		vp.Fields = append(vp.Fields, field)
+
+		if !p.peekTokenIs(tk.RPAREN) && !p.expectPeek(tk.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
+
+	return vp
+}
+
+// [ PATTERN, ..., ...REST ]
+func (p *Parser) parseArrayPattern() ast.Pattern {
+	ap := &ast.ArrayPattern{Token: p.currToken}
+
+	for !p.peekTokenIs(tk.RBRACKET) {
+		p.nextToken()
+
+		if p.currTokenIs(tk.ELLIPSIS) {
+			p.nextToken()
+			ap.Rest = p.parsePattern()
+			if ap.Rest == nil {
+				return nil
+			}
+			// the rest always comes last
+			break
+		}
+
+		element := p.parsePattern()
+		if element == nil {
+			return nil
+		}
+		ap.Elements = append(ap.Elements, element)
+
+		if !p.peekTokenIs(tk.RBRACKET) && !p.expectPeek(tk.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(tk.RBRACKET) {
+		return nil
+	}
+
+	return ap
+}
+
+// { KEY: PATTERN, NAME, ... }
+func (p *Parser) parseHashPattern() ast.Pattern {
+	hp := &ast.HashPattern{Token: p.currToken}
+
+	for !p.peekTokenIs(tk.RBRACE) {
+		p.nextToken()
+
+		var key ast.Expression
+		var value ast.Pattern
+
+		switch p.currToken.Type {
+		case tk.INT, tk.FLOAT, tk.STRING, tk.TRUE, tk.FALSE:
+			key = p.parseExpression(PREFIX)
+
+		case tk.IDENTIFIER:
+			// a bare name is a string key, and binds that name on its own
+			key = &ast.StringLiteral{Token: p.currToken, Value: p.currToken.Literal}
+			if !p.peekTokenIs(tk.COLON) {
+				value = p.parsePattern()
+			}
+
+		default:
+			p.patternError(p.currToken)
+			return nil
+		}
+
+		if value == nil {
+			if !p.expectPeek(tk.COLON) {
+				return nil
+			}
+
+			p.nextToken()
+			value = p.parsePattern()
+			if value == nil {
+				return nil
+			}
+		}
+		hp.Keys = append(hp.Keys, key)
+		hp.Values = append(hp.Values, value)
+
+		if !p.peekTokenIs(tk.RBRACE) && !p.expectPeek(tk.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(tk.RBRACE) {
+		return nil
+	}
+
+	return hp
+}
@@ -3,7 +3,9 @@ package parser
 import (
 	"fmt"
 	"mkc/token"
+	"strings"
 	"testing"
+	"time"
 
 	"mkc/ast"
 	"mkc/lexer"
@@ -247,6 +249,63 @@ func TestLetStatement(t *testing.T) {
 	}
 }
 
+// const statement
+
+func TestConstStatement(t *testing.T) {
+	program := getAST(t, "const x = 5;")
+
+	stmt, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("s not *ast.LetStatement. got=%T", program.Statements[0])
+	}
+
+	if !stmt.IsConst() {
+		t.Fatalf("stmt is not const")
+	}
+
+	if stmt.String() != "const x = 5;" {
+		t.Fatalf("stmt.String() wrong. got=%q", stmt.String())
+	}
+
+	if !assertLiteralExpression(t, stmt.Value, 5) {
+		return
+	}
+}
+
+func TestRedeclarationErrors(t *testing.T) {
+	tests := []struct {
+		input     string
+		strictLet bool
+		expected  []string
+	}{
+		{"let x = 1; let x = 2;", false, []string{}},
+		{"let x = 1; let x = 2;", true, []string{"1:16: cannot redeclare x, declared at 1:5"}},
+		{"const x = 1; let x = 2;", false, []string{"1:18: cannot redeclare constant x, declared at 1:7"}},
+		{"let x = 1; const x = 2;", false, []string{"1:18: cannot redeclare x, declared at 1:5"}},
+		{"fn(x) { const x = 1; }", false, []string{"1:15: cannot redeclare x, declared at 1:4"}},
+		{"const x = 1; if (true) { const x = 2; }", false, []string{}},
+		{"if (true) { const x = 1; } else { const x = 2; }", false, []string{}},
+		{"fn f() {}; const f = 1;", false, []string{"1:18: cannot redeclare f, declared at 1:4"}},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.SetStrictLet(tt.strictLet)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expected) {
+			t.Fatalf("%q - wrong number of errors. expected=%v, got=%v", tt.input, tt.expected, errors)
+		}
+
+		for i, msg := range tt.expected {
+			if errors[i] != msg {
+				t.Fatalf("%q - wrong error. expected=%q, got=%q", tt.input, msg, errors[i])
+			}
+		}
+	}
+}
+
 // return statement
 
 func TestReturnStatement(t *testing.T) {
@@ -340,6 +399,49 @@ func TestIntegerLiteralStatement(t *testing.T) {
 	}
 }
 
+func TestBigIntegerLiteral(t *testing.T) {
+	program := getAST(t, "99999999999999999999;")
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	il, ok := stmt.Expression.(*ast.IntegerLiteral)
+	if !ok {
+		t.Fatalf("expression is not ast.IntegerLiteral, got=%T", stmt.Expression)
+	}
+	if il.Big == nil || il.Big.String() != "99999999999999999999" {
+		t.Errorf("il.Big wrong, got=%v", il.Big)
+	}
+}
+
+// float literal statement
+
+func TestFloatLiteralStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"1.5", 1.5},
+		{"0.125;", 0.125},
+		{"2e3", 2000},
+		{"2.5E-1", 0.25},
+	}
+
+	for _, tt := range tests {
+		program := getAST(t, tt.input)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		fl, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("expression is not ast.FloatLiteral, got=%T", stmt.Expression)
+		}
+		if fl.Value != tt.expected {
+			t.Errorf("fl.Value not %g, got=%g", tt.expected, fl.Value)
+		}
+		if fl.String() != strings.TrimSuffix(tt.input, ";") {
+			t.Errorf("fl.String() wrong, got=%q", fl.String())
+		}
+	}
+}
+
 // boolean literal statement
 
 func TestBooleanLiteralStatement(t *testing.T) {
@@ -509,6 +611,77 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		{"a + add(b * c) + d", "((a + add((b * c))) + d)"},
 		{"add(a, b, 1, 2 * 3, 4 + 5, add(6, 7 * 8))","add(a, b, 1, (2 * 3), (4 + 5), add(6, (7 * 8)))"},
 		{"add(a + b + c * d / f + g)", "add((((a + b) + ((c * d) / f)) + g))"},
+		{"a = b = 1 + 2", "(a = (b = (1 + 2)))"},
+		{"a = b == c", "(a = (b == c))"},
+		{"a * [1, 2, 3, 4][b * c] * d", "((a * ([1, 2, 3, 4][(b * c)])) * d)"},
+		{"add(a * b[2], b[1], 2 * [1, 2][1])", "add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))"},
+		{`"a" + "b"`, `("a" + "b")`},
+		{"a?", "(a?)"},
+		{"f(x)? + g(y)?", "((f(x)?) + (g(y)?))"},
+		{"-a?", "(-(a?))"},
+		{"a[0]?", "((a[0])?)"},
+		{"let x = f()?;", "let x = (f()?);"},
+		{"data |> filter(isValid) |> map(normalize) |> len", "len(map(filter(data, isValid), normalize))"},
+		{"a + b |> f", "f((a + b))"},
+		{"a == b |> f", "f((a == b))"},
+		{"x = a |> f(1)", "(x = f(a, 1))"},
+		{"a |> fn(x) { x }", "fn(x)x(a)"},
+		{"a |> parse()?", "(parse(a)?)"},
+		{"a |> m[0]", "(m[0])(a)"},
+		{"a ? b : c", "(a ? b : c)"},
+		{"a == b ? 1 : 2", "((a == b) ? 1 : 2)"},
+		{"a ? b : c ? d : e", "(a ? b : (c ? d : e))"},
+		{"a ? b ? c : d : e", "(a ? (b ? c : d) : e)"},
+		{"c ? -1 : 1", "(c ? (-1) : 1)"},
+		{"c ? [1] : (2)", "(c ? [1] : 2)"},
+		{"x = c ? a : b", "(x = (c ? a : b))"},
+		{"a ?? b ?? c", "((a ?? b) ?? c)"},
+		{"a ?? b == c", "(a ?? (b == c))"},
+		{"a ?? b ? c : d", "((a ?? b) ? c : d)"},
+		{"f()? ? 1 : 2", "((f()?) ? 1 : 2)"},
+		{"f()? - 1", "((f()?) - 1)"},
+		{"[f()?, 1]", "[(f()?), 1]"},
+		{"f()?[0]", "((f()?)[0])"},
+		{"c ? f()? : g()?", "(c ? (f()?) : (g()?))"},
+		{"c ?-1 : 1", "(c ? (-1) : 1)"},
+		{"f()? -1", "((f()?) - 1)"},
+		{"c ? (1) : 2", "(c ? 1 : 2)"},
+		{"x ? -x ? -x : 0 : 0", "(x ? ((-x) ? (-x) : 0) : 0)"},
+		{"c? -1 : 1", "(c ? (-1) : 1)"},
+		{"c?-1:1", "(c ? (-1) : 1)"},
+		{"c?[0]:[1]", "(c ? [0] : [1])"},
+		{"c?(1):2", "(c ? 1 : 2)"},
+		{"f()?-1", "((f()?) - 1)"},
+		{"c ? f()? - 1 : 2", "(c ? ((f()?) - 1) : 2)"},
+		{"f()? - c ? 1 : 2", "(((f()?) - c) ? 1 : 2)"},
+		{"a? b? -1 : 2 : 3", "(a ? (b ? (-1) : 2) : 3)"},
+		{"g(c?-1:1, f()?-1)", "g((c ? (-1) : 1), ((f()?) - 1))"},
+		{`{"k": c ? 1 : 2}`, `{"k": (c ? 1 : 2)}`},
+		{"x = null", "(x = null)"},
+		{"a || b && c", "(a || (b && c))"},
+		{"a && b == c || d", "((a && (b == c)) || d)"},
+		{"a ?? b || c", "(a ?? (b || c))"},
+		{"a || b ? c : d", "((a || b) ? c : d)"},
+		{"!a && b", "((!a) && b)"},
+		{"a..b + 1", "(a .. (b + 1))"},
+		{"0..<n * 2", "(0 ..< (n * 2))"},
+		{"a..b == c", "((a .. b) == c)"},
+		{"xs |> f(...ys, 1)", "f(xs, ...ys, 1)"},
+		{"[0, ...1..3]", "[0, ...(1 .. 3)]"},
+		{"-p.x", "(-p.x)"},
+		{"a.b.c", "a.b.c"},
+		{"p.x * 2 + q.y", "((p.x * 2) + q.y)"},
+		{"f(p).x", "f(p).x"},
+		{"xs[0].y", "(xs[0]).y"},
+		{"p.xs[1]", "(p.xs[1])"},
+		{"p.x = q.y = 3", "(p.x = (q.y = 3))"},
+		{"a.b.c = 1 + 2", "(a.b.c = (1 + 2))"},
+		{"xs.push(1 + 2)", "xs.push((1 + 2))"},
+		{"a.b.c(1).d()", "a.b.c(1).d()"},
+		{"-s.len() * 2", "((-s.len()) * 2)"},
+		{"s.trim()[0]", "(s.trim()[0])"},
+		{"xs |> ys.concat(1)", "ys.concat(xs, 1)"},
+		{"x |> p.parse()?", "(p.parse(x)?)"},
 	}
 
 	for _, tt := range tests {
@@ -521,6 +694,29 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 	}
 }
 
+// Deciding between postfix ? and a conditional must not parse ahead, which
+// took exponential time in the nesting of conditionals
+func TestNestedConditionals(t *testing.T) {
+	depth := 200
+	input := "x" + strings.Repeat(" ? -x", depth) + strings.Repeat(" : 0", depth)
+
+	p := New(lexer.New(input))
+	done := make(chan *ast.Program)
+	go func() { done <- p.ParseProgram() }()
+
+	select {
+	case program := <-done:
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors: %v", p.Errors())
+		}
+		if got := strings.Count(program.String(), "?"); got != depth {
+			t.Fatalf("expected %d conditionals, got=%d", depth, got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("parsing %d nested conditionals took over 5s", depth)
+	}
+}
+
 // if statements
 
 func TestIfExpression(t *testing.T) {
@@ -715,3 +911,739 @@ func TestCallExpression(t *testing.T) {
 	assertInfixExpression(t, ce.Arguments[1], 2, "*", 3)
 	assertInfixExpression(t, ce.Arguments[2], 4, "+", 5)
 }
+
+// function declarations
+
+func TestFunctionStatement(t *testing.T) {
+	input := `fn add(x, y) { x + y }`
+
+	program := getAST(t, input)
+	if len(program.Statements) != 1 {
+		t.Fatalf(
+			"program.Body does not contain %d statements. got=%d\n",
+			1, len(program.Statements),
+		)
+	}
+
+	stmt, ok := program.Statements[0].(*ast.FunctionStatement)
+	if !ok {
+		t.Fatalf(
+			"program.Statements[0] is not ast.FunctionStatement. got=%T",
+			program.Statements[0],
+		)
+	}
+
+	if stmt.Name.Value != "add" {
+		t.Fatalf("stmt.Name.Value not 'add'. got=%q", stmt.Name.Value)
+	}
+
+	if stmt.Function.Name != "add" {
+		t.Fatalf("stmt.Function.Name not 'add'. got=%q", stmt.Function.Name)
+	}
+
+	if len(stmt.Function.Parameters) != 2 {
+		t.Fatalf(
+			"function parameters wrong. want 2, got=%d\n",
+			len(stmt.Function.Parameters),
+		)
+	}
+	assertLiteralExpression(t, stmt.Function.Parameters[0], "x")
+	assertLiteralExpression(t, stmt.Function.Parameters[1], "y")
+
+	expected := "fn add(x,y)(x + y)"
+	if stmt.String() != expected {
+		t.Fatalf("expected=%q, got=%q", expected, stmt.String())
+	}
+}
+
+// strings, arrays and indexing
+
+func TestStringLiteralExpression(t *testing.T) {
+	program := getAST(t, `"hello world";`)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.StringLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.StringLiteral. got=%T", stmt.Expression)
+	}
+
+	if literal.Value != "hello world" {
+		t.Fatalf("literal.Value not %q. got=%q", "hello world", literal.Value)
+	}
+}
+
+func TestArrayLiteralExpression(t *testing.T) {
+	program := getAST(t, "[1, 2 * 2, 3 + 3]")
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.ArrayLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(array.Elements) != 3 {
+		t.Fatalf("len(array.Elements) not 3. got=%d", len(array.Elements))
+	}
+
+	assertIntegerLiteral(t, array.Elements[0], 1)
+	assertInfixExpression(t, array.Elements[1], 2, "*", 2)
+	assertInfixExpression(t, array.Elements[2], 3, "+", 3)
+}
+
+func TestIndexExpression(t *testing.T) {
+	program := getAST(t, "myArray[1 + 1]")
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	indexExp, ok := stmt.Expression.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.IndexExpression. got=%T", stmt.Expression)
+	}
+
+	if !assertIdentifier(t, indexExp.Left, "myArray") {
+		return
+	}
+
+	assertInfixExpression(t, indexExp.Index, 1, "+", 1)
+}
+
+// try catch and throw
+
+func TestTryExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"try { x } catch (e) { y }", "try x catch(e) y"},
+		{"try { x } finally { z }", "try x finally z"},
+		{"try { x } catch (e) { y } finally { z }", "try x catch(e) y finally z"},
+	}
+
+	for _, tt := range tests {
+		program := getAST(t, tt.input)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if _, ok := stmt.Expression.(*ast.TryExpression); !ok {
+			t.Fatalf("exp not *ast.TryExpression. got=%T", stmt.Expression)
+		}
+
+		if program.String() != tt.expected {
+			t.Fatalf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	p := New(lexer.New("try { x }"))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Fatalf("expected error for try without catch or finally")
+	}
+}
+
+func TestThrowStatement(t *testing.T) {
+	program := getAST(t, `throw "bad";`)
+
+	stmt, ok := program.Statements[0].(*ast.ThrowStatement)
+	if !ok {
+		t.Fatalf("stmt not *ast.ThrowStatement. got=%T", program.Statements[0])
+	}
+
+	if stmt.String() != `throw "bad";` {
+		t.Fatalf("stmt.String() wrong. got=%q", stmt.String())
+	}
+}
+
+// postfix expressions
+
+func TestParsingPostfixExpression(t *testing.T) {
+	program := getAST(t, "parse(x)?;")
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.PostfixExpression)
+	if !ok {
+		t.Fatalf("stmt is not ast.PostfixExpression. got=%T", stmt.Expression)
+	}
+
+	if exp.Operator != "?" {
+		t.Fatalf("exp.Operator is not '?'. got=%s", exp.Operator)
+	}
+
+	if _, ok := exp.Left.(*ast.CallExpression); !ok {
+		t.Fatalf("exp.Left is not ast.CallExpression. got=%T", exp.Left)
+	}
+}
+
+// hashes and match
+
+func TestHashLiteral(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"{}", "{}"},
+		{`{"one": 1, "two": 1 + 1}`, `{"one": 1, "two": (1 + 1)}`},
+		{`{1: true, x: [y]}`, "{1: true, x: [y]}"},
+	}
+
+	for _, tt := range tests {
+		program := getAST(t, tt.input)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if _, ok := stmt.Expression.(*ast.HashLiteral); !ok {
+			t.Fatalf("exp not *ast.HashLiteral. got=%T", stmt.Expression)
+		}
+
+		if program.String() != tt.expected {
+			t.Fatalf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"match (x) { 0 => a, _ => b }", "match (x) {0 => a, _ => b}"},
+		{"match (x) { -1 => a, n if n > 10 => n, }", "match (x) {(-1) => a, n if (n > 10) => n}"},
+		{"match (x) { [first, ...rest] => { first } }", "match (x) {[first, ...rest] => first}"},
+		{`match (x) { {"type": t, "size": [_, 2]} => t }`, `match (x) {{"type": t, "size": [_, 2]} => t}`},
+		{`match (x) { true => "yes", "s" => 1 }`, `match (x) {true => "yes", "s" => 1}`},
+		{"match (s) { Shape.Circle(r) => r, Shape.Rect(_, [h]) => h, Shape.Empty => 0 }",
+			"match (s) {Shape.Circle(r) => r, Shape.Rect(_, [h]) => h, Shape.Empty => 0}"},
+	}
+
+	for _, tt := range tests {
+		program := getAST(t, tt.input)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if _, ok := stmt.Expression.(*ast.MatchExpression); !ok {
+			t.Fatalf("exp not *ast.MatchExpression. got=%T", stmt.Expression)
+		}
+
+		if program.String() != tt.expected {
+			t.Fatalf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestMatchPatternErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"match (x) { [a, a] => a }", "1:17: a is bound more than once in pattern"},
+		{"match (x) { a + 1 => a }", "expected next token to be =>, got + instead"},
+		{"match (x) { fn => 1 }", "1:13: unexpected fn in pattern"},
+		{"match (x) { {fn: 1} => 1 }", "1:14: unexpected fn in pattern"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("%q - expected error %q, got=%v", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+// destructuring
+
+func TestDestructuringLetStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		names    []string
+	}{
+		{"let [a, b, ...rest] = arr;", "let [a, b, ...rest] = arr;", []string{"a", "b", "rest"}},
+		{"let {name, age: years} = person;", `let {"name": name, "age": years} = person;`, []string{"name", "years"}},
+		{"const [_, {x: [y]}] = p;", `const [_, {"x": [y]}] = p;`, []string{"y"}},
+	}
+
+	for _, tt := range tests {
+		program := getAST(t, tt.input)
+
+		stmt, ok := program.Statements[0].(*ast.LetStatement)
+		if !ok {
+			t.Fatalf("stmt not *ast.LetStatement. got=%T", program.Statements[0])
+		}
+
+		if stmt.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, stmt.String())
+		}
+
+		names := stmt.Names()
+		if len(names) != len(tt.names) {
+			t.Fatalf("wrong names. expected=%v, got=%v", tt.names, names)
+		}
+		for i, name := range tt.names {
+			assertIdentifier(t, names[i], name)
+		}
+	}
+}
+
+func TestDestructuringParameters(t *testing.T) {
+	program := getAST(t, "fn([x, y], {z}, w) { x }")
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	fl := stmt.Expression.(*ast.FunctionLiteral)
+
+	if len(fl.Parameters) != 3 {
+		t.Fatalf("function literal parameters wrong. want 3, got=%d", len(fl.Parameters))
+	}
+	if fl.Parameters[0].Value != "[x, y]" || fl.ParameterPattern(0) == nil {
+		t.Fatalf("first parameter not destructured. got=%+v", fl.Parameters[0])
+	}
+	if fl.ParameterPattern(2) != nil {
+		t.Fatalf("plain parameter has a pattern. got=%+v", fl.ParameterPattern(2))
+	}
+
+	expected := []string{"x", "y", "z", "w"}
+	bindings := fl.Bindings()
+	if len(bindings) != len(expected) {
+		t.Fatalf("wrong bindings. expected=%v, got=%v", expected, bindings)
+	}
+	for i, name := range expected {
+		assertIdentifier(t, bindings[i], name)
+	}
+
+	p := New(lexer.New("let [a, a] = b;"))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Fatalf("expected error for name bound twice by a let pattern")
+	}
+}
+
+// arrow functions
+
+func TestArrowFunction(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		params   []string
+	}{
+		{"x => x * 2", "x => (x * 2)", []string{"x"}},
+		{"(a, b) => a + b", "(a, b) => (a + b)", []string{"a", "b"}},
+		{"() => 1", "() => 1", []string{}},
+		{"(x) => x", "x => x", []string{"x"}},
+		{"([a, b]) => a", "([a, b]) => a", []string{"[a, b]"}},
+		{"x => { let y = x; y }", "x => { let y = x;y }", []string{"x"}},
+		{`x => ({"k": x})`, `x => ({"k": x})`, []string{"x"}},
+		{"x => y => x + y", "x => y => (x + y)", []string{"x"}},
+	}
+
+	for _, tt := range tests {
+		program := getAST(t, tt.input)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		fl, ok := stmt.Expression.(*ast.FunctionLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.FunctionLiteral. got=%T", stmt.Expression)
+		}
+
+		if fl.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, fl.String())
+		}
+
+		if len(fl.Parameters) != len(tt.params) {
+			t.Fatalf("wrong parameters. expected=%v, got=%v", tt.params, fl.Parameters)
+		}
+		for i, param := range tt.params {
+			if fl.Parameters[i].Value != param {
+				t.Errorf("parameter %d wrong. expected=%q, got=%q", i, param, fl.Parameters[i].Value)
+			}
+		}
+
+		// the short form parses back to the same function
+		if again := getAST(t, fl.String()); again.String() != program.String() {
+			t.Errorf("round trip changed %q to %q", program.String(), again.String())
+		}
+	}
+}
+
+func TestArrowFunctionDisambiguation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(a + b) * c", "((a + b) * c)"},
+		{"(a)", "a"},
+		{"([1, 2])[0]", "([1, 2][0])"},
+		{"map(xs, x => x + 1)", "map(xs, x => (x + 1))"},
+		{"map(xs, (x, i) => x * i)", "map(xs, (x, i) => (x * i))"},
+		{"xs |> map(x => x)", "map(xs, x => x)"},
+		{"match (v) { n if ok => n }", "match (v) {n if ok => n}"},
+		{"match (v) { n if any(xs, x => x) => n }", "match (v) {n if any(xs, x => x) => n}"},
+	}
+
+	for _, tt := range tests {
+		program := getAST(t, tt.input)
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	p := New(lexer.New("fn(1) { 1 }"))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 || p.Errors()[0] != "1:4: expected parameter, got 1" {
+		t.Fatalf("expected parameter error, got=%v", p.Errors())
+	}
+}
+
+// for loops
+
+func TestForStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"for (x in xs) { x }", "for (x in xs) x"},
+		{"for ([k, v] in pairs) { let s = k + v; s }", "for ([k, v] in pairs) let s = (k + v);s"},
+		{"for (i in 0..<n) { f(i); }", "for (i in (0 ..< n)) f(i)"},
+	}
+
+	for _, tt := range tests {
+		program := getAST(t, tt.input)
+		if len(program.Statements) != 1 {
+			t.Fatalf("expected 1 statement, got=%d", len(program.Statements))
+		}
+
+		fs, ok := program.Statements[0].(*ast.ForStatement)
+		if !ok {
+			t.Fatalf("statement not *ast.ForStatement. got=%T", program.Statements[0])
+		}
+
+		if fs.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, fs.String())
+		}
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"for (x of xs) { x }", "expected next token to be IN, got IDENTIFIER instead"},
+		{"for ([a, a] in xs) { a }", "1:10: a is bound more than once in pattern"},
+		{"for (x in xs) { const x = 1; }", "1:23: cannot redeclare x, declared at 1:6"},
+	}
+
+	for _, tt := range errors {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("%q - expected error %q, got=%v", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+// generators
+
+func TestGenerators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn* (n) { yield n; }", "fn*(n)yield n;"},
+		{"fn*(n) { yield n * 2 }", "fn*(n)yield (n * 2);"},
+		{"fn* count(n) { yield n; }", "fn* count(n)yield n;"},
+		{"let g = fn* () { for (x in xs) { yield x; } }", "let g = fn*()for (x in xs) yield x;;"},
+		{"fn* outer() { let f = fn* () { yield 1; }; yield f; }", "fn* outer()let f = fn*()yield 1;;yield f;"},
+		{"2 * 3", "(2 * 3)"},
+	}
+
+	for _, tt := range tests {
+		program := getAST(t, tt.input)
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	program := getAST(t, "fn* count(n) { yield n; }")
+	fs, ok := program.Statements[0].(*ast.FunctionStatement)
+	if !ok || !fs.Function.Generator || fs.Name.Value != "count" {
+		t.Fatalf("expected generator declaration count, got=%T (%+v)", program.Statements[0], program.Statements[0])
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"yield 1;", "1:1: yield outside of a generator"},
+		{"fn (n) { yield n; }", "1:10: yield outside of a generator"},
+		{"fn* (n) { let f = fn() { yield n; }; }", "1:26: yield outside of a generator"},
+		{"fn* (n) { let f = x => { yield x; }; }", "1:26: yield outside of a generator"},
+	}
+
+	for _, tt := range errors {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("%q - expected error %q, got=%v", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+// structs
+
+func TestStructStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		name     string
+		fields   []string
+		expected string
+	}{
+		{"struct Point { x, y }", "Point", []string{"x", "y"}, "struct Point { x, y }"},
+		{"struct Line { from, to, };", "Line", []string{"from", "to"}, "struct Line { from, to }"},
+		{"struct Empty {}", "Empty", []string{}, "struct Empty {  }"},
+	}
+
+	for _, tt := range tests {
+		program := getAST(t, tt.input)
+		if len(program.Statements) != 1 {
+			t.Fatalf("expected 1 statement, got=%d", len(program.Statements))
+		}
+
+		ss, ok := program.Statements[0].(*ast.StructStatement)
+		if !ok {
+			t.Fatalf("statement not *ast.StructStatement. got=%T", program.Statements[0])
+		}
+
+		if ss.Name.Value != tt.name {
+			t.Errorf("wrong name. expected=%q, got=%q", tt.name, ss.Name.Value)
+		}
+		if len(ss.Fields) != len(tt.fields) {
+			t.Fatalf("wrong fields. expected=%v, got=%v", tt.fields, ss.Fields)
+		}
+		for i, field := range tt.fields {
+			if ss.Fields[i].Value != field {
+				t.Errorf("field %d wrong. expected=%q, got=%q", i, field, ss.Fields[i].Value)
+			}
+		}
+		if ss.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, ss.String())
+		}
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"struct Point { x, x }", "1:19: duplicate field x in struct Point"},
+		{"struct { x }", "expected next token to be IDENTIFIER, got { instead"},
+		{"struct P { 1 }", "expected next token to be IDENTIFIER, got INT instead"},
+		{"p.1", "expected next token to be IDENTIFIER, got INT instead"},
+		{"struct P {} const P = 1;", "1:19: cannot redeclare P, declared at 1:8"},
+		{"let = 5; struct P {", "expected next token to be IDENTIFIER, got = instead"},
+		{"p.x() = 1", "cannot assign to p.x()"},
+	}
+
+	for _, tt := range errors {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("%q - expected error %q, got=%v", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+// methods
+
+func TestImplStatement(t *testing.T) {
+	input := `impl Point {
+		fn norm(self) { self.x * self.x }
+		fn* parts(self) { yield self.x; }
+	}`
+
+	program := getAST(t, input)
+	if len(program.Statements) != 1 {
+		t.Fatalf("expected 1 statement, got=%d", len(program.Statements))
+	}
+
+	is, ok := program.Statements[0].(*ast.ImplStatement)
+	if !ok {
+		t.Fatalf("statement not *ast.ImplStatement. got=%T", program.Statements[0])
+	}
+	if is.Name.Value != "Point" {
+		t.Errorf("wrong name. expected=%q, got=%q", "Point", is.Name.Value)
+	}
+	if len(is.Methods) != 2 || is.Methods[0].Name.Value != "norm" || is.Methods[1].Name.Value != "parts" {
+		t.Fatalf("wrong methods, got=%v", is.Methods)
+	}
+	if !is.Methods[1].Function.Generator {
+		t.Errorf("expected parts to be a generator")
+	}
+
+	expected := "impl Point { fn norm(self)(self.x * self.x) fn* parts(self)yield self.x; }"
+	if is.String() != expected {
+		t.Errorf("expected=%q, got=%q", expected, is.String())
+	}
+
+	mc, ok := getAST(t, "p.add(q, 1)").Statements[0].(*ast.ExpressionStatement).Expression.(*ast.MethodCallExpression)
+	if !ok {
+		t.Fatalf("expression not *ast.MethodCallExpression")
+	}
+	if mc.Object.String() != "p" || mc.Method.Value != "add" || len(mc.Arguments) != 2 {
+		t.Errorf("wrong method call, got=%q", mc.String())
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"impl Point { fn norm() { 1 } }", "1:17: method norm must take a receiver parameter, such as self"},
+		{"impl Point { let x = 1; }", "expected next token to be FUNCTION, got LET instead"},
+		{"impl Point { fn (self) { 1 } }", "expected next token to be IDENTIFIER, got ( instead"},
+		{"impl { }", "expected next token to be IDENTIFIER, got { instead"},
+	}
+
+	for _, tt := range errors {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("%q - expected error %q, got=%v", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+func TestEnumStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		name     string
+		variants []string
+		expected string
+	}{
+		{"enum Shape { Circle(r), Rect(w, h), Empty }", "Shape", []string{"Circle", "Rect", "Empty"},
+			"enum Shape { Circle(r), Rect(w, h), Empty }"},
+		{"enum Color { Red, Green, };", "Color", []string{"Red", "Green"}, "enum Color { Red, Green }"},
+	}
+
+	for _, tt := range tests {
+		program := getAST(t, tt.input)
+		if len(program.Statements) != 1 {
+			t.Fatalf("expected 1 statement, got=%d", len(program.Statements))
+		}
+
+		es, ok := program.Statements[0].(*ast.EnumStatement)
+		if !ok {
+			t.Fatalf("statement not *ast.EnumStatement. got=%T", program.Statements[0])
+		}
+
+		if es.Name.Value != tt.name {
+			t.Errorf("wrong name. expected=%q, got=%q", tt.name, es.Name.Value)
+		}
+		if len(es.Variants) != len(tt.variants) {
+			t.Fatalf("wrong variants. expected=%v, got=%v", tt.variants, es.Variants)
+		}
+		for i, variant := range tt.variants {
+			if es.Variants[i].Name.Value != variant {
+				t.Errorf("variant %d wrong. expected=%q, got=%q", i, variant, es.Variants[i].Name.Value)
+			}
+		}
+		if es.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, es.String())
+		}
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"enum Color { Red, Red }", "1:19: duplicate variant Red in enum Color"},
+		{"enum Shape { Rect(w, w) }", "1:22: duplicate field w in variant Shape.Rect"},
+		{"enum Shape { Circle() }", "expected next token to be IDENTIFIER, got ) instead"},
+		{"enum Color { Red Green }", "expected next token to be ,, got IDENTIFIER instead"},
+		{"enum Color {} const Color = 1;", "1:21: cannot redeclare Color, declared at 1:6"},
+		{"match (c) { Color.1 => 1 }", "expected next token to be IDENTIFIER, got INT instead"},
+	}
+
+	for _, tt := range errors {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("%q - expected error %q, got=%v", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+func TestImportExport(t *testing.T) {
+	input := `import "lib/math" as m;
+	export let x = 1;
+	export const [a, b] = [1, 2];
+	export fn f() { x }
+	export struct P { v }
+	export enum E { A }
+	let hidden = 2;`
+
+	program := getAST(t, input)
+	if len(program.Statements) != 7 {
+		t.Fatalf("expected 7 statements, got=%d", len(program.Statements))
+	}
+
+	is, ok := program.Statements[0].(*ast.ImportStatement)
+	if !ok {
+		t.Fatalf("statement not *ast.ImportStatement. got=%T", program.Statements[0])
+	}
+	if is.Path.Value != "lib/math" || is.Name.Value != "m" {
+		t.Errorf("wrong import, got=%q", is.String())
+	}
+
+	var exports []string
+	for _, name := range program.Exports() {
+		exports = append(exports, name.Value)
+	}
+	if strings.Join(exports, " ") != "x a b f P E" {
+		t.Errorf("wrong exports, got=%v", exports)
+	}
+
+	expected := `import "lib/math" as m;export let x = 1;`
+	if !strings.HasPrefix(program.String(), expected) {
+		t.Errorf("expected prefix=%q, got=%q", expected, program.String())
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"import lib as m;", "expected next token to be STRING, got IDENTIFIER instead"},
+		{`import "lib";`, "expected next token to be AS, got ; instead"},
+		{`import "lib" as 1;`, "expected next token to be IDENTIFIER, got INT instead"},
+		{`import "a" as m; import "b" as m;`, "1:32: cannot redeclare constant m, declared at 1:15"},
+		{"export 1;", "1:8: cannot export 1, only declarations"},
+		{"export fn() { 1 };", "1:8: cannot export a function literal, only declarations"},
+		{"fn f() { export let x = 1; }", "1:10: cannot export outside of the top level"},
+		{"export let = 1;", "expected next token to be IDENTIFIER, got = instead"},
+	}
+
+	for _, tt := range errors {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("%q - expected error %q, got=%v", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+func TestIllegalTokenErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let s = "open;`, "1:9: unterminated string \"open;"},
+		{`let s = "caf\u00e9";`, `1:9: unknown escape \u in string`},
+		{"let s = @;", "1:9: illegal character @"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("%q - expected error %q, got=%v", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
@@ -7,17 +7,32 @@ type pRank uint8
 const (
 	_ pRank = iota
 	LOWEST
+	ASSIGN		// =
+	PIPE		// |>
+	TERNARY		// X ? Y : Z
+	NULLISH		// ??
+	OR			// ||
+	AND			// &&
 	EQUALS		// ==
 	LESSGREATER // >, <, <=, >=
+	RANGE		// .. ..<
 	SUM			// + -
 	PRODUCT		// * /
 	MOD			// %
 	POWER		// **
 	PREFIX		// -X or !X
+	POSTFIX		// X?
 	CALL		// myFunction(X)
+	MEMBER		// point.x
+	INDEX		// array[index]
 )
 
 var precedenceTable = map[tk.TokenType]pRank{
+	tk.ASSIGN:   ASSIGN,
+	tk.PIPE:     PIPE,
+	tk.NULLISH:  NULLISH,
+	tk.OR:       OR,
+	tk.AND:      AND,
 	tk.PLUS:     SUM,
 	tk.MINUS:    SUM,
 	tk.ASTRICK:  PRODUCT,
@@ -30,5 +45,10 @@ var precedenceTable = map[tk.TokenType]pRank{
 	tk.LTEQ:     LESSGREATER,
 	tk.GT:       LESSGREATER,
 	tk.GTEQ:     LESSGREATER,
+	tk.RANGE:    RANGE,
+	tk.RANGE_EXCLUSIVE: RANGE,
+	tk.QUESTION: POSTFIX,
 	tk.LPAREN:   CALL,
+	tk.DOT:      MEMBER,
+	tk.LBRACKET: INDEX,
 }
@@ -10,9 +10,9 @@ import (
 
 const PROMPT = "-> "
 
-func Start(in io.Reader, out io.Writer) {
+func Start(in io.Reader, out io.Writer, options *obj.Options) {
 	rio := SetupIO(in, out)
-	env := obj.NewEnvironment()
+	env := obj.NewEnvironmentWithOptions(options)
 
 	for {
 		rio.Write(PROMPT)
@@ -28,6 +28,7 @@ func Start(in io.Reader, out io.Writer) {
 
 		l := lexer.New(line)
 		p := parser.New(l)
+		p.SetStrictLet(options.StrictLet)
 
 		program := p.ParseProgram()
 		if len(p.Errors()) != 0 {
@@ -0,0 +1,46 @@
+package resolver
+
+import (
+	"fmt"
+	"mkc/token"
+	"sort"
+)
+
+type Severity string
+
+const (
+	ERROR   Severity = "error"
+	WARNING Severity = "warning"
+)
+
+// A problem found in a program before it runs
+type Diagnostic struct {
+	Token    tk.Token
+	Severity Severity
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s: %s", d.Token.Position(), d.Severity, d.Message)
+}
+
+// Checks if any diagnostic would make the program fail
+func HasErrors(diagnostics []Diagnostic) bool {
+	for _, d := range diagnostics {
+		if d.Severity == ERROR {
+			return true
+		}
+	}
+	return false
+}
+
+// Orders diagnostics by their position in the source
+func sortDiagnostics(diagnostics []Diagnostic) {
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i].Token, diagnostics[j].Token
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+}
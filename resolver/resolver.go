@@ -0,0 +1,552 @@
+package resolver
+
+import (
+	"fmt"
+	"mkc/ast"
+	"mkc/token"
+	"strings"
+)
+
+// Walks a program before evaluation, building the same scopes as the
+// evaluator's environments, and reports undefined names, unused and
+// shadowing declarations, and unreachable code.
+// Names local to functions are annotated with slots along the way,
+// which the evaluator uses instead of looking them up by name.
+type Resolver struct {
+	strictLet   bool
+	universe    *scope
+	scope       *scope
+	blocks      int
+	diagnostics []Diagnostic
+}
+
+// Returns a resolver that knows no names yet
+func New() *Resolver {
+	return &Resolver{universe: newScope(nil)}
+}
+
+// Lints a program that uses no predeclared names
+func Lint(program *ast.Program) []Diagnostic {
+	return New().Resolve(program)
+}
+
+// Makes names available to every program, such as builtins or names
+// already bound in an environment
+func (r *Resolver) Define(names ...string) {
+	for _, name := range names {
+		r.universe.symbols[name] = &symbol{kind: PREDECLARED}
+	}
+}
+
+// Makes let redeclaration in the same scope an error
+func (r *Resolver) SetStrictLet(strict bool) {
+	r.strictLet = strict
+}
+
+// Resolves a program, returning its diagnostics ordered by position
+func (r *Resolver) Resolve(program *ast.Program) []Diagnostic {
+	r.diagnostics = []Diagnostic{}
+
+	r.scope = r.universe
+	r.openScope(nil)
+	r.resolveBlock(program.Statements)
+	r.closeScope()
+
+	sortDiagnostics(r.diagnostics)
+	return r.diagnostics
+}
+
+///////////////
+// Reporting //
+///////////////
+
+func (r *Resolver) report(severity Severity, token tk.Token, format string, a ...interface{}) {
+	r.diagnostics = append(r.diagnostics, Diagnostic{
+		Token:    token,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, a...),
+	})
+}
+
+// Returns the first token of a statement
+func statementToken(statement ast.Statement) tk.Token {
+	switch node := statement.(type) {
+	case *ast.LetStatement:
+		return node.Token
+	case *ast.FunctionStatement:
+		return node.Token
+	case *ast.ReturnStatement:
+		return node.Token
+	case *ast.ThrowStatement:
+		return node.Token
+	case *ast.ExpressionStatement:
+		return node.Token
+	case *ast.BlockStatement:
+		return node.Token
+	case *ast.ForStatement:
+		return node.Token
+	case *ast.YieldStatement:
+		return node.Token
+	case *ast.StructStatement:
+		return node.Token
+	case *ast.ImplStatement:
+		return node.Token
+	case *ast.EnumStatement:
+		return node.Token
+	case *ast.ImportStatement:
+		return node.Token
+	case *ast.ExportStatement:
+		return node.Token
+	}
+	return tk.Token{}
+}
+
+////////////
+// Scopes //
+////////////
+
+// Enters the scope of a function body or of the program
+func (r *Resolver) openScope(slots *int) {
+	s := newScope(r.scope)
+	s.slots = slots
+	s.owner = s
+	r.scope = s
+}
+
+// Enters a scope nested in the current function, such as a catch clause
+func (r *Resolver) openBlockScope(slots *int) {
+	s := newScope(r.scope)
+	s.slots = slots
+	s.owner = r.scope.owner
+	s.owner.children = append(s.owner.children, s)
+	r.scope = s
+}
+
+// Leaves a nested scope, its names are checked along with its owner
+func (r *Resolver) closeBlockScope() {
+	r.scope = r.scope.outer
+}
+
+// Resolves the functions deferred in the current scope, then assigns
+// slots and reports unused names in it and its nested scopes, and leaves it
+func (r *Resolver) closeScope() {
+	s := r.scope
+
+	for len(s.pending) > 0 {
+		pf := s.pending[0]
+		s.pending = s.pending[1:]
+
+		r.scope = pf.parent
+		r.resolveFunction(pf.function)
+	}
+
+	for _, sc := range append(s.children, s) {
+		if sc.slots != nil {
+			sc.assignSlots()
+		}
+
+		for name, sym := range sc.symbols {
+			r.checkUnused(name, sym)
+		}
+	}
+
+	r.scope = s.outer
+}
+
+// Resolves a function literal once its owner scope is complete, so the
+// body can refer to names declared after the literal
+func (r *Resolver) deferFunction(fl *ast.FunctionLiteral) {
+	owner := r.scope.owner
+	owner.pending = append(owner.pending, pendingFunction{function: fl, parent: r.scope})
+}
+
+// Resolves a function body in a new scope, inside the current one
+func (r *Resolver) resolveFunction(fl *ast.FunctionLiteral) {
+	r.openScope(&fl.Slots)
+
+	for i := range fl.Parameters {
+		r.resolvePattern(fl.ParameterPattern(i))
+	}
+	for _, param := range fl.Bindings() {
+		r.declare(param, PARAMETER, 0)
+	}
+	r.resolveBlock(fl.Body.Statements)
+
+	r.closeScope()
+}
+
+// Binds a name in the current scope
+func (r *Resolver) declare(name *ast.Identifier, kind symbolKind, block int) {
+	s := r.scope
+
+	if previous, ok := s.symbols[name.Value]; ok {
+		constant := previous.kind == CONST || kind == CONST
+		if s.active(previous.block) && (constant || r.strictLet) {
+			if previous.kind == CONST {
+				r.report(ERROR, name.Token, "cannot redeclare constant %s, declared at %s",
+					name.Value, previous.token.Position())
+			} else {
+				r.report(ERROR, name.Token, "cannot redeclare %s, declared at %s",
+					name.Value, previous.token.Position())
+			}
+		} else {
+			r.checkUnused(name.Value, previous)
+		}
+	} else if shadowed, ok := s.outer.lookup(name.Value); ok && shadowed.kind != PREDECLARED {
+		r.report(WARNING, name.Token, "%s shadows declaration at %s",
+			name.Value, shadowed.token.Position())
+	}
+
+	s.symbols[name.Value] = &symbol{token: name.Token, kind: kind, block: block, scope: s}
+
+	l := s.local(name.Value)
+	l.declarations += 1
+	l.constant = l.constant || kind == CONST
+	l.refs = append(l.refs, reference{ident: name})
+}
+
+// Records a reference to a symbol, so it is annotated with the symbol's slot
+func (r *Resolver) reference(ident *ast.Identifier, sym *symbol, assigned bool) {
+	if sym.scope == nil || sym.scope.slots == nil {
+		return
+	}
+
+	l := sym.scope.local(ident.Value)
+	l.assigned = l.assigned || assigned
+	l.refs = append(l.refs, reference{ident: ident, depth: r.scope.depth - sym.scope.depth})
+}
+
+// Warns about a let or const that is never read
+func (r *Resolver) checkUnused(name string, sym *symbol) {
+	if sym.used || strings.HasPrefix(name, "_") {
+		return
+	}
+	if sym.kind == LET || sym.kind == CONST {
+		r.report(WARNING, sym.token, "%s declared but never used", name)
+	}
+}
+
+////////////////
+// Statements //
+////////////////
+
+// Resolves a list of statements sharing the current scope
+func (r *Resolver) resolveBlock(statements []ast.Statement) {
+	r.blocks += 1
+	block := r.blocks
+
+	s := r.scope
+	s.blocks = append(s.blocks, block)
+
+	// function, struct and enum declarations are hoisted to the start of their block
+	for _, statement := range statements {
+		switch st := ast.Unexported(statement).(type) {
+		case *ast.FunctionStatement:
+			r.declare(st.Name, FUNCTION, block)
+		case *ast.StructStatement:
+			r.declare(st.Name, FUNCTION, block)
+		case *ast.EnumStatement:
+			r.declare(st.Name, FUNCTION, block)
+			s.symbols[st.Name.Value].enum = st
+		}
+	}
+
+	terminated := false
+	for _, statement := range statements {
+		if terminated {
+			r.report(WARNING, statementToken(statement), "unreachable code")
+			terminated = false
+		}
+		r.resolveStatement(statement, block)
+
+		switch statement.(type) {
+		case *ast.ReturnStatement, *ast.ThrowStatement:
+			terminated = true
+		}
+	}
+
+	s.blocks = s.blocks[:len(s.blocks)-1]
+}
+
+func (r *Resolver) resolveStatement(statement ast.Statement, block int) {
+	switch node := statement.(type) {
+	case *ast.LetStatement:
+		r.resolveExpression(node.Value)
+		r.resolvePattern(node.Pattern)
+		kind := symbolKind(LET)
+		if node.IsConst() {
+			kind = CONST
+		}
+		for _, name := range node.Names() {
+			r.declare(name, kind, block)
+		}
+
+	case *ast.FunctionStatement:
+		r.deferFunction(node.Function)
+
+	case *ast.ReturnStatement:
+		r.resolveExpression(node.ReturnValue)
+
+	case *ast.ThrowStatement:
+		r.resolveExpression(node.Value)
+
+	case *ast.YieldStatement:
+		r.resolveExpression(node.Value)
+
+	case *ast.ImportStatement:
+		r.declare(node.Name, CONST, block)
+
+	case *ast.ExportStatement:
+		r.resolveStatement(node.Declaration, block)
+		// importers may read exports, so they are never unused
+		for _, name := range node.Names() {
+			r.scope.symbols[name.Value].used = true
+		}
+
+	case *ast.ImplStatement:
+		r.resolveExpression(node.Name)
+		for _, method := range node.Methods {
+			r.deferFunction(method.Function)
+		}
+
+	case *ast.ExpressionStatement:
+		r.resolveExpression(node.Expression)
+
+	case *ast.BlockStatement:
+		r.resolveBlock(node.Statements)
+
+	case *ast.ForStatement:
+		r.resolveExpression(node.Iterable)
+		// loop variables are not reported as unused, like parameters
+		r.openBlockScope(&node.Slots)
+		r.resolvePattern(node.Pattern)
+		for _, name := range ast.PatternBindings(node.Pattern) {
+			r.declare(name, PARAMETER, 0)
+		}
+		r.resolveBlock(node.Body.Statements)
+		r.closeBlockScope()
+	}
+}
+
+/////////////////
+// Expressions //
+/////////////////
+
+func (r *Resolver) resolveExpression(expression ast.Expression) {
+	switch node := expression.(type) {
+	case *ast.Identifier:
+		sym, ok := r.scope.lookup(node.Value)
+		if !ok {
+			r.report(ERROR, node.Token, "identifier not found: %s", node.Value)
+			return
+		}
+		sym.used = true
+		r.reference(node, sym, false)
+
+	case *ast.AssignExpression:
+		r.resolveExpression(node.Value)
+		sym, ok := r.scope.lookup(node.Name.Value)
+		if !ok {
+			r.report(ERROR, node.Name.Token, "identifier not found: %s", node.Name.Value)
+			return
+		}
+		if sym.kind == CONST {
+			r.report(ERROR, node.Name.Token, "cannot assign to constant %s, declared at %s",
+				node.Name.Value, sym.token.Position())
+		}
+		r.reference(node.Name, sym, true)
+
+	case *ast.PrefixExpression:
+		r.resolveExpression(node.Right)
+
+	case *ast.PostfixExpression:
+		r.resolveExpression(node.Left)
+
+	case *ast.InfixExpression:
+		r.resolveExpression(node.Left)
+		r.resolveExpression(node.Right)
+
+	case *ast.ConditionalExpression:
+		r.resolveExpression(node.Condition)
+		r.resolveExpression(node.Consequence)
+		r.resolveExpression(node.Alternative)
+
+	case *ast.IfExpression:
+		r.resolveExpression(node.Condition)
+		r.resolveBlock(node.Consequence.Statements)
+		if node.Alternative != nil {
+			r.resolveBlock(node.Alternative.Statements)
+		}
+
+	case *ast.FunctionLiteral:
+		r.deferFunction(node)
+
+	case *ast.ArrayLiteral:
+		for _, e := range node.Elements {
+			r.resolveExpression(e)
+		}
+
+	case *ast.HashLiteral:
+		for _, key := range node.Keys {
+			r.resolveExpression(key)
+			r.resolveExpression(node.Pairs[key])
+		}
+
+	case *ast.SpreadExpression:
+		r.resolveExpression(node.Value)
+
+	case *ast.MemberExpression:
+		r.resolveExpression(node.Object)
+
+	case *ast.MethodCallExpression:
+		r.resolveExpression(node.Object)
+		for _, arg := range node.Arguments {
+			r.resolveExpression(arg)
+		}
+
+	case *ast.MemberAssignExpression:
+		r.resolveExpression(node.Value)
+		r.resolveExpression(node.Target.Object)
+
+	case *ast.IndexExpression:
+		r.resolveExpression(node.Left)
+		r.resolveExpression(node.Index)
+
+	case *ast.MatchExpression:
+		r.resolveExpression(node.Subject)
+		for _, arm := range node.Arms {
+			r.resolvePattern(arm.Pattern)
+			// captures are not reported as unused, like parameters
+			r.openBlockScope(&arm.Slots)
+			for _, name := range ast.PatternBindings(arm.Pattern) {
+				r.declare(name, PARAMETER, 0)
+			}
+			if arm.Guard != nil {
+				r.resolveExpression(arm.Guard)
+			}
+			r.resolveBlock(arm.Body.Statements)
+			r.closeBlockScope()
+		}
+		r.checkExhaustive(node)
+
+	case *ast.TryExpression:
+		r.resolveBlock(node.Block.Statements)
+		if node.Catch != nil {
+			r.openBlockScope(&node.CatchSlots)
+			r.declare(node.Param, PARAMETER, 0)
+			r.resolveBlock(node.Catch.Statements)
+			r.closeBlockScope()
+		}
+		if node.Finally != nil {
+			r.resolveBlock(node.Finally.Statements)
+		}
+
+	case *ast.CallExpression:
+		r.resolveExpression(node.Function)
+		for _, arg := range node.Arguments {
+			r.resolveExpression(arg)
+		}
+	}
+}
+
+//////////////
+// Patterns //
+//////////////
+
+// Resolves the enum names in a pattern, and checks its variants exist
+// with the fields the pattern gives
+func (r *Resolver) resolvePattern(pattern ast.Pattern) {
+	switch p := pattern.(type) {
+	case *ast.ArrayPattern:
+		for _, element := range p.Elements {
+			r.resolvePattern(element)
+		}
+
+	case *ast.HashPattern:
+		for _, value := range p.Values {
+			r.resolvePattern(value)
+		}
+
+	case *ast.VariantPattern:
+		r.resolveExpression(p.Enum)
+		for _, field := range p.Fields {
+			r.resolvePattern(field)
+		}
+
+		enum := r.enum(p.Enum)
+		if enum == nil {
+			return
+		}
+		variant := enum.Variant(p.Variant.Value)
+		if variant == nil {
+			r.report(ERROR, p.Variant.Token, "%s has no variant %s", enum.Name.Value, p.Variant.Value)
+			return
+		}
+		if p.Fields != nil && len(p.Fields) != len(variant.Fields) {
+			r.report(ERROR, p.Variant.Token, "%s.%s has %d fields, pattern has %d",
+				enum.Name.Value, variant.Name.Value, len(variant.Fields), len(p.Fields))
+		}
+	}
+}
+
+// Returns the declaration of the enum a name refers to, or nil
+func (r *Resolver) enum(name *ast.Identifier) *ast.EnumStatement {
+	if sym, ok := r.scope.lookup(name.Value); ok {
+		return sym.enum
+	}
+	return nil
+}
+
+// Warns about a match over the variants of an enum that misses some of them.
+// Only arms without guards, whose fields match anything, cover a variant.
+func (r *Resolver) checkExhaustive(me *ast.MatchExpression) {
+	var enum *ast.EnumStatement
+	covered := map[string]bool{}
+
+	for _, arm := range me.Arms {
+		if arm.Guard != nil {
+			continue
+		}
+
+		switch p := arm.Pattern.(type) {
+		case *ast.WildcardPattern, *ast.BindingPattern:
+			return
+
+		case *ast.VariantPattern:
+			e := r.enum(p.Enum)
+			if e == nil || (enum != nil && e != enum) {
+				return
+			}
+			enum = e
+			if irrefutable(p.Fields) {
+				covered[p.Variant.Value] = true
+			}
+		}
+	}
+
+	if enum == nil {
+		return
+	}
+
+	var missing []string
+	for _, v := range enum.Variants {
+		if !covered[v.Name.Value] {
+			missing = append(missing, v.Name.Value)
+		}
+	}
+	if len(missing) > 0 {
+		r.report(WARNING, me.Token, "match over %s misses %s", enum.Name.Value, strings.Join(missing, ", "))
+	}
+}
+
+// Checks if patterns match any value
+func irrefutable(patterns []ast.Pattern) bool {
+	for _, pattern := range patterns {
+		switch pattern.(type) {
+		case *ast.WildcardPattern, *ast.BindingPattern:
+		default:
+			return false
+		}
+	}
+	return true
+}
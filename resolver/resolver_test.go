@@ -0,0 +1,392 @@
+package resolver
+
+import (
+	"mkc/ast"
+	"mkc/lexer"
+	"mkc/parser"
+	"testing"
+)
+
+///////////////
+// Utilities //
+///////////////
+
+func getAST(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != 0 {
+		t.Errorf("parser has %d errors", len(errors))
+		for _, msg := range errors {
+			t.Errorf("parser error: %q", msg)
+		}
+
+		t.FailNow()
+	}
+
+	return program
+}
+
+func assertDiagnostics(t *testing.T, input string, got []Diagnostic, expected []string) {
+	if len(got) != len(expected) {
+		t.Fatalf("%q - wrong number of diagnostics. expected=%v, got=%v", input, expected, got)
+	}
+
+	for i, msg := range expected {
+		if got[i].String() != msg {
+			t.Fatalf("%q - wrong diagnostic. expected=%q, got=%q", input, msg, got[i].String())
+		}
+	}
+}
+
+///////////
+// Tests //
+///////////
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		// clean programs
+		{"let a = 1; a;", []string{}},
+		{"let f = fn(x) { f(x) }; f(1);", []string{}},
+		{"let f = fn() { g() }; let g = fn() { 1 }; f();", []string{}},
+		{"fn isEven(n) { isOdd(n) } fn isOdd(n) { isEven(n) } isEven(1);", []string{}},
+		{"let _ignored = 1;", []string{}},
+		{"let a = 1; a = 2; a;", []string{}},
+
+		// undefined names
+		{"a;", []string{"1:1: error: identifier not found: a"}},
+		{"a; let a = 1; a;", []string{"1:1: error: identifier not found: a"}},
+		{"let f = fn(x) { y };\nf(1);", []string{"1:17: error: identifier not found: y"}},
+		{"b = 1;", []string{"1:1: error: identifier not found: b"}},
+
+		// unused names
+		{"let a = 1;", []string{"1:5: warning: a declared but never used"}},
+		{"let f = fn() { const a = 1; 2 }; f();", []string{"1:22: warning: a declared but never used"}},
+		{"let a = 1; a = 2;", []string{"1:5: warning: a declared but never used"}},
+
+		// shadowing
+		{
+			"let x = 1; let f = fn(x) { x }; f(x);",
+			[]string{"1:23: warning: x shadows declaration at 1:5"},
+		},
+		{
+			"let x = 1; let f = fn() { let x = 2; x }; f() + x;",
+			[]string{"1:31: warning: x shadows declaration at 1:5"},
+		},
+
+		// unreachable code
+		{
+			"let f = fn() { return 1; 2; 3 }; f();",
+			[]string{"1:26: warning: unreachable code"},
+		},
+		{
+			"let f = fn() { if (true) { return 1; 2 } 3 }; f();",
+			[]string{"1:38: warning: unreachable code"},
+		},
+
+		// constants
+		{
+			"const a = 1; if (true) { let a = 2; a }; a;",
+			[]string{"1:30: error: cannot redeclare constant a, declared at 1:7"},
+		},
+		{
+			"if (true) { const a = 1; a } else { const a = 2; a }",
+			[]string{},
+		},
+		{
+			"const a = 1; let f = fn() { a = 2 }; f();",
+			[]string{
+				"1:7: warning: a declared but never used",
+				"1:29: error: cannot assign to constant a, declared at 1:7",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		program := getAST(t, tt.input)
+		assertDiagnostics(t, tt.input, Lint(program), tt.expected)
+	}
+}
+
+func TestResolveStrictLet(t *testing.T) {
+	input := "let a = 1; let a = a + 1; a;"
+	program := getAST(t, input)
+
+	r := New()
+	assertDiagnostics(t, input, r.Resolve(program), []string{})
+
+	r = New()
+	r.SetStrictLet(true)
+	assertDiagnostics(t, input, r.Resolve(program), []string{
+		"1:16: error: cannot redeclare a, declared at 1:5",
+	})
+}
+
+func TestResolveDefine(t *testing.T) {
+	input := "let f = fn(len) { len }; f(len);"
+	program := getAST(t, input)
+
+	r := New()
+	r.Define("len")
+	assertDiagnostics(t, input, r.Resolve(program), []string{})
+
+	if !HasErrors(Lint(program)) {
+		t.Fatalf("expected errors without len defined")
+	}
+}
+
+func TestResolveSlots(t *testing.T) {
+	input := `
+		let g = 1;
+		let f = fn(a, b) {
+			let c = a + b;
+			let inner = fn(d) { c + d + g };
+			let c = 3;
+			inner(c);
+		};
+	`
+	program := getAST(t, input)
+	Lint(program)
+
+	f := program.Statements[1].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+
+	// a, b, inner; c is declared twice and stays named
+	if f.Slots != 3 {
+		t.Fatalf("f.Slots wrong. want=3, got=%d", f.Slots)
+	}
+
+	a, b := f.Parameters[0], f.Parameters[1]
+	if !a.Resolved || a.Slot != 0 || !b.Resolved || b.Slot != 1 {
+		t.Fatalf("parameters not resolved to slots 0 and 1. got=%+v, %+v", a, b)
+	}
+
+	innerLet := f.Body.Statements[1].(*ast.LetStatement)
+	inner := innerLet.Value.(*ast.FunctionLiteral)
+	if inner.Slots != 1 {
+		t.Fatalf("inner.Slots wrong. want=1, got=%d", inner.Slots)
+	}
+
+	// c + d + g
+	body := inner.Body.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.InfixExpression)
+	c := body.Left.(*ast.InfixExpression).Left.(*ast.Identifier)
+	d := body.Left.(*ast.InfixExpression).Right.(*ast.Identifier)
+	g := body.Right.(*ast.Identifier)
+
+	if c.Resolved {
+		t.Fatalf("redeclared c should stay named. got=%+v", c)
+	}
+	if !d.Resolved || d.Depth != 0 || d.Slot != 0 {
+		t.Fatalf("d not resolved to depth 0 slot 0. got=%+v", d)
+	}
+	if g.Resolved {
+		t.Fatalf("global g should stay named. got=%+v", g)
+	}
+
+	call := f.Body.Statements[3].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	callee := call.Function.(*ast.Identifier)
+	if !callee.Resolved || callee.Depth != 0 || callee.Slot != innerLet.Name.Slot {
+		t.Fatalf("inner not resolved to its declaration slot. got=%+v", callee)
+	}
+}
+
+func TestResolveTryCatch(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"try { 1 } catch (e) { e }", []string{}},
+		{"try { 1 } catch (e) { 2 }; e;", []string{"1:28: error: identifier not found: e"}},
+		{
+			"let f = fn() { throw \"x\"; 1 }; f();",
+			[]string{"1:27: warning: unreachable code"},
+		},
+		{
+			"let f = fn() { try { 1 } catch (e) { let g = fn() { h }; g() } }; let h = 1; f();",
+			[]string{},
+		},
+		{
+			"let e = 1; try { e } catch (e) { e }",
+			[]string{"1:29: warning: e shadows declaration at 1:5"},
+		},
+	}
+
+	for _, tt := range tests {
+		program := getAST(t, tt.input)
+		assertDiagnostics(t, tt.input, Lint(program), tt.expected)
+	}
+}
+
+func TestResolveMatch(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"match (1) { [a, ...rest] => a, n if n > 1 => n, _ => 0 }", []string{}},
+		{"match (1) { n => n }; n;", []string{"1:23: error: identifier not found: n"}},
+		{"match (1) { n if m => n }", []string{"1:18: error: identifier not found: m"}},
+		{
+			"let n = 1; match (n) { n => n }",
+			[]string{"1:24: warning: n shadows declaration at 1:5"},
+		},
+		{
+			"let f = fn(x) { match (x) { [a, b] => { let c = a; b } } }; f(1);",
+			[]string{"1:45: warning: c declared but never used"},
+		},
+	}
+
+	for _, tt := range tests {
+		program := getAST(t, tt.input)
+		assertDiagnostics(t, tt.input, Lint(program), tt.expected)
+	}
+}
+
+func TestResolveDestructuring(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let [a, ...rest] = [1]; a + rest[0];", []string{}},
+		{"let {name, age: years} = x; name;", []string{
+			"1:17: warning: years declared but never used",
+			"1:26: error: identifier not found: x",
+		}},
+		{"let f = fn([a, b], {c}) { a + b + c }; f([1, 2], {});", []string{}},
+		{"const [k] = [1]; k = 2; k;", []string{"1:18: error: cannot assign to constant k, declared at 1:8"}},
+	}
+
+	for _, tt := range tests {
+		program := getAST(t, tt.input)
+		assertDiagnostics(t, tt.input, Lint(program), tt.expected)
+	}
+}
+
+func TestResolveForStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let s = 0; for ([a, b] in [[1, 2]]) { s = s + a + b; } s;", []string{}},
+		{"for (x in xs) { 1; }", []string{"1:11: error: identifier not found: xs"}},
+		{"for (x in [1]) { let y = x; } x;", []string{
+			"1:22: warning: y declared but never used",
+			"1:31: error: identifier not found: x",
+		}},
+		{"let f = fn(xs) { for (x in xs) { return x; } }; f([1]);", []string{}},
+	}
+
+	for _, tt := range tests {
+		program := getAST(t, tt.input)
+		assertDiagnostics(t, tt.input, Lint(program), tt.expected)
+	}
+}
+
+func TestResolveGenerator(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"fn* g(n) { for (i in 0..<n) { yield i * 2; } } g(3);", []string{}},
+		{"fn* g() { yield missing; } g();", []string{"1:17: error: identifier not found: missing"}},
+		{"fn* g() { return 1; yield 2; } g();", []string{"1:21: warning: unreachable code"}},
+	}
+
+	for _, tt := range tests {
+		program := getAST(t, tt.input)
+		assertDiagnostics(t, tt.input, Lint(program), tt.expected)
+	}
+}
+
+func TestResolveStruct(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let p = Point(1, 2); p.x = p.y; struct Point { x, y }", []string{}},
+		{"struct Point { x, y } let p = Point(1, 2); q.x = p.x;", []string{"1:44: error: identifier not found: q"}},
+		{"struct Point { x, y } let p = Point(1, 2); p.x = z;", []string{"1:50: error: identifier not found: z"}},
+		{"let f = fn() { struct Box { v } Box }; f(); Box;", []string{"1:45: error: identifier not found: Box"}},
+	}
+
+	for _, tt := range tests {
+		program := getAST(t, tt.input)
+		assertDiagnostics(t, tt.input, Lint(program), tt.expected)
+	}
+}
+
+func TestResolveImpl(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"struct P { v } impl P { fn get(self) { self.v } } P(1).get();", []string{}},
+		{"impl Q { fn get(self) { 1 } }", []string{"1:6: error: identifier not found: Q"}},
+		{"struct P { v } impl P { fn get(self) { w } }", []string{"1:40: error: identifier not found: w"}},
+		{"let xs = [1]; xs.push(y);", []string{"1:23: error: identifier not found: y"}},
+	}
+
+	for _, tt := range tests {
+		program := getAST(t, tt.input)
+		assertDiagnostics(t, tt.input, Lint(program), tt.expected)
+	}
+}
+
+func TestResolveEnum(t *testing.T) {
+	shape := "enum Shape { Circle(r), Rect(w, h), Empty } "
+
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{shape + "fn f(s) { match (s) { Shape.Circle(r) => r, Shape.Rect(w, _) => w, Shape.Empty => 0 } } f(Shape.Empty);", []string{}},
+		{shape + "fn f(s) { match (s) { Shape.Circle(r) => r, _ => 0 } } f(Shape.Empty);", []string{}},
+		{shape + "fn f(s) { match (s) { Shape.Circle(r) => r } } f(Shape.Empty);", []string{
+			"1:55: warning: match over Shape misses Rect, Empty",
+		}},
+		{shape + "fn f(s) { match (s) { Shape.Circle(1) => 1, Shape.Rect => 2, Shape.Empty => 0 } } f(Shape.Empty);", []string{
+			"1:55: warning: match over Shape misses Circle",
+		}},
+		{shape + "fn f(s) { match (s) { Shape.Circle(r) if r > 0 => r, Shape.Rect => 2, Shape.Empty => 0 } } f(Shape.Empty);", []string{
+			"1:55: warning: match over Shape misses Circle",
+		}},
+		{shape + "fn f(s) { match (s) { Shape.Square => 1, _ => 0 } } f(Shape.Empty);", []string{
+			"1:73: error: Shape has no variant Square",
+		}},
+		{shape + "fn f(s) { match (s) { Shape.Rect(w) => w, _ => 0 } } f(Shape.Empty);", []string{
+			"1:73: error: Shape.Rect has 2 fields, pattern has 1",
+		}},
+		{shape + "let Shape.Circle(r) = Shape.Circle(1); r; for (Shape.Circle(q) in []) { q; }", []string{}},
+		{"fn f(s) { match (s) { Shape.Empty => 0 } } f(1);", []string{"1:23: error: identifier not found: Shape"}},
+		{"let f = fn() { enum E { A } E.A }; f(); E;", []string{"1:41: error: identifier not found: E"}},
+	}
+
+	for _, tt := range tests {
+		program := getAST(t, tt.input)
+		assertDiagnostics(t, tt.input, Lint(program), tt.expected)
+	}
+}
+
+func TestResolveModules(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`import "lib" as m; m.f(1);`, []string{}},
+		{`import "lib" as m;`, []string{"1:17: warning: m declared but never used"}},
+		{`import "lib" as m; m = 1;`, []string{"1:17: warning: m declared but never used", "1:20: error: cannot assign to constant m, declared at 1:17"}},
+		{"export let x = 1; export const y = 2; let z = 3;", []string{"1:43: warning: z declared but never used"}},
+		{"export fn f() { g() } fn g() { 1 }", []string{}},
+		{"export let x = y;", []string{"1:16: error: identifier not found: y"}},
+		{"export let x = E.A; export enum E { A, B } fn f(e) { match (e) { E.A => 1 } } f(x);", []string{
+			"1:54: warning: match over E misses B",
+		}},
+	}
+
+	for _, tt := range tests {
+		program := getAST(t, tt.input)
+		assertDiagnostics(t, tt.input, Lint(program), tt.expected)
+	}
+}
@@ -0,0 +1,148 @@
+package resolver
+
+import (
+	"mkc/ast"
+	"mkc/token"
+	"sort"
+)
+
+type symbolKind uint8
+
+const (
+	_ symbolKind = iota
+	PREDECLARED
+	PARAMETER
+	FUNCTION
+	LET
+	CONST
+)
+
+// A declared name
+type symbol struct {
+	token tk.Token
+	kind  symbolKind
+	block int // block the declaration is in, 0 for the whole scope
+	used  bool
+	scope *scope
+	enum  *ast.EnumStatement // declaration of an enum name, to check patterns
+}
+
+// Every declaration of and reference to a name in one scope,
+// used to decide if the name can be given a slot
+type local struct {
+	declarations int
+	constant     bool
+	assigned     bool
+	refs         []reference
+}
+
+// An identifier referring to a local, depth functions inside its scope
+type reference struct {
+	ident *ast.Identifier
+	depth int
+}
+
+// Names visible in one object.Environment, such as the program, a
+// function body or a catch clause. Blocks of if expressions share the
+// scope around them, like they share its environment.
+type scope struct {
+	outer   *scope
+	symbols map[string]*symbol
+	depth   int
+
+	// where to store the number of slots, nil for the program whose
+	// names always stay in the environment's map
+	slots  *int
+	locals map[string]*local
+
+	// ids of the blocks being walked, innermost last
+	blocks []int
+
+	// The function or program scope this scope is part of. Owners resolve
+	// function literals once they are complete, and only then check the
+	// scopes nested in them.
+	owner    *scope
+	pending  []pendingFunction
+	children []*scope
+}
+
+// A function literal waiting for its owner scope to be complete
+type pendingFunction struct {
+	function *ast.FunctionLiteral
+	parent   *scope
+}
+
+func newScope(outer *scope) *scope {
+	s := &scope{
+		outer:   outer,
+		symbols: make(map[string]*symbol),
+		locals:  make(map[string]*local),
+	}
+	if outer != nil {
+		s.depth = outer.depth + 1
+	}
+	return s
+}
+
+// Returns what is known about name as a local of this scope
+func (s *scope) local(name string) *local {
+	l, ok := s.locals[name]
+	if !ok {
+		l = &local{}
+		s.locals[name] = l
+	}
+	return l
+}
+
+// Gives a slot to every local declared exactly once, and that is not
+// a constant someone tries to assign to. These can never be redeclared
+// at runtime, so the evaluator can skip its checks for them.
+// Everything else stays a named binding in the environment.
+func (s *scope) assignSlots() {
+	names := make([]string, 0, len(s.locals))
+	for name := range s.locals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	slots := 0
+	for _, name := range names {
+		l := s.locals[name]
+		if l.declarations != 1 || (l.constant && l.assigned) {
+			continue
+		}
+
+		for _, ref := range l.refs {
+			ref.ident.Resolved = true
+			ref.ident.Depth = ref.depth
+			ref.ident.Slot = slots
+		}
+		slots += 1
+	}
+
+	*s.slots = slots
+}
+
+// Finds the symbol for name in this or any outer scope
+func (s *scope) lookup(name string) (*symbol, bool) {
+	for sc := s; sc != nil; sc = sc.outer {
+		if sym, ok := sc.symbols[name]; ok {
+			return sym, true
+		}
+	}
+	return nil, false
+}
+
+// Checks if a block is being walked, so declarations in it are
+// certain to have run before the current statement
+func (s *scope) active(block int) bool {
+	if block == 0 {
+		return true
+	}
+	for _, b := range s.blocks {
+		if b == block {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+// Functions that make and combine functions
+
+import "std/testing" as t;
+
+export fn identity(x) { x }
+
+// Returns a function that ignores its argument and returns x
+export fn always(x) { _ => x }
+
+// Returns a function applying g, then f
+export fn compose(f, g) { x => f(g(x)) }
+
+// Returns a function applying each of fs in turn, the first one first
+export fn pipe(fs) {
+	x => {
+		let out = x;
+		for (f in fs) { out = f(out); }
+		out
+	}
+}
+
+// Returns f with its two arguments swapped
+export fn flip(f) { (a, b) => f(b, a) }
+
+// Returns f with its first argument fixed to a
+export fn partial(f, a) { b => f(a, b) }
+
+// Returns f taking its two arguments one at a time
+export fn curry(f) { a => b => f(a, b) }
+
+// Returns the results of f(0) to f(n - 1)
+export fn times(n, f) {
+	let out = [];
+	for (i in 0..<n) { out.push(f(i)); }
+	out
+}
+
+fn test_combinators() {
+	let inc = x => x + 1;
+	let double = x => x * 2;
+	t.assert_eq([identity(3), always(3)(9)], [3, 3]);
+	t.assert_eq(compose(inc, double)(5), 11);
+	t.assert_eq(pipe([inc, double])(5), 12);
+	t.assert_eq(pipe([])(5), 5);
+}
+
+fn test_arguments() {
+	let sub = (a, b) => a - b;
+	t.assert_eq(flip(sub)(1, 10), 9);
+	t.assert_eq(partial(sub, 10)(1), 9);
+	t.assert_eq(curry(sub)(10)(1), 9);
+	t.assert_eq(times(4, i => i * i), [0, 1, 4, 9]);
+}
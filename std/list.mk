@@ -0,0 +1,137 @@
+// Functions over arrays. None of them change the arrays they are given.
+
+import "std/testing" as t;
+
+// Combines the elements from the left, starting from init
+export fn reduce(xs, init, f) {
+	let acc = init;
+	for (x in xs) { acc = f(acc, x); }
+	acc
+}
+
+export fn sum(xs) { reduce(xs, 0, (a, b) => a + b) }
+
+export fn product(xs) { reduce(xs, 1, (a, b) => a * b) }
+
+// Returns the first element, or null for an empty array
+export fn first(xs) { xs[0] }
+
+// Returns the last element, or null for an empty array
+export fn last(xs) { xs[xs.len() - 1] }
+
+// Returns the elements from start up to, but not including, end
+export fn slice(xs, start, end) {
+	let out = [];
+	for (i in start..<end) {
+		if (i >= 0 && i < xs.len()) { out.push(xs[i]); }
+	}
+	out
+}
+
+export fn reverse(xs) {
+	let out = [];
+	for (i in 0..<xs.len()) { out.push(xs[xs.len() - 1 - i]); }
+	out
+}
+
+// Returns the position of the first element equal to x, or -1
+export fn index_of(xs, x) {
+	for (i in 0..<xs.len()) {
+		if (xs[i] == x) { return i; }
+	}
+	-1
+}
+
+// Returns the number of elements for which pred holds
+export fn count(xs, pred) {
+	reduce(xs, 0, (n, x) => pred(x) ? n + 1 : n)
+}
+
+// Returns the smallest element, or null for an empty array
+export fn min(xs) {
+	reduce(xs, first(xs), (m, x) => x < m ? x : m)
+}
+
+// Returns the largest element, or null for an empty array
+export fn max(xs) {
+	reduce(xs, first(xs), (m, x) => x > m ? x : m)
+}
+
+// Pairs up the elements of two arrays, as long as the shorter one
+export fn zip(xs, ys) {
+	let out = [];
+	for (i in 0..<(xs.len() < ys.len() ? xs.len() : ys.len())) {
+		out.push([xs[i], ys[i]]);
+	}
+	out
+}
+
+// Joins an array of arrays into one array
+export fn flatten(xss) {
+	reduce(xss, [], (out, xs) => [...out, ...xs])
+}
+
+// Sorts in ascending order
+export fn sort(xs) { sort_with(xs, (a, b) => a < b) }
+
+// Sorts so that less(a, b) holds for a before b. Equal elements keep
+// their order.
+export fn sort_with(xs, less) {
+	if (xs.len() <= 1) { return [...xs]; }
+
+	let mid = xs.len() / 2;
+	let left = sort_with(slice(xs, 0, mid), less);
+	let right = sort_with(slice(xs, mid, xs.len()), less);
+
+	let out = [];
+	let i = 0;
+	let j = 0;
+	for (_ in 0..<xs.len()) {
+		if (j >= right.len() || (i < left.len() && !less(right[j], left[i]))) {
+			out.push(left[i]);
+			i = i + 1;
+		} else {
+			out.push(right[j]);
+			j = j + 1;
+		}
+	}
+	out
+}
+
+fn test_reduce() {
+	t.assert_eq(reduce([1, 2, 3], 10, (a, b) => a - b), 4);
+	t.assert_eq(reduce([], 7, (a, b) => a + b), 7);
+	t.assert_eq(sum(1..4), 10);
+	t.assert_eq(product([2, 3, 4]), 24);
+}
+
+fn test_access() {
+	t.assert_eq([first([4, 5]), last([4, 5])], [4, 5]);
+	t.assert_eq([first([]), last([])], [null, null]);
+	t.assert_eq(slice([1, 2, 3, 4], 1, 3), [2, 3]);
+	t.assert_eq(slice([1, 2], 1, 9), [2]);
+	t.assert_eq([index_of([1, 2, 3], 3), index_of([1], 5)], [2, -1]);
+}
+
+fn test_reshape() {
+	let xs = [1, 2, 3];
+	t.assert_eq(reverse(xs), [3, 2, 1]);
+	t.assert_eq(xs, [1, 2, 3]);
+	t.assert_eq(zip([1, 2, 3], ["a", "b"]), [[1, "a"], [2, "b"]]);
+	t.assert_eq(flatten([[1], [], [2, 3]]), [1, 2, 3]);
+}
+
+fn test_count_min_max() {
+	t.assert_eq(count([1, 2, 3, 4], x => x % 2 == 0), 2);
+	t.assert_eq([min([3, 1, 2]), max([3, 1, 2])], [1, 3]);
+	t.assert_eq(min([]), null);
+}
+
+fn test_sort() {
+	t.assert_eq(sort([5, 3, 9, 1, 3, 0]), [0, 1, 3, 3, 5, 9]);
+	t.assert_eq(sort([]), []);
+	t.assert_eq(sort_with([1, 3, 2], (a, b) => a > b), [3, 2, 1]);
+
+	let pairs = [[2, "a"], [1, "b"], [2, "c"], [1, "d"]];
+	t.assert_eq(sort_with(pairs, (a, b) => a[0] < b[0]), [[1, "b"], [1, "d"], [2, "a"], [2, "c"]]);
+}
@@ -0,0 +1,66 @@
+// Integer arithmetic
+
+import "std/testing" as t;
+
+export fn abs(n) { n < 0 ? -n : n }
+
+// Returns -1, 0 or 1, by the sign of n
+export fn sign(n) { n < 0 ? -1 : (n > 0 ? 1 : 0) }
+
+export fn min(a, b) { a < b ? a : b }
+
+export fn max(a, b) { a > b ? a : b }
+
+// Returns n, moved into lo..hi
+export fn clamp(n, lo, hi) { min(max(n, lo), hi) }
+
+export fn is_even(n) { n % 2 == 0 }
+
+export fn is_odd(n) { n % 2 != 0 }
+
+// Greatest common divisor, never negative
+export fn gcd(a, b) { b == 0 ? abs(a) : gcd(b, a % b) }
+
+// Least common multiple, never negative
+export fn lcm(a, b) { a == 0 || b == 0 ? 0 : abs(a / gcd(a, b) * b) }
+
+export fn factorial(n) {
+	let out = 1;
+	for (i in 2..n) { out = out * i; }
+	out
+}
+
+// Returns the largest integer whose square is at most n
+export fn isqrt(n) {
+	if (n < 0) { throw "isqrt of a negative number"; }
+	if (n < 2) { return n; }
+
+	let lo = 1;
+	let hi = n;
+	// every step keeps lo * lo <= n < hi * hi
+	for (_ in 0..64) {
+		if (hi - lo <= 1) { return lo; }
+		let mid = lo + (hi - lo) / 2;
+		if (mid <= n / mid) { lo = mid; } else { hi = mid; }
+	}
+	lo
+}
+
+fn test_signs() {
+	t.assert_eq([abs(-3), abs(0), abs(4)], [3, 0, 4]);
+	t.assert_eq([sign(-3), sign(0), sign(4)], [-1, 0, 1]);
+	t.assert_eq([min(2, 5), max(2, 5)], [2, 5]);
+	t.assert_eq([clamp(-1, 0, 10), clamp(5, 0, 10), clamp(11, 0, 10)], [0, 5, 10]);
+	t.assert_eq([is_even(4), is_even(-3), is_odd(-3)], [true, false, true]);
+}
+
+fn test_divisors() {
+	t.assert_eq([gcd(12, 18), gcd(-4, 6), gcd(0, 5)], [6, 2, 5]);
+	t.assert_eq([lcm(4, 6), lcm(-3, 5), lcm(0, 5)], [12, 15, 0]);
+}
+
+fn test_factorial_isqrt() {
+	t.assert_eq([factorial(0), factorial(1), factorial(5)], [1, 1, 120]);
+	t.assert_eq([isqrt(0), isqrt(1), isqrt(15), isqrt(16), isqrt(17)], [0, 1, 3, 4, 4]);
+	t.assert_eq(isqrt(9223372036854775807), 3037000499);
+}
@@ -0,0 +1,32 @@
+// Package std is the standard library: modules written in Monkey, built
+// into the interpreter and imported as "std/NAME". Their functions whose
+// name starts with test_ are their tests.
+package std
+
+import (
+	"embed"
+	"strings"
+)
+
+//go:embed *.mk
+var files embed.FS
+
+// Returns the source of module std/name, such as "list"
+func Source(name string) (string, bool) {
+	source, err := files.ReadFile(name + ".mk")
+	if err != nil {
+		return "", false
+	}
+	return string(source), true
+}
+
+// Returns the names of all modules, in order
+func Names() []string {
+	entries, _ := files.ReadDir(".")
+
+	var names []string
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(entry.Name(), ".mk"))
+	}
+	return names
+}
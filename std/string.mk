@@ -0,0 +1,54 @@
+// Functions over strings, which are sequences of characters
+
+import "std/testing" as t;
+
+// Returns the characters of s, as strings
+export fn chars(s) { s.chars() }
+
+export fn is_empty(s) { s == "" }
+
+export fn reverse(s) {
+	let out = "";
+	for (c in s) { out = c + out; }
+	out
+}
+
+// Returns s, n times over
+export fn repeat(s, n) { s.repeat(n) }
+
+// Joins an array of strings, with sep between them
+export fn join(xs, sep) { xs.join(sep) }
+
+// Returns the number of times the character c is in s
+export fn count(s, c) {
+	let n = 0;
+	for (x in s) {
+		if (x == c) { n = n + 1; }
+	}
+	n
+}
+
+export fn starts_with(s, prefix) { s.starts_with(prefix) }
+
+export fn ends_with(s, suffix) { s.ends_with(suffix) }
+
+fn test_chars() {
+	t.assert_eq(chars("héllo"), ["h", "é", "l", "l", "o"]);
+	t.assert_eq(chars(""), []);
+	t.assert_eq([is_empty(""), is_empty(" ")], [true, false]);
+}
+
+fn test_build() {
+	t.assert_eq(reverse("abc"), "cba");
+	t.assert_eq(repeat("ab", 3), "ababab");
+	t.assert_eq(repeat("ab", 0), "");
+	t.assert_eq(join(["a", "b", "c"], ", "), "a, b, c");
+	t.assert_eq(join([], ", "), "");
+}
+
+fn test_search() {
+	t.assert_eq(count("banana", "a"), 3);
+	t.assert_eq([starts_with("banana", "ban"), starts_with("ba", "ban")], [true, false]);
+	t.assert_eq([ends_with("banana", "na"), ends_with("banana", "ba")], [true, false]);
+	t.assert(starts_with("x", ""), "every string starts with the empty string");
+}
@@ -0,0 +1,25 @@
+// Assertions for the test functions of modules
+
+// Throws message unless cond holds
+export fn assert(cond, message) {
+	if (!cond) { throw message; }
+	null
+}
+
+// Throws unless got equals want
+export fn assert_eq(got, want) {
+	if (got != want) { throw {"got": got, "want": want}; }
+	null
+}
+
+fn test_assert() {
+	assert(true, "true holds");
+	let failed = try { assert(false, "false fails"); "" } catch (e) { e["message"] };
+	assert_eq(failed, "false fails");
+}
+
+fn test_assert_eq() {
+	assert_eq([1, {"a": 2}], [1, {"a": 2}]);
+	let failed = try { assert_eq(1, 2); "" } catch (e) { e["message"] };
+	assert_eq(failed, "{\"got\": 1, \"want\": 2}");
+}
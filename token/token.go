@@ -1,5 +1,7 @@
 package tk
 
+import "fmt"
+
 /////////////////////
 // Data structures //
 /////////////////////
@@ -9,6 +11,13 @@ type TokenType string
 type Token struct {
 	Type    TokenType
 	Literal string
+	Line    int
+	Column  int
+}
+
+// Returns line:column of the token in its source
+func (t Token) Position() string {
+	return fmt.Sprintf("%d:%d", t.Line, t.Column)
 }
 
 // Vocabulary
@@ -20,6 +29,8 @@ const (
 	// Identifiers and literals
 	IDENTIFIER = "IDENTIFIER"
 	INT        = "INT"
+	FLOAT      = "FLOAT"
+	STRING     = "STRING"
 
 	// Arithmetic
 	ASSIGN		= "="
@@ -30,6 +41,11 @@ const (
 	ASTRICK		= "*"
 	DASTRICK	= "**"
 	MOD			= "%"
+	QUESTION	= "?"
+	PIPE		= "|>"
+	NULLISH		= "??"
+	AND			= "&&"
+	OR			= "||"
 
 	// Relational
 	LT    = "<"
@@ -42,32 +58,72 @@ const (
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
+	COLON     = ":"
+	ARROW     = "=>"
+	ELLIPSIS  = "..."
+	DOT       = "."
+
+	// Ranges
+	RANGE           = ".."
+	RANGE_EXCLUSIVE = "..<"
 
 	LPAREN = "("
 	RPAREN = ")"
 	LBRACE = "{"
 	RBRACE = "}"
+	LBRACKET = "["
+	RBRACKET = "]"
 
 	// Keywords
 	FUNCTION	= "FUNCTION"
 	LET			= "LET"
+	CONST		= "CONST"
 	IF			= "IF"
 	ELSE		= "ELSE"
 	FOR			= "FOR"
 	RETURN		= "RETURN"
 	TRUE		= "TRUE"
 	FALSE		= "FALSE"
+	NULL		= "NULL"
+	TRY			= "TRY"
+	CATCH		= "CATCH"
+	FINALLY		= "FINALLY"
+	THROW		= "THROW"
+	MATCH		= "MATCH"
+	IN			= "IN"
+	YIELD		= "YIELD"
+	STRUCT		= "STRUCT"
+	IMPL		= "IMPL"
+	ENUM		= "ENUM"
+	IMPORT		= "IMPORT"
+	EXPORT		= "EXPORT"
+	AS			= "AS"
 )
 
 var keywords = map[string]TokenType {
 	"fn": FUNCTION,
 	"let": LET,
+	"const": CONST,
 	"true": TRUE,
 	"false": FALSE,
+	"null": NULL,
 	"if": IF,
 	"else": ELSE,
 	"for": FOR,
 	"return": RETURN,
+	"try": TRY,
+	"catch": CATCH,
+	"finally": FINALLY,
+	"throw": THROW,
+	"match": MATCH,
+	"in": IN,
+	"yield": YIELD,
+	"struct": STRUCT,
+	"impl": IMPL,
+	"enum": ENUM,
+	"import": IMPORT,
+	"export": EXPORT,
+	"as": AS,
 }
 
 // Checks if supposed identifier is a keyword